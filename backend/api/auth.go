@@ -3,17 +3,19 @@ package api
 
 import (
 	"backend/auth"
+	"backend/db/models"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/go-chi/render"
 	"gorm.io/gorm"
 )
 
-// Login - authenticate user and return JWT token
+// Login - authenticate user and return JWT access and refresh tokens
 // POST /login - expects email and password in request body
 func (handler *RequestHandler) Login(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Debug Login: Handler called - Method: %s, Path: %s", r.Method, r.URL.Path)
@@ -70,8 +72,14 @@ func (handler *RequestHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// locked out IPs and accounts are refused before bcrypt runs
+	if handler.rejectLocked(w, r, creds.Email) {
+		return
+	}
+
 	user, err := handler.db.GetUserByCredentials(creds)
 	if err != nil {
+		handler.loginFailed(r, creds.Email)
 		log.Printf("Error Login: Database error for email %s: %v", creds.Email, err)
 		// Check if it's a "record not found" error (user doesn't exist) or password mismatch
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -89,36 +97,26 @@ func (handler *RequestHandler) Login(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Info Login: User retrieved - ID: %s, Email: %s, Role: %s, ApprovalStatus: %s",
 		user.ID.String(), user.Email, string(user.Role), string(user.ApprovalStatus))
 
-	token, err := auth.GenerateToken(user.ID, user.Email)
-	if err != nil {
-		log.Printf("Error Login: Failed to generate token: %v", err)
-		http.Error(w, "Server Error", http.StatusInternalServerError)
+	if requireEmailVerification() && user.EmailVerifiedAt == nil {
+		http.Error(w, "Email address not verified", http.StatusForbidden)
 		return
 	}
 
-	// Create a safe user response (exclude password hashs)
-	// Handle potential empty/null values safely
-	approvalStatus := string(user.ApprovalStatus)
-	if approvalStatus == "" {
-		approvalStatus = "pending" // Default value
-		log.Printf("Warning Login: ApprovalStatus was empty, defaulting to 'pending'")
+	// accounts with 2FA get a challenge instead of tokens
+	if user.TOTPEnabledAt != nil {
+		handler.respondMFAChallenge(w, r, user)
+		return
 	}
 
-	userResponse := map[string]any{
-		"ID":             user.ID.String(),
-		"Email":           user.Email,
-		"Name":            user.Name,
-		"WalletAddress":  user.WalletAddress,
-		"Role":            string(user.Role),
-		"ApprovalStatus":  approvalStatus,
-		"CreatedAt":       user.CreatedAt,
-		"UpdatedAt":       user.UpdatedAt,
+	tokens, err := handler.startSession(r, user, false)
+	if err != nil {
+		log.Printf("Error Login: Failed to start session: %v", err)
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
 	}
+	handler.loginSucceeded(user.Email)
 
-	response := map[string]any{
-		"token": token,
-		"user":  userResponse,
-	}
+	response := loginResponse(user, tokens)
 
 	log.Printf("Info Login: Attempting to serialize response for user %s", user.Email)
 
@@ -140,7 +138,7 @@ func (handler *RequestHandler) Login(w http.ResponseWriter, r *http.Request) {
 }
 
 // RegisterUser - create new user account
-// POST /register - expects email, password, name, wallet_address
+// POST /register - expects email, password, name, wallet_address and a SIWE signature from that wallet
 func (handler *RequestHandler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 	var userDetails auth.RegisterUserPayload
 	if err := json.NewDecoder(r.Body).Decode(&userDetails); err != nil {
@@ -153,6 +151,29 @@ func (handler *RequestHandler) RegisterUser(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	// the registrant must prove control of the wallet the admin will approve on-chain
+	msg, err := handler.verifyWalletSignature(userDetails.SiweMessage, userDetails.SiweSignature)
+	if errors.Is(err, errSIWEDisabled) {
+		http.Error(w, "Wallet verification is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Wallet verification failed: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if !strings.EqualFold(msg.Address.Hex(), userDetails.WalletAddress) {
+		http.Error(w, "Signed message is for a different wallet", http.StatusUnauthorized)
+		return
+	}
+
+	if _, err := handler.db.GetUserByWalletAddress(userDetails.WalletAddress); err == nil {
+		http.Error(w, "Wallet already registered", http.StatusBadRequest)
+		return
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	exists, err := handler.db.UserExists(userDetails.Email)
 	if exists {
 		http.Error(w, "User Exists", http.StatusBadRequest)
@@ -163,11 +184,16 @@ func (handler *RequestHandler) RegisterUser(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	err = handler.db.CreateUser(userDetails)
+	user, err := handler.db.CreateUser(userDetails)
 	if err != nil {
 		http.Error(w, "Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	render.JSON(w, r, map[string]string{"message": "User registered successfully"})
+	// a failed email doesn't fail the signup, the user can ask for another one
+	if err := handler.sendUserToken(r.Context(), user, models.TokenEmailVerification); err != nil {
+		log.Printf("Error: Failed to send verification email to user %s: %v", user.ID, err)
+	}
+
+	render.JSON(w, r, map[string]string{"message": "User registered successfully, check your email to verify your address"})
 }
@@ -0,0 +1,216 @@
+package api
+
+import (
+	"backend/auth"
+	"backend/db/models"
+	"backend/mail"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	verificationTokenTTL = 24 * time.Hour
+	resetTokenTTL        = time.Hour
+)
+
+// appURL - frontend base URL emailed links point to, APP_URL (default the local dev server)
+func appURL() string {
+	if v := os.Getenv("APP_URL"); v != "" {
+		return strings.TrimSuffix(v, "/")
+	}
+	return "http://localhost:5173"
+}
+
+// requireEmailVerification - REQUIRE_EMAIL_VERIFICATION=true blocks login until the email is verified
+func requireEmailVerification() bool {
+	return os.Getenv("REQUIRE_EMAIL_VERIFICATION") == "true"
+}
+
+// sendUserToken - create a single-use token for user and email them a link carrying it
+// earlier unused tokens of the same purpose are invalidated
+func (handler *RequestHandler) sendUserToken(ctx context.Context, user models.User, purpose models.UserTokenPurpose) error {
+	token, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	ttl, path, subject, text := verificationTokenTTL, "/verify-email", "Verify your email address",
+		"Please confirm your email address by opening the link below. The link expires in 24 hours."
+	if purpose == models.TokenPasswordReset {
+		ttl, path, subject, text = resetTokenTTL, "/reset-password", "Reset your password",
+			"Someone asked to reset the password of your account. Open the link below to choose a new one. "+
+				"The link expires in 1 hour. If this wasn't you, you can ignore this email."
+	}
+
+	if err := handler.db.CreateUserToken(models.UserToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(ttl),
+	}); err != nil {
+		return err
+	}
+
+	link := appURL() + path + "?token=" + url.QueryEscape(token)
+	return handler.mail.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: subject,
+		Body:    fmt.Sprintf("Hello %s,\n\n%s\n\n%s\n", user.Name, text, link),
+	})
+}
+
+type emailRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type tokenRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type resetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=8"`
+}
+
+// VerifyEmail handles POST /auth/verify-email
+// Confirms the email address with the token from the verification email
+func (handler *RequestHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req tokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		http.Error(w, "Validation Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	token, found, err := handler.db.ConsumeUserToken(auth.HashOpaqueToken(req.Token), models.TokenEmailVerification)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Invalid or expired token", http.StatusBadRequest)
+		return
+	}
+
+	if err := handler.db.MarkEmailVerified(token.UserID); err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	render.JSON(w, r, map[string]string{"message": "Email verified"})
+}
+
+// ResendVerification handles POST /auth/resend-verification
+// Sends a new verification email, the response is the same whether or not the address is registered
+func (handler *RequestHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var req emailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		http.Error(w, "Validation Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := handler.db.GetUserByEmail(req.Email)
+	if err == nil && user.EmailVerifiedAt == nil {
+		if err := handler.sendUserToken(r.Context(), user, models.TokenEmailVerification); err != nil {
+			log.Printf("Error: Failed to send verification email to user %s: %v", user.ID, err)
+		}
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	render.Status(r, http.StatusAccepted)
+	render.JSON(w, r, map[string]string{"message": "If the address is registered and unverified, a verification email has been sent"})
+}
+
+// ForgotPassword handles POST /auth/forgot-password
+// Emails a password reset link, the response is the same whether or not the address is registered
+func (handler *RequestHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req emailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		http.Error(w, "Validation Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := handler.db.GetUserByEmail(req.Email)
+	if err == nil {
+		if err := handler.sendUserToken(r.Context(), user, models.TokenPasswordReset); err != nil {
+			log.Printf("Error: Failed to send password reset email to user %s: %v", user.ID, err)
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	render.Status(r, http.StatusAccepted)
+	render.JSON(w, r, map[string]string{"message": "If the address is registered, a password reset email has been sent"})
+}
+
+// ResetPasswordWithToken handles POST /auth/reset-password
+// Sets a new password with the token from the reset email and signs out every session
+func (handler *RequestHandler) ResetPasswordWithToken(w http.ResponseWriter, r *http.Request) {
+	var req resetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		http.Error(w, "Validation Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	token, found, err := handler.db.ConsumeUserToken(auth.HashOpaqueToken(req.Token), models.TokenPasswordReset)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Invalid or expired token", http.StatusBadRequest)
+		return
+	}
+
+	newHash, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		http.Error(w, "Hashing Error", http.StatusInternalServerError)
+		return
+	}
+	if err := handler.db.UpdatePassword(token.UserID.String(), newHash); err != nil {
+		http.Error(w, "DB Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// whoever had the old password must not stay logged in
+	if err := handler.db.RevokeUserSessions(token.UserID, uuid.Nil); err != nil {
+		log.Printf("Warning: Failed to revoke sessions after password reset: %v", err)
+	}
+	// the reset link reached the inbox, so the address is verified too
+	if err := handler.db.MarkEmailVerified(token.UserID); err != nil {
+		log.Printf("Warning: Failed to mark email verified after password reset: %v", err)
+	}
+
+	render.JSON(w, r, map[string]string{"message": "Password has been reset, please log in again"})
+}
@@ -6,8 +6,10 @@ import (
 	"backend/db"
 	"backend/db/models"
 	"backend/ipfs"
+	"backend/mail"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math/big"
@@ -18,7 +20,6 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware" // CORS middleware
 	"github.com/go-chi/cors"
@@ -76,15 +77,17 @@ type ApproveUserRequest struct {
 // RequestHandler - main API handler struct
 // contains database and blockchain service references
 type RequestHandler struct {
-	db    *db.Database      // database connection
-	chain *blockchain.ChainService // blockchain service (optional)
+	db      *db.Database      // database connection
+	chain   *blockchain.ChainService // blockchain service (optional)
+	mail    mail.Sender       // outgoing email (verification, password reset)
+	limiter *auth.Limiter     // login rate limits and lockouts
 }
 
 // NewRequestHandler - create new API handler instance
-func NewRequestHandler(db *db.Database, chain *blockchain.ChainService) *RequestHandler {
+func NewRequestHandler(db *db.Database, chain *blockchain.ChainService, mailer mail.Sender, limiter *auth.Limiter) *RequestHandler {
 	// Setup blockchain event listeners (optional - won't crash if subscriptions fail)
 
-	return &RequestHandler{db, chain}
+	return &RequestHandler{db, chain, mailer, limiter}
 }
 
 // Start - setup routes and start HTTP server
@@ -95,12 +98,16 @@ func (handler *RequestHandler) Start() {
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://*.vercel.app", "http://localhost:5173", "http://localhost:3000", "http://127.0.0.1:5173"}, // Allow Vercel domains and local development
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "Idempotency-Key"},
+		ExposedHeaders:   []string{"Link", "Location"},
 		AllowCredentials: true,
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
 	}))
 
+	// behind a reverse proxy the client address comes from X-Forwarded-For / X-Real-IP, rate limits key on it
+	if os.Getenv("TRUST_PROXY") == "true" {
+		r.Use(middleware.RealIP)
+	}
 	r.Use(middleware.Logger)
 	r.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -119,8 +126,21 @@ func (handler *RequestHandler) Start() {
 		w.Write([]byte("pong"))
 	})
 
-	r.Post("/login", handler.Login)
-	r.Post("/register", handler.RegisterUser)
+	// credential checks and routes that send email are rate limited per IP
+	r.Group(func(r chi.Router) {
+		r.Use(handler.RateLimit)
+		r.Post("/login", handler.Login)
+		r.Post("/register", handler.RegisterUser)
+		r.Post("/login/wallet", handler.LoginWallet)
+		r.Post("/login/2fa", handler.LoginSecondFactor)
+		r.Post("/auth/forgot-password", handler.ForgotPassword)
+		r.Post("/auth/resend-verification", handler.ResendVerification)
+	})
+	r.Get("/auth/nonce", handler.GetAuthNonce)
+	r.Post("/auth/refresh", handler.RefreshToken)
+	r.Post("/auth/verify-email", handler.VerifyEmail)
+	r.Post("/auth/reset-password", handler.ResetPasswordWithToken)
+	r.Get("/.well-known/jwks.json", handler.GetJWKS)
 
 	// Temporarily move upload outside auth for testing
 	r.Post("/upload", handler.UploadMetadata)
@@ -128,48 +148,131 @@ func (handler *RequestHandler) Start() {
 	r.Group(func(r chi.Router) {
 		r.Use(auth.Middleware)
 
-		// Property Routes
+		// Property Routes, also open to API keys with the read scope
 		r.Get("/properties", handler.GetProperties)
 		r.Get("/properties/{id}", handler.GetProperty)
 		r.Get("/properties/{id}/metadata", handler.GetPropertyMetadata)
 		r.Get("/properties/{id}/token-balance/{wallet}", handler.GetPropertyTokenBalance)
 		r.Get("/properties/{id}/token-stats", handler.GetPropertyTokenStats)
-		r.Post("/properties/{id}/transfer", handler.TransferPropertyTokens)
-		r.Post("/properties/{id}/purchase", handler.CreateTokenPurchase)
-		r.Get("/properties/{id}/pending-purchases", handler.GetPendingTokenPurchases)
-		r.Post("/properties/{id}/purchases/{purchaseId}/approve", handler.ApproveTokenPurchase)
-		r.Post("/properties/{id}/purchases/{purchaseId}/update-tx", handler.UpdateTokenPurchaseTxHash)
-		
-		// User routes - use Route() to ensure proper sub-path matching
-		r.Route("/users/me", func(r chi.Router) {
-			r.Get("/purchases", handler.GetMyTokenPurchases)
-			r.Get("/pending-transfers", handler.GetMyPendingTransfers)
-			r.Post("/reset-password", handler.ResetPassword)
-			r.Get("/", handler.GetCurrentUser) // "/" matches /users/me exactly
-			r.Put("/", handler.UpdateUserInfo)
-			r.Delete("/", handler.DeleteUser)
-		})
+		r.Get("/properties/{id}/offerings", handler.GetPropertyOfferings)
+		r.Get("/properties/{id}/orderbook", handler.GetPropertyOrderBook)
+		r.Get("/properties/{id}/trades", handler.GetPropertyTrades)
+		r.Get("/properties/{id}/distributions", handler.GetPropertyDistributions)
+		r.Get("/jobs/{id}", handler.GetJob)
 
-		// Property Upload Request Routes (authenticated users)
-		r.Post("/property-upload-requests", handler.CreatePropertyUploadRequest)
-		r.Get("/property-upload-requests", handler.GetPropertyUploadRequests)
-		r.Get("/property-upload-requests/user", handler.GetPropertyUploadRequests)
-		r.Get("/property-upload-requests/{id}", handler.GetPropertyUploadRequest)
-
-		// Admin Routes
+		// Staff Routes, each group requires one permission (admins hold all of them, API keys get them from scopes)
 		r.Group(func(r chi.Router) {
-			r.Use(handler.AdminMiddleware)
+			r.Use(handler.RequirePermission(PermViewUsers))
 			r.Get("/users", handler.GetUsers)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(handler.RequirePermission(PermApproveUsers))
 			r.Post("/approve-user", handler.ApproveUser)
+			r.Post("/reject-user", handler.RejectUser)
+			r.Post("/revoke-user", handler.RevokeUser)
 			r.Post("/users/approval", handler.UpdateUserApproval)
+			r.Get("/kyc", handler.GetKYCSubmissions)
+			r.Get("/kyc/{id}", handler.GetKYCSubmission)
+			r.Post("/kyc/{id}/review", handler.StartKYCReview)
+			r.Post("/kyc/{id}/checks", handler.AddKYCCheck)
+			r.Post("/kyc/{id}/complete", handler.CompleteKYCReview)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(handler.RequirePermission(PermManageProperties))
 			r.Post("/properties", handler.CreateProperty)
 			r.Post("/properties/approval", handler.UpdatePropertyApproval)
-			r.Post("/revenue/distribute", handler.DistributeRevenue)
+			r.Post("/properties/{id}/offerings", handler.CreateOffering)
+			r.Post("/properties/{id}/offerings/{offeringId}/close", handler.CloseOffering)
 			r.Post("/property-upload-requests/{id}/approve", handler.ApprovePropertyUploadRequest)
 			r.Post("/property-upload-requests/{id}/reject", handler.RejectPropertyUploadRequest)
 		})
+		r.Group(func(r chi.Router) {
+			r.Use(handler.RequirePermission(PermDistributeRevenue))
+			r.Post("/revenue/distribute", handler.DistributeRevenue)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(handler.RequirePermission(PermViewTransactions))
+			r.Get("/transactions", handler.GetTransactions)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(handler.RequirePermission(PermManageRoles))
+			r.Get("/users/{id}/roles", handler.GetUserRoles)
+			r.Post("/users/{id}/roles", handler.AssignUserRole)
+			r.Delete("/users/{id}/roles/{role}", handler.RemoveUserRole)
+		})
+
+		// Routes acting as the logged-in user, API keys are refused
+		r.Group(func(r chi.Router) {
+			r.Use(handler.RequireUser)
+
+			r.Post("/properties/{id}/transfer", handler.TransferPropertyTokens)
+			r.Post("/properties/{id}/purchase", handler.CreateTokenPurchase)
+			r.Get("/properties/{id}/pending-purchases", handler.GetPendingTokenPurchases)
+			r.Post("/properties/{id}/purchases/{purchaseId}/approve", handler.ApproveTokenPurchase)
+			r.Post("/properties/{id}/purchases/{purchaseId}/update-tx", handler.UpdateTokenPurchaseTxHash)
+			r.Post("/properties/{id}/purchases/{purchaseId}/payment", handler.VerifyTokenPurchasePayment)
+			r.Post("/properties/{id}/purchases/{purchaseId}/decline", handler.DeclineTokenPurchase)
+			r.Post("/properties/{id}/purchases/{purchaseId}/cancel", handler.CancelTokenPurchase)
+			r.Post("/properties/{id}/purchases/{purchaseId}/refund", handler.RefundTokenPurchase)
+			r.Post("/properties/{id}/orders", handler.PlaceOrder)
+			r.Delete("/orders/{id}", handler.CancelOrder)
+			r.Post("/trades/{id}/payment", handler.SubmitTradePayment)
+			r.Post("/trades/{id}/transfer", handler.SubmitTradeTransfer)
+			r.Get("/properties/{id}/distributions/{distributionId}/entitlement", handler.GetDistributionEntitlement)
+			r.Post("/auth/logout", handler.Logout)
+			r.Post("/auth/logout-all", handler.LogoutAll)
+
+			// User routes - use Route() to ensure proper sub-path matching
+			r.Route("/users/me", func(r chi.Router) {
+				r.Get("/purchases", handler.GetMyTokenPurchases)
+				r.Get("/pending-transfers", handler.GetMyPendingTransfers)
+				r.Get("/revenue", handler.GetMyRevenue)
+				r.Get("/transactions", handler.GetMyTransactions)
+				r.Get("/orders", handler.GetMyOrders)
+				r.Get("/trades", handler.GetMyTrades)
+				r.Get("/portfolio", handler.GetMyPortfolio)
+				r.Get("/permissions", handler.GetMyPermissions)
+				r.Get("/kyc", handler.GetMyKYC)
+				r.Post("/kyc", handler.SubmitKYC)
+				r.Get("/2fa", handler.GetTwoFactorStatus)
+				r.Post("/2fa/setup", handler.SetupTwoFactor)
+				r.Post("/2fa/enable", handler.EnableTwoFactor)
+				r.Post("/2fa/disable", handler.DisableTwoFactor)
+				r.Post("/2fa/recovery-codes", handler.RegenerateRecoveryCodes)
+				r.Post("/reset-password", handler.ResetPassword)
+				r.Get("/", handler.GetCurrentUser) // "/" matches /users/me exactly
+				r.Put("/", handler.UpdateUserInfo)
+				r.Delete("/", handler.DeleteUser)
+			})
+
+			// Property Upload Request Routes (authenticated users)
+			r.Post("/property-upload-requests", handler.CreatePropertyUploadRequest)
+			r.Get("/property-upload-requests", handler.GetPropertyUploadRequests)
+			r.Get("/property-upload-requests/user", handler.GetPropertyUploadRequests)
+			r.Get("/property-upload-requests/{id}", handler.GetPropertyUploadRequest)
+
+			// Admin Routes
+			r.Group(func(r chi.Router) {
+				r.Use(handler.AdminMiddleware)
+				r.Get("/lockouts", handler.GetLockouts)
+				r.Delete("/lockouts/{key}", handler.ClearLockout)
+				r.Get("/service-accounts", handler.GetServiceAccounts)
+				r.Post("/service-accounts", handler.CreateServiceAccount)
+				r.Delete("/service-accounts/{id}", handler.DisableServiceAccount)
+				r.Get("/service-accounts/{id}/keys", handler.GetAPIKeys)
+				r.Post("/service-accounts/{id}/keys", handler.CreateAPIKey)
+				r.Delete("/service-accounts/{id}/keys/{keyId}", handler.RevokeAPIKey)
+			})
+		})
 	})
 
+	// background workers for queued blockchain jobs, purchase and trade expiry, stopped on shutdown
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	handler.startJobWorkers(jobCtx)
+	handler.startPurchaseExpiry(jobCtx)
+	handler.startTradeExpiry(jobCtx)
+
 	srv := &http.Server{
 		Addr:    ":3000",
 		Handler: r,
@@ -252,20 +355,37 @@ func (handler *RequestHandler) UpdateUserInfo(w http.ResponseWriter, r *http.Req
 		}
 	}
 
-	if err := handler.db.UpdateUserInfo(claims.UserID.String(), req.Name, req.Email); err != nil {
+	emailChanged, err := handler.db.UpdateUserInfo(claims.UserID.String(), req.Name, req.Email)
+	if err != nil {
 		http.Error(w, "DB Error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	message := "Profile updated successfully"
+	if emailChanged {
+		// the new address has to be verified again, a failed email can be repeated via /auth/resend-verification
+		message = "Profile updated successfully, check your email to verify your new address"
+		user, err := handler.db.GetUserById(claims.UserID.String())
+		if err == nil {
+			err = handler.sendUserToken(r.Context(), user, models.TokenEmailVerification)
+		}
+		if err != nil {
+			log.Printf("Error: Failed to send verification email to user %s: %v", claims.UserID, err)
+		}
+	}
+
 	render.JSON(w, r, map[string]string{
 		"status":  "success",
-		"message": "Profile updated successfully",
+		"message": message,
 	})
 }
 
+// UpdateUserApproval handles POST /users/approval
+// Sets a wallet's on-chain approval through the same jobs as /approve-user, /reject-user and /revoke-user:
+// approved queues approve_user, rejected queues reject_user and revoked queues revoke_user
 func (handler *RequestHandler) UpdateUserApproval(w http.ResponseWriter, r *http.Request) {
 	type UserApprovalRequest struct {
-		WalletAddress string                `json:"wallet_address"`
+		WalletAddress string                `json:"wallet_address" validate:"required,eth_addr"`
 		Status        models.ApprovalStatus `json:"status"`
 	}
 
@@ -274,38 +394,30 @@ func (handler *RequestHandler) UpdateUserApproval(w http.ResponseWriter, r *http
 		http.Error(w, "Invalid Body", http.StatusBadRequest)
 		return
 	}
-
-	if req.WalletAddress == "" {
-		http.Error(w, "wallet_address is required", http.StatusBadRequest)
-		return
-	}
-
-	if req.Status != models.ApprovalApproved && req.Status != models.ApprovalRejected {
-		http.Error(w, "status must be 'approved' or 'rejected'", http.StatusBadRequest)
-		return
-	}
-
-	tx, err := handler.chain.ApproveUser(req.WalletAddress)
-	if err != nil {
-		http.Error(w, "Blockchain error: "+err.Error(), http.StatusInternalServerError)
+	if err := validate.Struct(req); err != nil {
+		http.Error(w, "Validation Error: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// 2. WAIT for mining
-	log.Printf("Waiting for approval tx to mine: %s", tx.Hash().Hex())
-	receipt, err := bind.WaitMined(r.Context(), handler.chain.Client, tx)
-	if err != nil || receipt.Status == 0 {
-		http.Error(w, "Transaction failed on-chain", http.StatusInternalServerError)
+	if handler.chain == nil || handler.chain.Approval == nil {
+		http.Error(w, "Blockchain Error: approval contract not deployed", http.StatusServiceUnavailable)
 		return
 	}
 
-	// 3. Update DB immediately
-	err = handler.db.UpdateUserApproval(req.WalletAddress, "approved")
-	if err != nil {
-		log.Printf("Failed to update DB after mining: %v", err)
+	key := strings.ToLower(req.WalletAddress)
+	switch req.Status {
+	case models.ApprovalApproved:
+		if !handler.kycApproved(w, req.WalletAddress) {
+			return
+		}
+		handler.enqueueJob(w, r, models.JobApproveUser, key, approveUserJob{WalletAddress: req.WalletAddress})
+	case models.ApprovalRejected:
+		handler.enqueueJob(w, r, models.JobRejectUser, key, rejectUserJob{WalletAddress: req.WalletAddress})
+	case models.ApprovalRevoked:
+		handler.enqueueJob(w, r, models.JobRevokeUser, key, revokeUserJob{WalletAddress: req.WalletAddress})
+	default:
+		http.Error(w, "status must be 'approved', 'rejected' or 'revoked'", http.StatusBadRequest)
 	}
-
-	render.JSON(w, r, map[string]string{"tx_hash": tx.Hash().Hex(), "": "confirmed"})
 }
 
 // DeleteUser handles DELETE /users/me
@@ -317,10 +429,19 @@ func (handler *RequestHandler) DeleteUser(w http.ResponseWriter, r *http.Request
 	}
 
 	if err := handler.db.DeleteUser(claims.UserID.String()); err != nil {
+		if errors.Is(err, db.ErrUserHasOnChainRoles) {
+			http.Error(w, "Account holds staff roles mirrored on-chain, ask an admin to remove them first", http.StatusConflict)
+			return
+		}
 		http.Error(w, "DB Error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// tokens of a deleted account must stop working right away
+	if err := handler.db.RevokeUserSessions(claims.UserID, uuid.Nil); err != nil {
+		log.Printf("Warning: Failed to revoke sessions of deleted user %s: %v", claims.UserID, err)
+	}
+
 	render.JSON(w, r, map[string]string{
 		"status":  "success",
 		"message": "Account deleted successfully",
@@ -370,6 +491,11 @@ func (handler *RequestHandler) ResetPassword(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	// 5. Sign out every other session, the one changing the password stays logged in
+	if err := handler.db.RevokeUserSessions(claims.UserID, claims.SessionID); err != nil {
+		log.Printf("Warning: Failed to revoke sessions after password change: %v", err)
+	}
+
 	render.JSON(w, r, map[string]string{
 		"status":  "success",
 		"message": "Password updated successfully",
@@ -393,9 +519,9 @@ func (handler *RequestHandler) DistributeRevenue(w http.ResponseWriter, r *http.
 		return
 	}
 
-	tx, err := handler.chain.DistributeRevenue(req.TokenAddress, req.StablecoinAddress, req.Amount)
+	tx, err := handler.chain.DistributeRevenue(r.Context(), req.TokenAddress, req.StablecoinAddress, req.Amount)
 	if err != nil {
-		http.Error(w, "Blockchain Submission Failed: "+err.Error(), http.StatusInternalServerError)
+		writeChainError(w, "Blockchain Submission Failed", err)
 		return
 	}
 
@@ -439,6 +565,11 @@ func (handler *RequestHandler) ApproveUser(w http.ResponseWriter, r *http.Reques
 	// }
 	// handler.chain = chain
 
+	// only a completed KYC review clears a wallet for approval
+	if !handler.kycApproved(w, req.WalletAddress) {
+		return
+	}
+
 	// Check if Approval contract is deployed
 	if handler.chain.Approval == nil {
 		log.Printf("Approval contract not deployed - cannot approve users")
@@ -446,27 +577,54 @@ func (handler *RequestHandler) ApproveUser(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	log.Printf("Approving user on blockchain: %s", req.WalletAddress)
-	tx, err := handler.chain.ApproveUser(req.WalletAddress)
-	if err != nil {
-		log.Printf("Blockchain approval failed for %s: %v", req.WalletAddress, err)
-		http.Error(w, "Blockchain Error: "+err.Error(), http.StatusInternalServerError)
+	// the transaction is sent and awaited by a job worker, the client polls GET /jobs/{id}
+	log.Printf("Queueing blockchain approval for: %s", req.WalletAddress)
+	handler.enqueueJob(w, r, models.JobApproveUser, strings.ToLower(req.WalletAddress), approveUserJob{
+		WalletAddress: req.WalletAddress,
+	})
+}
+
+// RejectUser - reject a wallet on-chain with ApprovalService.rejectUser
+// POST /reject-user - requires wallet_address, queued like /approve-user
+func (handler *RequestHandler) RejectUser(w http.ResponseWriter, r *http.Request) {
+	var req ApproveUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		http.Error(w, "Validation Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if handler.chain == nil || handler.chain.Approval == nil {
+		http.Error(w, "Blockchain Error: approval contract not deployed", http.StatusServiceUnavailable)
 		return
 	}
-	log.Printf("Blockchain approval confirmed for: %s", req.WalletAddress)
 
-	// Check final approval status
-	approved, err := handler.chain.IsApproved(req.WalletAddress)
-	if err != nil {
-		log.Printf("Warning: Could not verify approval status: %v", err)
-		approved = false // assume not approved if we can't check
+	handler.enqueueJob(w, r, models.JobRejectUser, strings.ToLower(req.WalletAddress), rejectUserJob{
+		WalletAddress: req.WalletAddress,
+	})
+}
+
+// RevokeUser - withdraw a wallet's approval on-chain with ApprovalService.revoke
+// POST /revoke-user - requires wallet_address, queued like /approve-user
+func (handler *RequestHandler) RevokeUser(w http.ResponseWriter, r *http.Request) {
+	var req ApproveUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		http.Error(w, "Validation Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if handler.chain == nil || handler.chain.Approval == nil {
+		http.Error(w, "Blockchain Error: approval contract not deployed", http.StatusServiceUnavailable)
+		return
 	}
 
-	render.JSON(w, r, map[string]interface{}{
-		"status":   "confirmed",
-		"message":  "User approval confirmed on blockchain",
-		"tx_hash":  tx.Hash().Hex(),
-		"approved": approved,
+	handler.enqueueJob(w, r, models.JobRevokeUser, strings.ToLower(req.WalletAddress), revokeUserJob{
+		WalletAddress: req.WalletAddress,
 	})
 }
 
@@ -485,6 +643,7 @@ func (handler *RequestHandler) GetUsers(w http.ResponseWriter, r *http.Request)
 // UpdatePropertyApproval is now in property.go with blockchain integration
 
 // AdminMiddleware ensures the authenticated user has the 'admin' role.
+// With REQUIRE_ADMIN_2FA the token must also come from a login that passed the second factor.
 func (handler *RequestHandler) AdminMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		claims, ok := r.Context().Value(auth.ClaimsKey).(*auth.Claims)
@@ -503,6 +662,9 @@ func (handler *RequestHandler) AdminMiddleware(next http.Handler) http.Handler {
 			http.Error(w, "Forbidden: Admin access required", http.StatusForbidden)
 			return
 		}
+		if secondFactorMissing(w, user, claims) {
+			return
+		}
 
 		next.ServeHTTP(w, r)
 	})
@@ -658,11 +820,25 @@ func (handler *RequestHandler) GetPropertyTokenStats(w http.ResponseWriter, r *h
 		percentageSold = (totalSold / total) * 100
 	}
 
+	// progress of the offering running now, if any
+	var offering *offeringView
+	if open, found, err := handler.db.GetOpenOffering(prop.ID, time.Now()); err != nil {
+		log.Printf("Failed to get open offering: %v", err)
+	} else if found {
+		if totals, err := handler.db.GetOfferingTotals(open.ID, ""); err != nil {
+			log.Printf("Failed to get offering totals: %v", err)
+		} else {
+			view := newOfferingView(open, totals, time.Now())
+			offering = &view
+		}
+	}
+
 	render.JSON(w, r, map[string]interface{}{
 		"total":           total,
 		"sold":            totalSold,
 		"available":       available,
 		"percentage_sold": percentageSold,
+		"offering":        offering,
 	})
 }
 
@@ -707,10 +883,10 @@ func (handler *RequestHandler) TransferPropertyTokens(w http.ResponseWriter, r *
 	amountFloat.Mul(amountFloat, weiMultiplier)
 	amountBig, _ := amountFloat.Int(nil)
 
-	tx, err := handler.chain.TransferTokens(prop.OnchainTokenAddress, req.ToAddress, amountBig)
+	tx, err := handler.chain.TransferTokens(r.Context(), prop.OnchainTokenAddress, req.ToAddress, amountBig)
 	if err != nil {
 		log.Printf("Failed to transfer tokens: %v", err)
-		http.Error(w, "Transfer failed: "+err.Error(), http.StatusInternalServerError)
+		writeChainError(w, "Transfer failed", err)
 		return
 	}
 
@@ -729,6 +905,7 @@ func (handler *RequestHandler) TransferPropertyTokens(w http.ResponseWriter, r *
 		PropertyID:  propertyUID,
 		BuyerWallet: req.ToAddress,
 		Amount:      amountStr,
+		Status:      models.PurchaseTransferred, // sent directly by the owner, nothing to pay or approve
 		TokenTxHash: txHash,
 		CreatedAt:   time.Now(),
 	}
@@ -747,16 +924,34 @@ func (handler *RequestHandler) TransferPropertyTokens(w http.ResponseWriter, r *
 	})
 }
 
+// parseUnits - decimal amount in whole units (ETH or tokens) as an integer of 10^-decimals units, e.g. wei
+// exact, unlike going through big.Float, so it matches on-chain values
+func parseUnits(amount string, decimals int64) (*big.Int, error) {
+	value, ok := new(big.Rat).SetString(amount)
+	if !ok || value.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid amount %q", amount)
+	}
+	value.Mul(value, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(decimals), nil)))
+	if !value.IsInt() {
+		return nil, fmt.Errorf("amount %q has more than %d decimals", amount, decimals)
+	}
+	return value.Num(), nil
+}
+
 // CreateTokenPurchase handles POST /properties/{id}/purchase
 // Records a token purchase after buyer sends payment (before owner approves transfer)
+// The payment transaction must be a transfer of purchase_price ETH from the buyer to the owner wallet,
+// the purchase is paid once it is confirmed and expires if the owner doesn't approve it in time
+// While the property has an open offering the purchase must match its price and fit its allocation limits
+// buyer_wallet must be the caller's own wallet
 func (handler *RequestHandler) CreateTokenPurchase(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
 	type PurchaseRequest struct {
 		BuyerWallet   string `json:"buyer_wallet" validate:"required,eth_addr"`
-		Amount        string `json:"amount" validate:"required"` // Token amount in token units
-		PaymentTxHash string `json:"payment_tx_hash" validate:"required"` // ETH payment transaction hash
-		PurchasePrice string `json:"purchase_price" validate:"required"` // ETH paid
+		Amount        string `json:"amount" validate:"required,numeric"` // Token amount in token units
+		PaymentTxHash string `json:"payment_tx_hash" validate:"required,len=66,hexadecimal"` // ETH payment transaction hash
+		PurchasePrice string `json:"purchase_price" validate:"required,numeric"` // ETH paid
 	}
 
 	var req PurchaseRequest
@@ -770,37 +965,121 @@ func (handler *RequestHandler) CreateTokenPurchase(w http.ResponseWriter, r *htt
 		return
 	}
 
+	// a payment seen in the mempool must not be registered by anyone but its sender
+	user, ok := handler.walletUser(w, r)
+	if !ok {
+		return
+	}
+	if !strings.EqualFold(user.WalletAddress, req.BuyerWallet) {
+		http.Error(w, "Forbidden: buyer_wallet must be your own wallet", http.StatusForbidden)
+		return
+	}
+
+	if _, err := parseUnits(req.Amount, 18); err != nil {
+		http.Error(w, "Validation Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	priceWei, err := parseUnits(req.PurchasePrice, 18)
+	if err != nil {
+		http.Error(w, "Validation Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Verify property exists
-	_, err := handler.db.GetPropertyByID(id)
+	prop, err := handler.db.GetPropertyByID(id)
 	if err != nil {
 		http.Error(w, "Property not found", http.StatusNotFound)
 		return
 	}
 
-	propertyUID, _ := uuid.Parse(id)
+	if handler.chain == nil {
+		http.Error(w, "Blockchain service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	// one payment can only settle one purchase or trade
+	if handler.txHashUsed(w, req.PaymentTxHash) {
+		return
+	}
+
+	// properties with offerings only sell while one is open, at its price and within its limits
+	now := time.Now()
+	offering, ok := handler.purchaseOffering(w, prop.ID, now)
+	if !ok {
+		return
+	}
+	var offeringID *uuid.UUID
+	check := offeringPurchaseCheck(req.Amount, priceWei, now)
+	if offering != nil {
+		offeringID = &offering.ID
+		// checked again while reserving, this only fails early before the payment is looked up
+		totals, err := handler.db.GetOfferingTotals(offering.ID, req.BuyerWallet)
+		if err != nil {
+			http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := check(*offering, totals); err != nil {
+			writeOfferingError(w, err)
+			return
+		}
+	}
+
+	expiresAt := now.Add(purchaseApprovalWindow())
 	purchase := models.TokenPurchase{
 		ID:            uuid.New(),
-		PropertyID:    propertyUID,
+		PropertyID:    prop.ID,
+		OfferingID:    offeringID,
 		BuyerWallet:   req.BuyerWallet,
 		Amount:        req.Amount,
+		Status:        models.PurchasePaid,
 		PaymentTxHash: req.PaymentTxHash,
-		TokenTxHash:   "pending", // Will be updated when owner approves
 		PurchasePrice: req.PurchasePrice,
-		CreatedAt:     time.Now(),
+		ExpiresAt:     &expiresAt,
+		CreatedAt:     now,
+	}
+
+	// a payment without enough confirmations is recorded as awaiting_payment and checked again via /payment
+	_, err = handler.chain.VerifyPayment(r.Context(), req.PaymentTxHash, req.BuyerWallet, prop.OwnerWallet, priceWei)
+	var settleErr *blockchain.SettlementError
+	switch {
+	case err == nil:
+		purchase.PaymentVerifiedAt = &now
+	case errors.As(err, &settleErr) && settleErr.Kind == blockchain.SettlementPending:
+		// a payment that never confirms only holds its tokens for a short while
+		expiresAt = now.Add(purchasePaymentWindow())
+		purchase.Status = models.PurchaseAwaitingPayment
+		purchase.ExpiresAt = &expiresAt
+	default:
+		log.Printf("Warning: Payment %s for property %s not accepted: %v", req.PaymentTxHash, id, err)
+		writeSettlementError(w, "Payment", err)
+		return
+	}
+
+	// the offering's tokens are reserved atomically, a concurrent buyer may have taken them since the check above
+	if offering != nil {
+		err = handler.db.ReserveOfferingPurchase(purchase, check)
+	} else {
+		err = handler.db.CreateTokenPurchase(purchase)
 	}
-
-	if err := handler.db.CreateTokenPurchase(purchase); err != nil {
+	if err != nil {
 		log.Printf("Failed to create token purchase: %v", err)
-		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		writeOfferingError(w, err)
 		return
 	}
 
-	log.Printf("Token purchase recorded: Property=%s, Buyer=%s, Amount=%s, PaymentTX=%s", id, req.BuyerWallet, req.Amount, req.PaymentTxHash)
+	log.Printf("Token purchase recorded: Property=%s, Buyer=%s, Amount=%s, PaymentTX=%s, Status=%s", id, req.BuyerWallet, req.Amount, req.PaymentTxHash, purchase.Status)
 
+	message := "Payment verified. Waiting for owner approval."
+	if purchase.Status == models.PurchaseAwaitingPayment {
+		message = "Payment not confirmed yet (" + settleErr.Reason + "). Check it again with POST /properties/" + id + "/purchases/" + purchase.ID.String() + "/payment."
+		render.Status(r, http.StatusAccepted)
+	}
 	render.JSON(w, r, map[string]interface{}{
-		"status":      "success",
-		"purchase_id": purchase.ID.String(),
-		"message":     "Purchase recorded. Waiting for owner approval.",
+		"status":          "success",
+		"purchase_id":     purchase.ID.String(),
+		"purchase_status": purchase.Status,
+		"expires_at":      purchase.ExpiresAt,
+		"message":         message,
 	})
 }
 
@@ -848,85 +1127,30 @@ func (handler *RequestHandler) GetPendingTokenPurchases(w http.ResponseWriter, r
 }
 
 // ApproveTokenPurchase handles POST /properties/{id}/purchases/{purchaseId}/approve
-// Owner approves purchase and transfers tokens, then updates the purchase record
+// Owner accepts a paid purchase, the response carries what the frontend needs to transfer the tokens
 func (handler *RequestHandler) ApproveTokenPurchase(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	purchaseId := chi.URLParam(r, "purchaseId")
-
-	// Get property
-	prop, err := handler.db.GetPropertyByID(id)
-	if err != nil {
-		http.Error(w, "Property not found", http.StatusNotFound)
-		return
-	}
-
-	// Get authenticated user
-	claims, ok := r.Context().Value(auth.ClaimsKey).(*auth.Claims)
+	prop, purchase, _, ok := handler.ownerPurchaseTarget(w, r)
 	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	// Get user to check if they're the owner
-	user, err := handler.db.GetUserById(claims.UserID.String())
+	// Parse amount (convert from token units to wei), exactly what UpdateTokenPurchaseTxHash expects in the Transfer log
+	amountBig, err := parseUnits(purchase.Amount, 18)
 	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
-		return
-	}
-
-	// Verify user is the property owner
-	if user.WalletAddress == "" || user.WalletAddress != prop.OwnerWallet {
-		http.Error(w, "Forbidden: Only the property owner can approve purchases", http.StatusForbidden)
-		return
-	}
-
-	// Get the purchase record
-	purchases, err := handler.db.GetPendingTokenPurchasesByProperty(id)
-	if err != nil {
-		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	var purchase *models.TokenPurchase
-	for i := range purchases {
-		if purchases[i].ID.String() == purchaseId {
-			purchase = &purchases[i]
-			break
-		}
-	}
-
-	if purchase == nil {
-		http.Error(w, "Purchase not found or already approved", http.StatusNotFound)
-		return
-	}
-
-	if handler.chain == nil {
-		http.Error(w, "Blockchain service not available", http.StatusServiceUnavailable)
+		http.Error(w, "Invalid amount format", http.StatusBadRequest)
 		return
 	}
 
-	// Parse amount (convert from token units to wei)
-	amountFloat, _, err := big.ParseFloat(purchase.Amount, 10, 256, big.ToNearestEven)
-	if err != nil {
-		http.Error(w, "Invalid amount format", http.StatusBadRequest)
+	if !handler.transitionPurchase(w, purchase, models.PurchaseApproved, nil) {
 		return
 	}
 
-	weiMultiplier := big.NewFloat(1000000000000000000) // 10^18
-	amountFloat.Mul(amountFloat, weiMultiplier)
-	amountBig, _ := amountFloat.Int(nil)
-
-	// Transfer tokens from owner to buyer
-	// Note: This requires the owner's wallet to have tokens and be connected
-	// The frontend will handle the actual transfer using the owner's signer
-	// This endpoint just updates the database after the frontend confirms the transfer
-
-	// For now, we'll return the purchase details and let the frontend handle the transfer
-	// Then the frontend will call back to update the token_tx_hash
+	// The frontend transfers the tokens with the owner's signer,
+	// then calls update-tx so the transfer can be verified and the purchase completed
 	render.JSON(w, r, map[string]interface{}{
-		"status":      "ready",
-		"purchase":    purchase,
-		"message":     "Purchase ready for approval. Frontend should transfer tokens and then update the record.",
+		"status":        "ready",
+		"purchase":      purchase,
+		"message":       "Purchase approved. Frontend should transfer tokens and then update the record.",
 		"token_address": prop.OnchainTokenAddress,
 		"buyer_address": purchase.BuyerWallet,
 		"amount_wei":    amountBig.String(),
@@ -935,12 +1159,10 @@ func (handler *RequestHandler) ApproveTokenPurchase(w http.ResponseWriter, r *ht
 
 // UpdateTokenPurchaseTxHash handles POST /properties/{id}/purchases/{purchaseId}/update-tx
 // Updates the token transfer transaction hash after owner approves and transfers tokens
+// The transaction must emit a confirmed Transfer of the purchased amount from the owner to the buyer
 func (handler *RequestHandler) UpdateTokenPurchaseTxHash(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	purchaseId := chi.URLParam(r, "purchaseId")
-
 	type UpdateRequest struct {
-		TokenTxHash string `json:"token_tx_hash" validate:"required"`
+		TokenTxHash string `json:"token_tx_hash" validate:"required,len=66,hexadecimal"`
 	}
 
 	var req UpdateRequest
@@ -954,41 +1176,43 @@ func (handler *RequestHandler) UpdateTokenPurchaseTxHash(w http.ResponseWriter,
 		return
 	}
 
-	// Get property
-	prop, err := handler.db.GetPropertyByID(id)
-	if err != nil {
-		http.Error(w, "Property not found", http.StatusNotFound)
+	prop, purchase, _, ok := handler.ownerPurchaseTarget(w, r)
+	if !ok {
+		return
+	}
+	if purchase.Status != models.PurchaseApproved {
+		http.Error(w, "Purchase is "+string(purchase.Status)+", only approved purchases can be transferred", http.StatusConflict)
 		return
 	}
 
-	// Get authenticated user
-	claims, ok := r.Context().Value(auth.ClaimsKey).(*auth.Claims)
-	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	if handler.chain == nil {
+		http.Error(w, "Blockchain service not available", http.StatusServiceUnavailable)
 		return
 	}
 
-	// Get user to check if they're the owner
-	user, err := handler.db.GetUserById(claims.UserID.String())
+	// the transfer of a trade between the same wallets must not complete a purchase too
+	if handler.txHashUsed(w, req.TokenTxHash) {
+		return
+	}
+
+	amountWei, err := parseUnits(purchase.Amount, 18)
 	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+		http.Error(w, "Invalid amount format", http.StatusInternalServerError)
 		return
 	}
 
-	// Verify user is the property owner
-	if user.WalletAddress == "" || user.WalletAddress != prop.OwnerWallet {
-		http.Error(w, "Forbidden: Only the property owner can update purchases", http.StatusForbidden)
+	// the purchase is only complete once the token moved from the owner to the buyer on-chain
+	if _, err := handler.chain.VerifyTokenTransfer(r.Context(), req.TokenTxHash, prop.OnchainTokenAddress, prop.OwnerWallet, purchase.BuyerWallet, amountWei); err != nil {
+		log.Printf("Warning: Token transfer %s for purchase %s not accepted: %v", req.TokenTxHash, purchase.ID, err)
+		writeSettlementError(w, "Token transfer", err)
 		return
 	}
 
-	// Update the purchase record
-	if err := handler.db.UpdateTokenPurchaseTxHash(purchaseId, req.TokenTxHash); err != nil {
-		log.Printf("Failed to update token purchase: %v", err)
-		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+	if !handler.transitionPurchase(w, purchase, models.PurchaseTransferred, map[string]any{"token_tx_hash": req.TokenTxHash}) {
 		return
 	}
 
-	log.Printf("Token purchase updated: PurchaseID=%s, TokenTX=%s", purchaseId, req.TokenTxHash)
+	log.Printf("Token purchase updated: PurchaseID=%s, TokenTX=%s", purchase.ID, req.TokenTxHash)
 
 	render.JSON(w, r, map[string]interface{}{
 		"status":  "success",
@@ -0,0 +1,565 @@
+package api
+
+import (
+	"backend/auth"
+	"backend/blockchain"
+	"backend/db/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// createPropertyJob - input of a create_property job
+// files are already on IPFS when the job is queued, only the chain work is deferred
+type createPropertyJob struct {
+	Property     PropertyPayload           `json:"property"`
+	MetadataHash string                    `json:"metadata_hash"`
+	Documents    []models.PropertyDocument `json:"documents"`
+}
+
+// approveUploadRequestJob - input of an approve_upload_request job
+type approveUploadRequestJob struct {
+	RequestID string `json:"request_id"`
+}
+
+// approveUserJob - input of an approve_user job
+type approveUserJob struct {
+	WalletAddress string `json:"wallet_address"`
+}
+
+// rejectUserJob - input of a reject_user job
+type rejectUserJob struct {
+	WalletAddress string `json:"wallet_address"`
+}
+
+// revokeUserJob - input of a revoke_user job
+type revokeUserJob struct {
+	WalletAddress string `json:"wallet_address"`
+}
+
+// jobPermissions - permission that lets staff other than the creator read a job
+var jobPermissions = map[models.JobType]Permission{
+	models.JobCreateProperty:       PermManageProperties,
+	models.JobApproveUploadRequest: PermManageProperties,
+	models.JobApproveUser:          PermApproveUsers,
+	models.JobRejectUser:           PermApproveUsers,
+	models.JobRevokeUser:           PermApproveUsers,
+}
+
+// JobResponse - job state returned by the API
+type JobResponse struct {
+	models.Job
+	Result map[string]any `json:"result,omitempty"`
+}
+
+func newJobResponse(job models.Job) JobResponse {
+	response := JobResponse{Job: job}
+	if job.Result != "" {
+		if err := json.Unmarshal([]byte(job.Result), &response.Result); err != nil {
+			log.Printf("Warning: Job %s has an invalid result: %v", job.ID, err)
+		}
+	}
+	return response
+}
+
+// walletStatusJobs - jobs keyed by wallet by default; a wallet can be approved, revoked and approved again,
+// so their default key only dedupes while the job is queued or running
+var walletStatusJobs = map[models.JobType]bool{
+	models.JobApproveUser: true,
+	models.JobRejectUser:  true,
+	models.JobRevokeUser:  true,
+}
+
+// enqueueJob - queue a job and answer 202 with its ID
+// the Idempotency-Key header overrides defaultKey, a repeated key returns the existing job instead of queueing another
+func (handler *RequestHandler) enqueueJob(w http.ResponseWriter, r *http.Request, jobType models.JobType, defaultKey string, payload any) {
+	claims, ok := r.Context().Value(auth.ClaimsKey).(*auth.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	key, activeOnly := defaultKey, walletStatusJobs[jobType]
+	if header := r.Header.Get("Idempotency-Key"); header != "" {
+		if len(header) > 200 {
+			http.Error(w, "Idempotency-Key too long", http.StatusBadRequest)
+			return
+		}
+		key, activeOnly = header, false
+	}
+
+	job, created, err := handler.queueJob(claims.UserID, jobType, key, activeOnly, payload)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJobAccepted(w, r, job, created)
+}
+
+// queueJob - queue a job, or return the existing one when the idempotency key was used before
+// with activeOnly the key is free again once the job with it succeeded
+func (handler *RequestHandler) queueJob(createdBy uuid.UUID, jobType models.JobType, key string, activeOnly bool, payload any) (models.Job, bool, error) {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return models.Job{}, false, fmt.Errorf("failed to encode job: %w", err)
+	}
+
+	job, created, err := handler.db.EnqueueJob(models.Job{
+		ID:             uuid.New(),
+		Type:           jobType,
+		Status:         models.JobQueued,
+		IdempotencyKey: string(jobType) + ":" + key,
+		ActiveKeyOnly:  activeOnly,
+		CreatedBy:      createdBy,
+		Payload:        string(payloadJSON),
+		MaxAttempts:    int(envInt("JOB_MAX_ATTEMPTS", 5)),
+		RunAfter:       time.Now(),
+	})
+	if err != nil {
+		log.Printf("Failed to enqueue %s job: %v", jobType, err)
+		return job, false, err
+	}
+
+	if created {
+		log.Printf("Info: Queued %s job %s", jobType, job.ID)
+	} else {
+		log.Printf("Info: Reusing %s job %s for idempotency key %q", jobType, job.ID, key)
+	}
+	return job, created, nil
+}
+
+// writeJobAccepted - 202 pointing the client at the job's status URL
+func writeJobAccepted(w http.ResponseWriter, r *http.Request, job models.Job, created bool) {
+	w.Header().Set("Location", "/jobs/"+job.ID.String())
+	render.Status(r, http.StatusAccepted)
+	render.JSON(w, r, map[string]any{
+		"job_id":     job.ID.String(),
+		"status":     job.Status,
+		"status_url": "/jobs/" + job.ID.String(),
+		"duplicate":  !created,
+	})
+}
+
+// GetJob handles GET /jobs/{id}
+// Returns status, tx hash and result of a job, visible to the user who queued it and to admins
+func (handler *RequestHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(auth.ClaimsKey).(*auth.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		http.Error(w, "Invalid job id", http.StatusBadRequest)
+		return
+	}
+
+	job, err := handler.db.GetJobByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// other staff with the permission the job needs may follow it too
+	if job.CreatedBy != claims.UserID {
+		allowed, err := handler.claimsHavePermission(claims, jobPermissions[job.Type])
+		if err != nil || !allowed {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	render.JSON(w, r, newJobResponse(job))
+}
+
+// --- Job workers ---
+
+// startJobWorkers - run JOB_WORKERS workers that execute queued jobs until ctx is cancelled
+// JOB_POLL_INTERVAL (seconds) and JOB_LEASE (seconds, must outlast TX_WAIT_TIMEOUT) tune them
+func (handler *RequestHandler) startJobWorkers(ctx context.Context) {
+	workers := int(envInt("JOB_WORKERS", 2))
+	pollInterval := time.Duration(envInt("JOB_POLL_INTERVAL", 2)) * time.Second
+	lease := time.Duration(envInt("JOB_LEASE", 600)) * time.Second
+	if lease < time.Minute {
+		lease = time.Minute
+	}
+
+	for i := 0; i < workers; i++ {
+		go func(worker int) {
+			for {
+				job, found, err := handler.db.ClaimJob(lease)
+				if err != nil {
+					log.Printf("Error: Job worker %d failed to claim a job: %v", worker, err)
+				}
+				if found {
+					handler.runJob(ctx, job, lease)
+					continue
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(pollInterval):
+				}
+			}
+		}(i)
+	}
+	log.Printf("Info: Started %d job worker(s)", workers)
+}
+
+// runJob - execute one claimed job and record the outcome
+// reverts and invalid input fail the job, anything else is retried with backoff
+func (handler *RequestHandler) runJob(ctx context.Context, job models.Job, lease time.Duration) {
+	// stop before the lease runs out so another worker never runs the job concurrently
+	ctx, cancel := context.WithTimeout(ctx, lease-30*time.Second)
+	defer cancel()
+
+	log.Printf("Info: Running %s job %s (attempt %d/%d)", job.Type, job.ID, job.Attempts, job.MaxAttempts)
+
+	var (
+		result     map[string]any
+		propertyID *uuid.UUID
+		err        error
+	)
+	switch job.Type {
+	case models.JobCreateProperty:
+		result, propertyID, err = handler.runCreatePropertyJob(ctx, job)
+	case models.JobApproveUploadRequest:
+		result, propertyID, err = handler.runApproveUploadRequestJob(ctx, job)
+	case models.JobApproveUser:
+		result, err = handler.runApproveUserJob(ctx, job)
+	case models.JobRejectUser:
+		result, err = handler.runRejectUserJob(ctx, job)
+	case models.JobRevokeUser:
+		result, err = handler.runRevokeUserJob(ctx, job)
+	default:
+		err = permanentJobError{fmt.Errorf("unknown job type %q", job.Type)}
+	}
+
+	if err == nil {
+		resultJSON, _ := json.Marshal(result)
+		if err := handler.db.CompleteJob(job.ID, string(resultJSON), propertyID); err != nil {
+			log.Printf("Error: Failed to complete job %s: %v", job.ID, err)
+			return
+		}
+		log.Printf("Success: Job %s (%s) succeeded", job.ID, job.Type)
+		return
+	}
+
+	var txErr *blockchain.TxError
+	var permanent permanentJobError
+	switch {
+	case errors.As(err, &txErr) && txErr.Kind == blockchain.TxReverted, errors.As(err, &permanent):
+		log.Printf("Error: Job %s (%s) failed: %v", job.ID, job.Type, err)
+		if dbErr := handler.db.FailJob(job.ID, err.Error()); dbErr != nil {
+			log.Printf("Error: Failed to mark job %s failed: %v", job.ID, dbErr)
+		}
+	case job.Attempts >= job.MaxAttempts && !handler.jobHasPendingTx(job.ID):
+		log.Printf("Error: Job %s (%s) failed after %d attempts: %v", job.ID, job.Type, job.Attempts, err)
+		if dbErr := handler.db.FailJob(job.ID, err.Error()); dbErr != nil {
+			log.Printf("Error: Failed to mark job %s failed: %v", job.ID, dbErr)
+		}
+	default:
+		// a sent transaction is never abandoned, the job keeps waiting for it
+		backoff := time.Duration(job.Attempts*job.Attempts) * 5 * time.Second
+		if backoff > 5*time.Minute {
+			backoff = 5 * time.Minute
+		}
+		log.Printf("Warning: Job %s (%s) attempt %d failed, retrying in %s: %v", job.ID, job.Type, job.Attempts, backoff, err)
+		if dbErr := handler.db.RetryJob(job.ID, err.Error(), time.Now().Add(backoff)); dbErr != nil {
+			log.Printf("Error: Failed to requeue job %s: %v", job.ID, dbErr)
+		}
+	}
+}
+
+// permanentJobError - error that retrying won't fix
+type permanentJobError struct{ error }
+
+func (e permanentJobError) Unwrap() error { return e.error }
+
+// jobHasPendingTx - whether the job sent a transaction that may still be mined
+func (handler *RequestHandler) jobHasPendingTx(id uuid.UUID) bool {
+	job, err := handler.db.GetJobByID(id.String())
+	return err != nil || job.TxHash != ""
+}
+
+// sendJobTx - send the job's transaction once
+// if a previous attempt already sent one it is reused, unless the node has forgotten it (dropped)
+// the hash is stored before returning, so a crash while waiting can't lead to a second send
+func (handler *RequestHandler) sendJobTx(ctx context.Context, job *models.Job, submit func() (*types.Transaction, error)) (common.Hash, error) {
+	if job.TxHash != "" {
+		// follow fee-bump replacements recorded in the transaction log
+		current := job.TxHash
+		if tx, err := handler.db.GetCurrentTransaction(job.TxHash); err == nil {
+			current = tx.TxHash
+		}
+		hash := common.HexToHash(current)
+
+		_, _, err := handler.chain.Client.TransactionByHash(ctx, hash)
+		if err == nil {
+			log.Printf("Info: Job %s resuming wait for %s", job.ID, current)
+			return hash, nil
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			return common.Hash{}, err
+		}
+		log.Printf("Warning: Job %s transaction %s was dropped, sending again", job.ID, current)
+	}
+
+	tx, err := submit()
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	job.TxHash = tx.Hash().Hex()
+	if err := handler.db.SetJobTxHash(job.ID, job.TxHash); err != nil {
+		log.Printf("Error: Failed to store tx hash %s for job %s: %v", job.TxHash, job.ID, err)
+	}
+	return tx.Hash(), nil
+}
+
+func (handler *RequestHandler) runCreatePropertyJob(ctx context.Context, job models.Job) (map[string]any, *uuid.UUID, error) {
+	var input createPropertyJob
+	if err := json.Unmarshal([]byte(job.Payload), &input); err != nil {
+		return nil, nil, permanentJobError{err}
+	}
+	if handler.chain == nil {
+		return nil, nil, errors.New("blockchain service not available")
+	}
+
+	p := input.Property
+	hash, err := handler.sendJobTx(ctx, &job, func() (*types.Transaction, error) {
+		return handler.chain.SubmitCreateProperty(ctx, p.OwnerAddress, p.Name, p.Symbol, input.MetadataHash, p.Valuation, p.TokenSupply)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result, err := handler.chain.WaitPropertyCreated(ctx, hash, p.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	property, err := handler.saveCreatedProperty(result, p.OwnerAddress, input.MetadataHash, float64(p.Valuation), input.Documents)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return map[string]any{
+		"tx_hash":       result.TxHash,
+		"property_id":   property.ID.String(),
+		"asset_address": result.AssetAddress,
+		"token_address": result.TokenAddress,
+		"files_count":   len(input.Documents),
+	}, &property.ID, nil
+}
+
+func (handler *RequestHandler) runApproveUploadRequestJob(ctx context.Context, job models.Job) (map[string]any, *uuid.UUID, error) {
+	var input approveUploadRequestJob
+	if err := json.Unmarshal([]byte(job.Payload), &input); err != nil {
+		return nil, nil, permanentJobError{err}
+	}
+	if handler.chain == nil {
+		return nil, nil, errors.New("blockchain service not available")
+	}
+
+	request, err := handler.db.GetPropertyUploadRequestByID(input.RequestID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, permanentJobError{fmt.Errorf("request %s not found", input.RequestID)}
+		}
+		return nil, nil, err
+	}
+	// an approved request is only expected when this job's own earlier attempt got that far
+	if request.Status != models.ApprovalPending && (request.Status != models.ApprovalApproved || job.TxHash == "") {
+		return nil, nil, permanentJobError{fmt.Errorf("request already %s", request.Status)}
+	}
+
+	hash, err := handler.sendJobTx(ctx, &job, func() (*types.Transaction, error) {
+		return handler.chain.SubmitCreateProperty(ctx, request.WalletAddress, request.Name, request.Symbol,
+			request.MetadataHash, int64(request.Valuation), request.TokenSupply)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result, err := handler.chain.WaitPropertyCreated(ctx, hash, request.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	property, err := handler.saveCreatedProperty(result, request.WalletAddress, request.MetadataHash, request.Valuation, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := handler.db.UpdatePropertyUploadRequestStatus(input.RequestID, models.ApprovalApproved, ""); err != nil {
+		return nil, nil, err
+	}
+
+	return map[string]any{
+		"request_id":    input.RequestID,
+		"tx_hash":       result.TxHash,
+		"property_id":   property.ID.String(),
+		"asset_address": result.AssetAddress,
+		"token_address": result.TokenAddress,
+	}, &property.ID, nil
+}
+
+func (handler *RequestHandler) runApproveUserJob(ctx context.Context, job models.Job) (map[string]any, error) {
+	var input approveUserJob
+	if err := json.Unmarshal([]byte(job.Payload), &input); err != nil {
+		return nil, permanentJobError{err}
+	}
+	if handler.chain == nil || handler.chain.Approval == nil {
+		return nil, errors.New("approval contract not available")
+	}
+
+	hash, err := handler.sendJobTx(ctx, &job, func() (*types.Transaction, error) {
+		return handler.chain.SubmitApproveUser(ctx, input.WalletAddress)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := handler.chain.WaitForTx(ctx, hash); err != nil {
+		return nil, err
+	}
+
+	if err := handler.db.UpdateUserApproval(input.WalletAddress, models.ApprovalApproved); err != nil {
+		log.Printf("Warning: Failed to update approval for %s, the indexer will catch up: %v", input.WalletAddress, err)
+	}
+
+	approved, err := handler.chain.IsApproved(input.WalletAddress)
+	if err != nil {
+		log.Printf("Warning: Could not verify approval status: %v", err)
+	}
+
+	return map[string]any{
+		"tx_hash":        hash.Hex(),
+		"wallet_address": input.WalletAddress,
+		"approved":       approved,
+	}, nil
+}
+
+// runRejectUserJob - call ApprovalService.rejectUser for a wallet and mark the user rejected
+func (handler *RequestHandler) runRejectUserJob(ctx context.Context, job models.Job) (map[string]any, error) {
+	var input rejectUserJob
+	if err := json.Unmarshal([]byte(job.Payload), &input); err != nil {
+		return nil, permanentJobError{err}
+	}
+	return handler.withdrawApproval(ctx, job, input.WalletAddress, models.ApprovalRejected, handler.chain.SubmitRejectUser)
+}
+
+// runRevokeUserJob - call ApprovalService.revoke for a wallet and mark the user revoked
+func (handler *RequestHandler) runRevokeUserJob(ctx context.Context, job models.Job) (map[string]any, error) {
+	var input revokeUserJob
+	if err := json.Unmarshal([]byte(job.Payload), &input); err != nil {
+		return nil, permanentJobError{err}
+	}
+	return handler.withdrawApproval(ctx, job, input.WalletAddress, models.ApprovalRevoked, handler.chain.SubmitRevokeUser)
+}
+
+// withdrawApproval - send a transaction clearing a wallet's approval, wait for it and record the new status
+func (handler *RequestHandler) withdrawApproval(ctx context.Context, job models.Job, wallet string, status models.ApprovalStatus, submit func(context.Context, string) (*types.Transaction, error)) (map[string]any, error) {
+	if handler.chain == nil || handler.chain.Approval == nil {
+		return nil, errors.New("approval contract not available")
+	}
+
+	hash, err := handler.sendJobTx(ctx, &job, func() (*types.Transaction, error) {
+		return submit(ctx, wallet)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := handler.chain.WaitForTx(ctx, hash); err != nil {
+		return nil, err
+	}
+
+	if err := handler.db.UpdateUserApproval(wallet, status); err != nil {
+		log.Printf("Warning: Failed to update approval for %s, the indexer will catch up: %v", wallet, err)
+	}
+
+	return map[string]any{
+		"tx_hash":        hash.Hex(),
+		"wallet_address": wallet,
+		"status":         status,
+	}, nil
+}
+
+// saveCreatedProperty - insert the property row for a confirmed creation, or return the existing one
+// safe to call again when a job is retried after the insert, or when the indexer recorded the property first
+func (handler *RequestHandler) saveCreatedProperty(result *blockchain.PropertyCreationResult, owner, metadataHash string, valuation float64, documents []models.PropertyDocument) (models.Property, error) {
+	property, created, err := handler.db.CreatePropertyIfMissing(models.Property{
+		ID:                  uuid.New(),
+		Name:                result.PropertyName,
+		OnchainAssetAddress: result.AssetAddress,
+		OnchainTokenAddress: result.TokenAddress,
+		OwnerWallet:         owner,
+		MetadataHash:        metadataHash,
+		Valuation:           valuation,
+		Status:              models.StatusActive,
+		TxHash:              result.TxHash,
+		CreatedAt:           time.Now(),
+	})
+	if err != nil {
+		return property, err
+	}
+	if created {
+		log.Printf("Info: Property saved to database - ID: %s", property.ID)
+	}
+
+	if len(documents) == 0 {
+		return property, nil
+	}
+	// documents are linked once, whoever created the row
+	count, err := handler.db.CountPropertyDocuments(property.ID)
+	if err != nil {
+		return property, err
+	}
+	if count > 0 {
+		return property, nil
+	}
+
+	// Link documents to property
+	for i := range documents {
+		documents[i].PropertyID = property.ID
+		if err := handler.db.CreatePropertyDocument(documents[i]); err != nil {
+			log.Printf("Warning: Failed to save document %d: %v", i, err)
+		}
+	}
+	return property, nil
+}
+
+func envInt(name string, def int64) int64 {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n <= 0 {
+		log.Printf("Warning: Invalid %s %q, using default %d", name, v, def)
+		return def
+	}
+	return n
+}
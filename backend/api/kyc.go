@@ -0,0 +1,432 @@
+package api
+
+import (
+	"backend/auth"
+	"backend/db/models"
+	"backend/ipfs"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// kycDocumentTypes - multipart fields a submission's files are read from, the first two are required
+var kycDocumentTypes = []string{"identity_document", "proof_of_address", "other"}
+
+// kycRequiredChecks - checks that must have passed before a submission can be approved
+var kycRequiredChecks = []string{"identity_document", "proof_of_address", "sanctions_screening"}
+
+type kycSubmissionPayload struct {
+	LegalName   string `json:"legal_name" validate:"required,max=255"`
+	DateOfBirth string `json:"date_of_birth" validate:"required,datetime=2006-01-02"`
+	Country     string `json:"country" validate:"required,iso3166_1_alpha2"`
+}
+
+type kycCheckRequest struct {
+	Name   string `json:"name" validate:"required,oneof=identity_document proof_of_address sanctions_screening"`
+	Passed bool   `json:"passed"`
+	Notes  string `json:"notes" validate:"max=2000"`
+}
+
+type kycCompleteRequest struct {
+	Decision string `json:"decision" validate:"required,oneof=approve reject"`
+	Notes    string `json:"notes" validate:"required_if=Decision reject,max=2000"`
+}
+
+// SubmitKYC handles POST /users/me/kyc
+// Multipart form: "data" holds the JSON payload, files go under identity_document, proof_of_address and other
+func (handler *RequestHandler) SubmitKYC(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(auth.ClaimsKey).(*auth.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := handler.db.GetUserById(claims.UserID.String())
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if user.ApprovalStatus == models.ApprovalApproved {
+		http.Error(w, "Wallet is already approved", http.StatusConflict)
+		return
+	}
+
+	// a new submission is only taken after the previous one was rejected
+	latest, err := handler.db.GetLatestKYCSubmission(user.ID)
+	if err == nil && latest.Status != models.KYCRejected {
+		http.Error(w, "A KYC submission is already "+string(latest.Status), http.StatusConflict)
+		return
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := r.ParseMultipartForm(20 << 20); err != nil {
+		http.Error(w, "File too large or invalid format", http.StatusBadRequest)
+		return
+	}
+	var payload kycSubmissionPayload
+	if err := json.Unmarshal([]byte(r.FormValue("data")), &payload); err != nil {
+		http.Error(w, "Invalid 'data' field", http.StatusBadRequest)
+		return
+	}
+	payload.Country = strings.ToUpper(payload.Country)
+	if err := validate.Struct(payload); err != nil {
+		http.Error(w, "Validation Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, docType := range kycDocumentTypes[:2] {
+		if len(r.MultipartForm.File[docType]) == 0 {
+			http.Error(w, docType+" file is required", http.StatusBadRequest)
+			return
+		}
+	}
+
+	submissionID := uuid.New()
+	var documents []models.KYCDocument
+	for _, docType := range kycDocumentTypes {
+		for _, fileHeader := range r.MultipartForm.File[docType] {
+			doc, err := uploadKYCDocument(fileHeader, docType)
+			if err != nil {
+				log.Printf("Error: KYC upload for user %s failed: %v", user.ID, err)
+				http.Error(w, "Failed to upload documents: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			doc.SubmissionID = submissionID
+			documents = append(documents, doc)
+		}
+	}
+
+	submission := models.KYCSubmission{
+		ID:            submissionID,
+		UserID:        user.ID,
+		WalletAddress: user.WalletAddress,
+		LegalName:     strings.TrimSpace(payload.LegalName),
+		DateOfBirth:   payload.DateOfBirth,
+		Country:       payload.Country,
+		Status:        models.KYCSubmitted,
+		Documents:     documents,
+	}
+	if err := handler.db.CreateKYCSubmission(&submission); err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Info: KYC submission %s created for user %s", submission.ID, user.ID)
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, submission)
+}
+
+// GetMyKYC handles GET /users/me/kyc
+// Returns the user's latest submission with its status history
+func (handler *RequestHandler) GetMyKYC(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(auth.ClaimsKey).(*auth.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	submission, err := handler.db.GetLatestKYCSubmission(claims.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "No KYC submission", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// the reviewer's checks are internal, the decision note is in the submission and history
+	submission.Checks = nil
+	render.JSON(w, r, submission)
+}
+
+// GetKYCSubmissions handles GET /kyc?status= (reviewers)
+// Lists submissions oldest first, without documents
+func (handler *RequestHandler) GetKYCSubmissions(w http.ResponseWriter, r *http.Request) {
+	status := models.KYCStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", models.KYCSubmitted, models.KYCInReview, models.KYCApproved, models.KYCRejected:
+	default:
+		http.Error(w, "Invalid status", http.StatusBadRequest)
+		return
+	}
+
+	submissions, err := handler.db.GetKYCSubmissions(status)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if submissions == nil {
+		submissions = []models.KYCSubmission{}
+	}
+	render.JSON(w, r, submissions)
+}
+
+// GetKYCSubmission handles GET /kyc/{id} (reviewers)
+// Returns a submission with documents, checks and history
+func (handler *RequestHandler) GetKYCSubmission(w http.ResponseWriter, r *http.Request) {
+	submission, ok := handler.kycTarget(w, r)
+	if !ok {
+		return
+	}
+	render.JSON(w, r, submission)
+}
+
+// StartKYCReview handles POST /kyc/{id}/review (reviewers)
+// Moves a submission into review, the reviewer is recorded and checks can be added from then on
+func (handler *RequestHandler) StartKYCReview(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(auth.ClaimsKey).(*auth.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	submission, ok := handler.kycTarget(w, r)
+	if !ok {
+		return
+	}
+	if submission.UserID == claims.UserID {
+		http.Error(w, "Forbidden: you can't review your own submission", http.StatusForbidden)
+		return
+	}
+
+	updated, err := handler.db.UpdateKYCStatus(submission.ID, models.KYCSubmitted, models.KYCInReview, claims.UserID, "", map[string]any{
+		"reviewer_id": claims.UserID,
+	})
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !updated {
+		http.Error(w, "Submission is not waiting for review", http.StatusConflict)
+		return
+	}
+
+	log.Printf("Info: KYC submission %s in review by %s", submission.ID, claims.UserID)
+	render.JSON(w, r, map[string]any{"id": submission.ID, "status": models.KYCInReview})
+}
+
+// AddKYCCheck handles POST /kyc/{id}/checks (reviewers)
+// Records the result of one check, a later result for the same check replaces the earlier one
+func (handler *RequestHandler) AddKYCCheck(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(auth.ClaimsKey).(*auth.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req kycCheckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		http.Error(w, "Validation Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	submission, ok := handler.kycTarget(w, r)
+	if !ok {
+		return
+	}
+	if submission.Status != models.KYCInReview {
+		http.Error(w, "Checks can only be recorded while the submission is in review", http.StatusConflict)
+		return
+	}
+	if submission.UserID == claims.UserID {
+		http.Error(w, "Forbidden: you can't review your own submission", http.StatusForbidden)
+		return
+	}
+
+	check := models.KYCCheck{
+		ID:           uuid.New(),
+		SubmissionID: submission.ID,
+		Name:         req.Name,
+		Passed:       req.Passed,
+		Notes:        req.Notes,
+		ReviewerID:   claims.UserID,
+	}
+	if err := handler.db.AddKYCCheck(check); err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, check)
+}
+
+// CompleteKYCReview handles POST /kyc/{id}/complete (reviewers)
+// Approving requires every required check to have passed, rejecting requires notes
+// The decision is carried on-chain by an approve_user or reject_user job, the response points at it
+func (handler *RequestHandler) CompleteKYCReview(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(auth.ClaimsKey).(*auth.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req kycCompleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		http.Error(w, "Validation Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	submission, ok := handler.kycTarget(w, r)
+	if !ok {
+		return
+	}
+	if submission.UserID == claims.UserID {
+		http.Error(w, "Forbidden: you can't review your own submission", http.StatusForbidden)
+		return
+	}
+
+	var payload any = approveUserJob{WalletAddress: submission.WalletAddress}
+	status, jobType := models.KYCApproved, models.JobApproveUser
+	if req.Decision == "reject" {
+		payload = rejectUserJob{WalletAddress: submission.WalletAddress}
+		status, jobType = models.KYCRejected, models.JobRejectUser
+	}
+
+	switch submission.Status {
+	case models.KYCInReview:
+	case status:
+		// repeated request, answer with the job queued the first time
+		if submission.JobID != nil {
+			if job, err := handler.db.GetJobByID(submission.JobID.String()); err == nil {
+				writeJobAccepted(w, r, job, false)
+				return
+			}
+		}
+	default:
+		http.Error(w, "Submission is "+string(submission.Status)+", start the review first", http.StatusConflict)
+		return
+	}
+
+	if submission.Status == models.KYCInReview {
+		if status == models.KYCApproved {
+			if missing := failedKYCChecks(submission.Checks); len(missing) > 0 {
+				http.Error(w, "Checks not passed: "+strings.Join(missing, ", "), http.StatusConflict)
+				return
+			}
+		}
+
+		if handler.chain == nil || handler.chain.Approval == nil {
+			http.Error(w, "Blockchain Error: approval contract not deployed", http.StatusServiceUnavailable)
+			return
+		}
+
+		updated, err := handler.db.UpdateKYCStatus(submission.ID, models.KYCInReview, status, claims.UserID, req.Notes, map[string]any{
+			"reviewed_at": time.Now(),
+			"decision":    req.Notes,
+		})
+		if err != nil {
+			http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !updated {
+			http.Error(w, "Submission changed, reload and try again", http.StatusConflict)
+			return
+		}
+		log.Printf("Info: KYC submission %s %s by %s", submission.ID, status, claims.UserID)
+	}
+
+	job, created, err := handler.queueJob(claims.UserID, jobType, "kyc:"+submission.ID.String(), false, payload)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := handler.db.SetKYCJob(submission.ID, job.ID); err != nil {
+		log.Printf("Warning: Failed to link KYC submission %s to job %s: %v", submission.ID, job.ID, err)
+	}
+	writeJobAccepted(w, r, job, created)
+}
+
+// kycApproved - whether the wallet's latest KYC submission was approved, writes a 409 if not
+func (handler *RequestHandler) kycApproved(w http.ResponseWriter, wallet string) bool {
+	approved, err := handler.db.IsKYCApproved(wallet)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if !approved {
+		http.Error(w, "Wallet has no approved KYC submission", http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// failedKYCChecks - required checks whose latest result is missing or failed
+func failedKYCChecks(checks []models.KYCCheck) []string {
+	latest := make(map[string]bool)
+	for _, check := range checks { // ordered by creation, later results overwrite
+		latest[check.Name] = check.Passed
+	}
+	var failed []string
+	for _, name := range kycRequiredChecks {
+		if !latest[name] {
+			failed = append(failed, name)
+		}
+	}
+	return failed
+}
+
+// kycTarget - submission named by the {id} URL parameter, writes the error response if there is none
+func (handler *RequestHandler) kycTarget(w http.ResponseWriter, r *http.Request) (models.KYCSubmission, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid submission id", http.StatusBadRequest)
+		return models.KYCSubmission{}, false
+	}
+	submission, err := handler.db.GetKYCSubmission(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Submission not found", http.StatusNotFound)
+			return models.KYCSubmission{}, false
+		}
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return models.KYCSubmission{}, false
+	}
+	return submission, true
+}
+
+// uploadKYCDocument - pin one submitted file to IPFS
+func uploadKYCDocument(fileHeader *multipart.FileHeader, docType string) (models.KYCDocument, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return models.KYCDocument{}, err
+	}
+	defer file.Close()
+
+	ext := filepath.Ext(fileHeader.Filename)
+	rawName := strings.TrimSuffix(fileHeader.Filename, ext)
+	uniqueName := fmt.Sprintf("kyc_%d_%s%s", time.Now().UnixNano(), rawName, ext)
+
+	hash, err := ipfs.Upload(file, uniqueName)
+	if err != nil {
+		return models.KYCDocument{}, err
+	}
+
+	return models.KYCDocument{
+		ID:         uuid.New(),
+		Type:       docType,
+		Name:       uniqueName,
+		FileHash:   hash,
+		FileUrl:    "https://gateway.pinata.cloud/ipfs/" + hash,
+		UploadedAt: time.Now(),
+	}, nil
+}
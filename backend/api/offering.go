@@ -0,0 +1,330 @@
+package api
+
+import (
+	"backend/auth"
+	"backend/db"
+	"backend/db/models"
+	"encoding/json"
+	"errors"
+	"log"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type createOfferingRequest struct {
+	PricePerToken string    `json:"price_per_token" validate:"required,numeric"` // ETH per token
+	StartsAt      time.Time `json:"starts_at" validate:"required"`
+	EndsAt        time.Time `json:"ends_at" validate:"required,gtfield=StartsAt"`
+	MinAllocation string    `json:"min_allocation" validate:"omitempty,numeric"` // Tokens, defaults to no minimum
+	MaxAllocation string    `json:"max_allocation" validate:"omitempty,numeric"` // Tokens per wallet, defaults to no limit
+	HardCap       string    `json:"hard_cap" validate:"required,numeric"`        // Tokens for sale
+}
+
+// offeringView - an offering with its live subscription progress
+type offeringView struct {
+	models.Offering
+	Status               string  `json:"status"`     // upcoming, open, sold_out, ended or closed
+	Subscribed           string  `json:"subscribed"` // tokens held by purchases that weren't declined, cancelled or expired
+	Sold                 string  `json:"sold"`       // tokens already transferred to buyers
+	Remaining            string  `json:"remaining"`  // tokens still available to purchases
+	PercentageSubscribed float64 `json:"percentage_subscribed"`
+	Investors            int64   `json:"investors"`
+}
+
+// offeringLimitError - purchase that doesn't fit an offering, status is the response status for it
+type offeringLimitError struct {
+	status int
+	reason string
+}
+
+func (e *offeringLimitError) Error() string {
+	return e.reason
+}
+
+// CreateOffering handles POST /properties/{id}/offerings (admin)
+// Opens a primary sale of the property's tokens, offerings of one property may not overlap
+func (handler *RequestHandler) CreateOffering(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(auth.ClaimsKey).(*auth.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req createOfferingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid Body", http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		http.Error(w, "Validation Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.MinAllocation == "" {
+		req.MinAllocation = "0"
+	}
+	if req.MaxAllocation == "" {
+		req.MaxAllocation = "0"
+	}
+
+	if _, err := parseUnits(req.PricePerToken, 18); err != nil {
+		http.Error(w, "Validation Error: price_per_token: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	hardCapWei, err := parseUnits(req.HardCap, 18)
+	if err != nil {
+		http.Error(w, "Validation Error: hard_cap: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	minAlloc, ok := db.ParseDecimal(req.MinAllocation)
+	if !ok {
+		http.Error(w, "Validation Error: invalid min_allocation", http.StatusBadRequest)
+		return
+	}
+	maxAlloc, ok := db.ParseDecimal(req.MaxAllocation)
+	if !ok {
+		http.Error(w, "Validation Error: invalid max_allocation", http.StatusBadRequest)
+		return
+	}
+	hardCap, _ := db.ParseDecimal(req.HardCap)
+	switch {
+	case !req.EndsAt.After(time.Now()):
+		http.Error(w, "Validation Error: ends_at must be in the future", http.StatusBadRequest)
+		return
+	case minAlloc.Cmp(hardCap) > 0:
+		http.Error(w, "Validation Error: min_allocation is larger than hard_cap", http.StatusBadRequest)
+		return
+	case maxAlloc.Sign() > 0 && (maxAlloc.Cmp(minAlloc) < 0 || maxAlloc.Cmp(hardCap) > 0):
+		http.Error(w, "Validation Error: max_allocation must be between min_allocation and hard_cap", http.StatusBadRequest)
+		return
+	}
+
+	prop, err := handler.db.GetPropertyByID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Property not found", http.StatusNotFound)
+		return
+	}
+
+	// the owner can't deliver more tokens than the property has
+	if handler.chain != nil && prop.OnchainTokenAddress != "" {
+		supply, err := handler.chain.GetTotalSupply(prop.OnchainTokenAddress)
+		if err != nil {
+			http.Error(w, "Failed to get total supply: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		if hardCapWei.Cmp(supply) > 0 {
+			http.Error(w, "Validation Error: hard_cap is larger than the token supply", http.StatusBadRequest)
+			return
+		}
+	}
+
+	overlaps, err := handler.db.OfferingOverlaps(prop.ID, req.StartsAt, req.EndsAt)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if overlaps {
+		http.Error(w, "Another offering of this property runs during this window", http.StatusConflict)
+		return
+	}
+
+	offering := models.Offering{
+		ID:            uuid.New(),
+		PropertyID:    prop.ID,
+		PricePerToken: req.PricePerToken,
+		StartsAt:      req.StartsAt,
+		EndsAt:        req.EndsAt,
+		MinAllocation: req.MinAllocation,
+		MaxAllocation: req.MaxAllocation,
+		HardCap:       req.HardCap,
+		CreatedBy:     claims.UserID,
+	}
+	if err := handler.db.CreateOffering(&offering); err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Info: Offering %s of %s tokens at %s ETH created for property %s by %s", offering.ID, offering.HardCap, offering.PricePerToken, prop.ID, claims.UserID)
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, newOfferingView(offering, db.OfferingTotals{}, time.Now()))
+}
+
+// GetPropertyOfferings handles GET /properties/{id}/offerings
+// Lists the property's offerings, latest first, with their subscription progress
+func (handler *RequestHandler) GetPropertyOfferings(w http.ResponseWriter, r *http.Request) {
+	prop, err := handler.db.GetPropertyByID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Property not found", http.StatusNotFound)
+		return
+	}
+
+	offerings, err := handler.db.GetOfferingsByProperty(prop.ID)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	views := make([]offeringView, 0, len(offerings))
+	for _, offering := range offerings {
+		totals, err := handler.db.GetOfferingTotals(offering.ID, "")
+		if err != nil {
+			http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		views = append(views, newOfferingView(offering, totals, now))
+	}
+	render.JSON(w, r, views)
+}
+
+// CloseOffering handles POST /properties/{id}/offerings/{offeringId}/close (admin)
+// Ends an offering early, purchases already recorded are settled as usual
+func (handler *RequestHandler) CloseOffering(w http.ResponseWriter, r *http.Request) {
+	offeringID, err := uuid.Parse(chi.URLParam(r, "offeringId"))
+	if err != nil {
+		http.Error(w, "Invalid offering id", http.StatusBadRequest)
+		return
+	}
+	offering, err := handler.db.GetOffering(offeringID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Offering not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if offering.PropertyID.String() != chi.URLParam(r, "id") {
+		http.Error(w, "Offering not found", http.StatusNotFound)
+		return
+	}
+
+	closed, err := handler.db.CloseOffering(offering.ID, time.Now())
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !closed {
+		http.Error(w, "Offering is already closed or over", http.StatusConflict)
+		return
+	}
+
+	log.Printf("Info: Offering %s of property %s closed", offering.ID, offering.PropertyID)
+	render.JSON(w, r, map[string]any{"id": offering.ID, "closed": true})
+}
+
+// purchaseOffering - offering a new purchase of the property reserves its tokens from
+// nil for properties that were never offered, they keep the free-form purchase flow;
+// writes a 409 and returns false if the property has offerings but none is open
+func (handler *RequestHandler) purchaseOffering(w http.ResponseWriter, propertyID uuid.UUID, now time.Time) (*models.Offering, bool) {
+	offering, found, err := handler.db.GetOpenOffering(propertyID, now)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if found {
+		return &offering, true
+	}
+
+	offerings, err := handler.db.GetOfferingsByProperty(propertyID)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if len(offerings) > 0 {
+		http.Error(w, "No offering of this property is open", http.StatusConflict)
+		return nil, false
+	}
+	return nil, true
+}
+
+// offeringPurchaseCheck - checks a purchase of amount tokens paying priceWei against an offering and its totals
+func offeringPurchaseCheck(amount string, priceWei *big.Int, now time.Time) func(models.Offering, db.OfferingTotals) error {
+	return func(offering models.Offering, totals db.OfferingTotals) error {
+		if offering.ClosedAt != nil || now.Before(offering.StartsAt) || !now.Before(offering.EndsAt) {
+			return &offeringLimitError{http.StatusConflict, "offering is not open"}
+		}
+
+		tokens, _ := db.ParseDecimal(amount)
+		price, _ := db.ParseDecimal(offering.PricePerToken)
+		cost := new(big.Rat).Mul(tokens, price)
+		if cost.Cmp(new(big.Rat).SetFrac(priceWei, weiPerEther)) != 0 {
+			return &offeringLimitError{http.StatusBadRequest, "purchase_price must be amount x " + offering.PricePerToken + " ETH = " + db.FormatDecimal(cost) + " ETH"}
+		}
+
+		if minAlloc, _ := db.ParseDecimal(offering.MinAllocation); tokens.Cmp(minAlloc) < 0 {
+			return &offeringLimitError{http.StatusBadRequest, "amount is below the minimum allocation of " + offering.MinAllocation + " tokens"}
+		}
+		if maxAlloc, _ := db.ParseDecimal(offering.MaxAllocation); maxAlloc.Sign() > 0 {
+			held, _ := db.ParseDecimal(totals.Wallet)
+			if left := new(big.Rat).Sub(maxAlloc, held); tokens.Cmp(left) > 0 {
+				return &offeringLimitError{http.StatusConflict, "amount exceeds the wallet's remaining allocation of " + db.FormatDecimal(left) + " tokens"}
+			}
+		}
+
+		hardCap, _ := db.ParseDecimal(offering.HardCap)
+		subscribed, _ := db.ParseDecimal(totals.Subscribed)
+		if left := new(big.Rat).Sub(hardCap, subscribed); tokens.Cmp(left) > 0 {
+			return &offeringLimitError{http.StatusConflict, "amount exceeds the " + db.FormatDecimal(left) + " tokens left in the offering"}
+		}
+		return nil
+	}
+}
+
+// writeOfferingError - response for a purchase the offering refused
+func writeOfferingError(w http.ResponseWriter, err error) {
+	var limitErr *offeringLimitError
+	if errors.As(err, &limitErr) {
+		http.Error(w, "Offering: "+limitErr.reason, limitErr.status)
+		return
+	}
+	if errors.Is(err, db.ErrTxHashUsed) {
+		http.Error(w, "Payment transaction already used for a purchase", http.StatusConflict)
+		return
+	}
+	http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+}
+
+func newOfferingView(offering models.Offering, totals db.OfferingTotals, now time.Time) offeringView {
+	hardCap, _ := db.ParseDecimal(offering.HardCap)
+	subscribed, _ := db.ParseDecimal(totals.Subscribed)
+	sold, _ := db.ParseDecimal(totals.Sold)
+
+	remaining := new(big.Rat).Sub(hardCap, subscribed)
+	if remaining.Sign() < 0 {
+		remaining.SetInt64(0)
+	}
+	var percentage float64
+	if hardCap.Sign() > 0 {
+		percentage, _ = new(big.Rat).Mul(new(big.Rat).Quo(subscribed, hardCap), big.NewRat(100, 1)).Float64()
+	}
+
+	status := "open"
+	switch {
+	case offering.ClosedAt != nil:
+		status = "closed"
+	case now.Before(offering.StartsAt):
+		status = "upcoming"
+	case !now.Before(offering.EndsAt):
+		status = "ended"
+	case remaining.Sign() == 0:
+		status = "sold_out"
+	}
+
+	return offeringView{
+		Offering:             offering,
+		Status:               status,
+		Subscribed:           db.FormatDecimal(subscribed),
+		Sold:                 db.FormatDecimal(sold),
+		Remaining:            db.FormatDecimal(remaining),
+		PercentageSubscribed: percentage,
+		Investors:            totals.Investors,
+	}
+}
+
+var weiPerEther = big.NewInt(1_000_000_000_000_000_000)
@@ -0,0 +1,558 @@
+package api
+
+import (
+	"backend/auth"
+	"backend/db"
+	"backend/db/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type placeOrderRequest struct {
+	Side   models.OrderSide `json:"side" validate:"required,oneof=bid ask"`
+	Price  string           `json:"price" validate:"required,numeric"`  // ETH per token
+	Amount string           `json:"amount" validate:"required,numeric"` // Tokens
+}
+
+type tradeLegRequest struct {
+	TxHash string `json:"tx_hash" validate:"required,len=66,hexadecimal"`
+}
+
+// tradeView - a trade with what each side has to send to settle it
+type tradeView struct {
+	models.Trade
+	Settlement tradeSettlement `json:"settlement"`
+}
+
+// tradeSettlement - settlement instructions of a trade, PropertyToken only accepts the transfer
+// while the property is Active, the token isn't paused and the buyer is approved
+type tradeSettlement struct {
+	TokenAddress   string `json:"token_address"`
+	TokenAmountWei string `json:"token_amount_wei"` // seller transfers this to the buyer on token_address
+	PaymentWei     string `json:"payment_wei"`      // buyer sends this ETH to the seller
+}
+
+// priceLevelView - depth of one price of a property's book
+type priceLevelView struct {
+	Price  string `json:"price"`
+	Amount string `json:"amount"`
+	Orders int64  `json:"orders"`
+}
+
+// PlaceOrder handles POST /properties/{id}/orders
+// Posts a limit bid or ask for the caller's wallet and matches it against the book right away,
+// whatever isn't matched rests on the book until it is filled or cancelled
+func (handler *RequestHandler) PlaceOrder(w http.ResponseWriter, r *http.Request) {
+	var req placeOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid Body", http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		http.Error(w, "Validation Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := parseUnits(req.Price, 18); err != nil {
+		http.Error(w, "Validation Error: price: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	amountWei, err := parseUnits(req.Amount, 18)
+	if err != nil {
+		http.Error(w, "Validation Error: amount: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, ok := handler.walletUser(w, r)
+	if !ok {
+		return
+	}
+	prop, err := handler.db.GetPropertyByID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Property not found", http.StatusNotFound)
+		return
+	}
+	if handler.chain == nil {
+		http.Error(w, "Blockchain service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	// orders that could never settle are refused up front
+	blocker, err := handler.chain.TransferBlocker(prop.OnchainTokenAddress, prop.OnchainAssetAddress)
+	if err != nil {
+		http.Error(w, "Failed to check token transfers: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	if blocker != "" {
+		http.Error(w, "Trading is halted: "+blocker, http.StatusConflict)
+		return
+	}
+	approved, err := handler.chain.IsApproved(user.WalletAddress)
+	if err != nil {
+		http.Error(w, "Failed to check approval: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	if !approved {
+		http.Error(w, "Forbidden: Only wallets approved on-chain can trade", http.StatusForbidden)
+		return
+	}
+	if req.Side == models.OrderAsk {
+		if reason, err := handler.askUncovered(prop, user.WalletAddress, amountWei); err != nil {
+			http.Error(w, "Failed to check token balance: "+err.Error(), http.StatusBadGateway)
+			return
+		} else if reason != "" {
+			http.Error(w, reason, http.StatusConflict)
+			return
+		}
+	}
+
+	order := models.Order{
+		ID:         uuid.New(),
+		PropertyID: prop.ID,
+		UserID:     user.ID,
+		Wallet:     user.WalletAddress,
+		Side:       req.Side,
+		Price:      req.Price,
+		Amount:     req.Amount,
+	}
+
+	// resting orders are checked again before they are matched, the book may be older than their wallet's state;
+	// the checks run before PlaceOrder locks the book, so no RPC call holds up the other orders of the property
+	unfit, err := handler.unfitMakers(prop, order)
+	if err != nil {
+		http.Error(w, "Failed to check resting orders: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	// orders placed since were checked when they were placed
+	canFill := func(maker models.Order) (string, error) {
+		return unfit[strings.ToLower(maker.Wallet)], nil
+	}
+
+	trades, err := handler.db.PlaceOrder(&order, time.Now().Add(tradeSettlementWindow()), canFill)
+	if err != nil {
+		log.Printf("Error: Failed to place order on property %s: %v", prop.ID, err)
+		http.Error(w, "Failed to place order: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Info: %s order %s for %s tokens at %s ETH placed on property %s, %d trade(s)", order.Side, order.ID, order.Amount, order.Price, prop.ID, len(trades))
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, map[string]any{
+		"order":  order,
+		"trades": newTradeViews(trades, prop),
+	})
+}
+
+// CancelOrder handles DELETE /orders/{id}
+// Takes the caller's open order off the book, trades it already matched still have to be settled
+func (handler *RequestHandler) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(auth.ClaimsKey).(*auth.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid order id", http.StatusBadRequest)
+		return
+	}
+
+	order, err := handler.db.GetOrder(id)
+	if err != nil || order.UserID != claims.UserID {
+		http.Error(w, "Order not found", http.StatusNotFound)
+		return
+	}
+
+	cancelled, err := handler.db.CancelOrder(order.ID, "cancelled by its owner")
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !cancelled {
+		http.Error(w, "Order is no longer open", http.StatusConflict)
+		return
+	}
+
+	log.Printf("Info: Order %s cancelled by its owner", order.ID)
+	render.JSON(w, r, map[string]any{"id": order.ID, "status": models.OrderCancelled})
+}
+
+// GetMyOrders handles GET /users/me/orders
+// Lists the caller's orders, newest first, ?status=open|filled|cancelled (default open, "all" for every order)
+func (handler *RequestHandler) GetMyOrders(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(auth.ClaimsKey).(*auth.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	status := models.OrderStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "":
+		status = models.OrderOpen
+	case "all":
+		status = ""
+	case models.OrderOpen, models.OrderFilled, models.OrderCancelled:
+	default:
+		http.Error(w, "Invalid status", http.StatusBadRequest)
+		return
+	}
+
+	orders, err := handler.db.GetOrdersByUser(claims.UserID, status)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if orders == nil {
+		orders = []models.Order{}
+	}
+	render.JSON(w, r, orders)
+}
+
+// GetMyTrades handles GET /users/me/trades
+// Lists the trades the caller's wallet bought or sold in, newest first, with their settlement instructions
+func (handler *RequestHandler) GetMyTrades(w http.ResponseWriter, r *http.Request) {
+	user, ok := handler.walletUser(w, r)
+	if !ok {
+		return
+	}
+
+	trades, err := handler.db.GetTradesByWallet(user.WalletAddress)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	properties := map[uuid.UUID]models.Property{}
+	views := make([]tradeView, 0, len(trades))
+	for _, trade := range trades {
+		prop, found := properties[trade.PropertyID]
+		if !found {
+			if prop, err = handler.db.GetPropertyByID(trade.PropertyID.String()); err != nil {
+				http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			properties[trade.PropertyID] = prop
+		}
+		views = append(views, newTradeView(trade, prop))
+	}
+	render.JSON(w, r, views)
+}
+
+// GetPropertyOrderBook handles GET /properties/{id}/orderbook
+// Market depth: open bids and asks summed per price, best price first, ?levels (default 20, at most 100)
+func (handler *RequestHandler) GetPropertyOrderBook(w http.ResponseWriter, r *http.Request) {
+	levels, ok := queryLimit(w, r, "levels", 20)
+	if !ok {
+		return
+	}
+	prop, err := handler.db.GetPropertyByID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Property not found", http.StatusNotFound)
+		return
+	}
+
+	book := map[string][]priceLevelView{}
+	for _, side := range []models.OrderSide{models.OrderBid, models.OrderAsk} {
+		depth, err := handler.db.GetOrderBookDepth(prop.ID, side, levels)
+		if err != nil {
+			http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		views := make([]priceLevelView, 0, len(depth))
+		for _, level := range depth {
+			price, _ := db.ParseDecimal(level.Price)
+			amount, _ := db.ParseDecimal(level.Amount)
+			views = append(views, priceLevelView{Price: db.FormatDecimal(price), Amount: db.FormatDecimal(amount), Orders: level.Orders})
+		}
+		book[string(side)+"s"] = views
+	}
+
+	render.JSON(w, r, map[string]any{
+		"property_id": prop.ID,
+		"bids":        book["bids"],
+		"asks":        book["asks"],
+	})
+}
+
+// GetPropertyTrades handles GET /properties/{id}/trades
+// Trade history of a property, newest first, ?limit (default 50, at most 100)
+func (handler *RequestHandler) GetPropertyTrades(w http.ResponseWriter, r *http.Request) {
+	limit, ok := queryLimit(w, r, "limit", 50)
+	if !ok {
+		return
+	}
+	prop, err := handler.db.GetPropertyByID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Property not found", http.StatusNotFound)
+		return
+	}
+
+	trades, err := handler.db.GetTradesByProperty(prop.ID, limit)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if trades == nil {
+		trades = []models.Trade{}
+	}
+	render.JSON(w, r, trades)
+}
+
+// SubmitTradePayment handles POST /trades/{id}/payment
+// Buyer records the ETH payment of a trade, it must send exactly payment_wei from the buyer to the seller
+func (handler *RequestHandler) SubmitTradePayment(w http.ResponseWriter, r *http.Request) {
+	handler.recordTradeLeg(w, r, db.TradePayment)
+}
+
+// SubmitTradeTransfer handles POST /trades/{id}/transfer
+// Seller records the token transfer of a trade, it must move exactly amount tokens from the seller to the buyer
+func (handler *RequestHandler) SubmitTradeTransfer(w http.ResponseWriter, r *http.Request) {
+	handler.recordTradeLeg(w, r, db.TradeTransfer)
+}
+
+// recordTradeLeg - verify and store the transaction of one leg of the trade in the URL
+func (handler *RequestHandler) recordTradeLeg(w http.ResponseWriter, r *http.Request, leg db.TradeLeg) {
+	var req tradeLegRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid Body", http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		http.Error(w, "Validation Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, ok := handler.walletUser(w, r)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid trade id", http.StatusBadRequest)
+		return
+	}
+	trade, err := handler.db.GetTrade(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Trade not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	party, wallet := "buyer", trade.BuyerWallet
+	if leg == db.TradeTransfer {
+		party, wallet = "seller", trade.SellerWallet
+	}
+	if !strings.EqualFold(user.WalletAddress, wallet) {
+		http.Error(w, "Forbidden: Only the "+party+" can record the "+string(leg), http.StatusForbidden)
+		return
+	}
+	if trade.Status != models.TradePending {
+		http.Error(w, "Trade is "+string(trade.Status)+", it can no longer be settled", http.StatusConflict)
+		return
+	}
+	if handler.chain == nil {
+		http.Error(w, "Blockchain service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	// one transaction can only settle one trade or purchase
+	if handler.txHashUsed(w, req.TxHash) {
+		return
+	}
+
+	prop, err := handler.db.GetPropertyByID(trade.PropertyID.String())
+	if err != nil {
+		http.Error(w, "Property not found", http.StatusNotFound)
+		return
+	}
+	settlement := newTradeView(trade, prop).Settlement
+	if leg == db.TradePayment {
+		paymentWei, _ := new(big.Int).SetString(settlement.PaymentWei, 10)
+		_, err = handler.chain.VerifyPayment(r.Context(), req.TxHash, trade.BuyerWallet, trade.SellerWallet, paymentWei)
+	} else {
+		amountWei, _ := new(big.Int).SetString(settlement.TokenAmountWei, 10)
+		_, err = handler.chain.VerifyTokenTransfer(r.Context(), req.TxHash, prop.OnchainTokenAddress, trade.SellerWallet, trade.BuyerWallet, amountWei)
+	}
+	if err != nil {
+		writeSettlementError(w, "Trade "+string(leg), err)
+		return
+	}
+
+	recorded, err := handler.db.RecordTradeLeg(trade.ID, leg, req.TxHash)
+	if errors.Is(err, db.ErrTxHashUsed) {
+		http.Error(w, "Transaction already used for a trade or purchase", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !recorded {
+		http.Error(w, "The "+string(leg)+" of this trade is already recorded", http.StatusConflict)
+		return
+	}
+
+	trade, err = handler.db.GetTrade(trade.ID)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Info: Trade %s %s verified in %s, trade is %s", trade.ID, leg, req.TxHash, trade.Status)
+	render.JSON(w, r, newTradeView(trade, prop))
+}
+
+// unfitMakers - why the wallets of the resting orders order would match can no longer trade, by lowercase wallet
+// wallets that still can are left out
+func (handler *RequestHandler) unfitMakers(prop models.Property, order models.Order) (map[string]string, error) {
+	makers, err := handler.db.GetMatchableOrders(order)
+	if err != nil {
+		return nil, err
+	}
+
+	unfit := map[string]string{}
+	checked := map[string]bool{}
+	for _, maker := range makers {
+		wallet := strings.ToLower(maker.Wallet)
+		if checked[wallet] {
+			continue
+		}
+		checked[wallet] = true
+
+		var reason string
+		if maker.Side == models.OrderBid {
+			approved, err := handler.chain.IsApproved(maker.Wallet)
+			if err != nil {
+				return nil, err
+			}
+			if !approved {
+				reason = "wallet is no longer approved to receive tokens"
+			}
+		} else if reason, err = handler.askUncovered(prop, maker.Wallet, new(big.Int)); err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			unfit[wallet] = reason
+		}
+	}
+	return unfit, nil
+}
+
+// tradeSettlementWindow - how long both sides have to settle a trade before it fails, TRADE_SETTLEMENT_HOURS (24)
+func tradeSettlementWindow() time.Duration {
+	return time.Duration(envInt("TRADE_SETTLEMENT_HOURS", 24)) * time.Hour
+}
+
+// startTradeExpiry - fail trades not settled in time, every TRADE_EXPIRY_INTERVAL seconds (60)
+func (handler *RequestHandler) startTradeExpiry(ctx context.Context) {
+	interval := time.Duration(envInt("TRADE_EXPIRY_INTERVAL", 60)) * time.Second
+
+	go func() {
+		for {
+			failed, err := handler.db.ExpireTrades(time.Now())
+			if err != nil {
+				log.Printf("Error: Failed to expire trades: %v", err)
+			} else if failed > 0 {
+				log.Printf("Info: %d trade(s) not settled in time marked failed", failed)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+}
+
+// askUncovered - why wallet can't deliver extra tokens (wei) on top of what it already offers or owes, "" if it can
+func (handler *RequestHandler) askUncovered(prop models.Property, wallet string, extra *big.Int) (string, error) {
+	balance, err := handler.chain.GetTokenBalance(prop.OnchainTokenAddress, wallet)
+	if err != nil {
+		return "", err
+	}
+	committed, err := handler.db.GetCommittedAskAmount(prop.ID, wallet)
+	if err != nil {
+		return "", err
+	}
+	committedWei, err := parseUnits(committed, 18)
+	if err != nil {
+		committedWei = new(big.Int) // parseUnits refuses 0
+	}
+	if needed := new(big.Int).Add(committedWei, extra); balance.Cmp(needed) < 0 {
+		return "Not enough tokens: the wallet holds " + db.FormatDecimal(new(big.Rat).SetFrac(balance, weiPerEther)) +
+			" and already offers or owes " + db.FormatDecimal(new(big.Rat).SetFrac(committedWei, weiPerEther)), nil
+	}
+	return "", nil
+}
+
+// walletUser - calling user, writes the error response if they have no wallet
+func (handler *RequestHandler) walletUser(w http.ResponseWriter, r *http.Request) (models.User, bool) {
+	claims, ok := r.Context().Value(auth.ClaimsKey).(*auth.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return models.User{}, false
+	}
+	user, err := handler.db.GetUserById(claims.UserID.String())
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return models.User{}, false
+	}
+	if user.WalletAddress == "" {
+		http.Error(w, "User wallet address not found", http.StatusBadRequest)
+		return models.User{}, false
+	}
+	return user, true
+}
+
+// queryLimit - positive integer query parameter name, def if it is missing, capped at 100
+func queryLimit(w http.ResponseWriter, r *http.Request, name string, def int) (int, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, true
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit <= 0 {
+		http.Error(w, "Invalid "+name, http.StatusBadRequest)
+		return 0, false
+	}
+	return min(limit, 100), true
+}
+
+func newTradeViews(trades []models.Trade, prop models.Property) []tradeView {
+	views := make([]tradeView, 0, len(trades))
+	for _, trade := range trades {
+		views = append(views, newTradeView(trade, prop))
+	}
+	return views
+}
+
+func newTradeView(trade models.Trade, prop models.Property) tradeView {
+	amountWei, _ := parseUnits(trade.Amount, 18)
+	paymentWei, err := parseUnits(trade.Total, 18)
+	if err != nil {
+		paymentWei = new(big.Int) // a trade too small to cost a wei
+	}
+	return tradeView{
+		Trade: trade,
+		Settlement: tradeSettlement{
+			TokenAddress:   prop.OnchainTokenAddress,
+			TokenAmountWei: amountWei.String(),
+			PaymentWei:     paymentWei.String(),
+		},
+	}
+}
@@ -0,0 +1,356 @@
+package api
+
+import (
+	"backend/auth"
+	"backend/blockchain"
+	"backend/db/models"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Permission - action a route group requires
+type Permission string
+
+const (
+	PermViewUsers         Permission = "users:read"
+	PermApproveUsers      Permission = "users:approve"
+	PermManageProperties  Permission = "properties:manage"
+	PermDistributeRevenue Permission = "revenue:distribute"
+	PermViewTransactions  Permission = "transactions:read"
+	PermManageRoles       Permission = "roles:manage"
+)
+
+// rolePermissions - what each role may do, admins may do everything
+var rolePermissions = map[models.UserRole][]Permission{
+	models.RoleAdmin: {
+		PermViewUsers, PermApproveUsers, PermManageProperties,
+		PermDistributeRevenue, PermViewTransactions, PermManageRoles,
+	},
+	models.RoleComplianceReviewer: {PermViewUsers, PermApproveUsers},
+	models.RolePropertyManager:    {PermViewUsers, PermManageProperties},
+	models.RoleRevenueDistributor: {PermDistributeRevenue, PermViewTransactions},
+}
+
+// staffRoleOnChain - contract role mirrored to the wallet of a user holding a staff role
+var staffRoleOnChain = map[models.UserRole]blockchain.OnChainRole{
+	models.RoleComplianceReviewer: blockchain.RoleApprovalAdmin,
+	models.RolePropertyManager:    blockchain.RoleCreator,
+	models.RoleRevenueDistributor: blockchain.RoleDistributor,
+}
+
+// scopePermissions - route permissions an API key scope grants, ScopeRead only opens GET routes without one
+var scopePermissions = map[models.APIKeyScope][]Permission{
+	models.ScopeDistributeRevenue: {PermDistributeRevenue},
+}
+
+// apiKeyPermissions - union of the permissions of an API key's scopes
+func apiKeyPermissions(scopes []models.APIKeyScope) []Permission {
+	var perms []Permission
+	for _, scope := range scopes {
+		perms = append(perms, scopePermissions[scope]...)
+	}
+	return perms
+}
+
+// userPermissions - union of the permissions of User.Role and the user's staff roles
+func (handler *RequestHandler) userPermissions(user models.User) ([]models.UserRole, []Permission, error) {
+	roles, err := handler.db.GetUserRoles(user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	roles = append([]models.UserRole{user.Role}, roles...)
+
+	var perms []Permission
+	for _, role := range roles {
+		for _, perm := range rolePermissions[role] {
+			if !slices.Contains(perms, perm) {
+				perms = append(perms, perm)
+			}
+		}
+	}
+	return roles, perms, nil
+}
+
+// hasPermission - whether the user with this id may perform perm
+func (handler *RequestHandler) hasPermission(userID uuid.UUID, perm Permission) (bool, error) {
+	user, err := handler.db.GetUserById(userID.String())
+	if err != nil {
+		return false, err
+	}
+	_, perms, err := handler.userPermissions(user)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(perms, perm), nil
+}
+
+// claimsHavePermission - hasPermission for the user or API key a request was authenticated as
+func (handler *RequestHandler) claimsHavePermission(claims *auth.Claims, perm Permission) (bool, error) {
+	if claims.IsAPIKey() {
+		return slices.Contains(apiKeyPermissions(claims.Scopes), perm), nil
+	}
+	return handler.hasPermission(claims.UserID, perm)
+}
+
+// RequirePermission - middleware that lets through users holding perm through any of their roles,
+// and API keys with a scope granting it
+func (handler *RequestHandler) RequirePermission(perm Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := r.Context().Value(auth.ClaimsKey).(*auth.Claims)
+			if !ok {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if claims.IsAPIKey() {
+				if allowed, _ := handler.claimsHavePermission(claims, perm); !allowed {
+					http.Error(w, "Forbidden: API key lacks the "+string(perm)+" scope", http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user, err := handler.db.GetUserById(claims.UserID.String())
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					http.Error(w, "User not found", http.StatusUnauthorized)
+					return
+				}
+				http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if secondFactorMissing(w, user, claims) {
+				return
+			}
+
+			_, perms, err := handler.userPermissions(user)
+			if err != nil {
+				http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !slices.Contains(perms, perm) {
+				http.Error(w, "Forbidden: "+string(perm)+" permission required", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireUser - middleware for routes that act as the logged-in user, API keys are refused
+func (handler *RequestHandler) RequireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if claims, ok := r.Context().Value(auth.ClaimsKey).(*auth.Claims); ok && claims.IsAPIKey() {
+			http.Error(w, "Forbidden: This route needs a user login, API keys can't use it", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GetMyPermissions handles GET /users/me/permissions
+// Lists the authenticated user's roles and the permissions they grant
+func (handler *RequestHandler) GetMyPermissions(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(auth.ClaimsKey).(*auth.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := handler.db.GetUserById(claims.UserID.String())
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	roles, perms, err := handler.userPermissions(user)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if perms == nil {
+		perms = []Permission{}
+	}
+
+	render.JSON(w, r, map[string]any{"roles": roles, "permissions": perms})
+}
+
+// GetUserRoles handles GET /users/{id}/roles (admin)
+// Lists the staff roles assigned to a user
+func (handler *RequestHandler) GetUserRoles(w http.ResponseWriter, r *http.Request) {
+	user, ok := handler.roleTarget(w, r)
+	if !ok {
+		return
+	}
+
+	assignments, err := handler.db.GetRoleAssignments(user.ID)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if assignments == nil {
+		assignments = []models.RoleAssignment{}
+	}
+
+	render.JSON(w, r, map[string]any{"role": user.Role, "assignments": assignments})
+}
+
+type assignRoleRequest struct {
+	Role          models.UserRole `json:"role" validate:"required"`
+	MirrorOnChain bool            `json:"mirror_on_chain"` // also grant the matching contract role to the user's wallet
+}
+
+// AssignUserRole handles POST /users/{id}/roles (admin)
+// Grants a staff role, and optionally the matching contract role to the user's wallet
+func (handler *RequestHandler) AssignUserRole(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(auth.ClaimsKey).(*auth.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req assignRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		http.Error(w, "Validation Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	onChainRole, isStaffRole := staffRoleOnChain[req.Role]
+	if !isStaffRole {
+		http.Error(w, "Invalid role, use compliance_reviewer, property_manager or revenue_distributor", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := handler.roleTarget(w, r)
+	if !ok {
+		return
+	}
+
+	assignment := models.RoleAssignment{UserID: user.ID, Role: req.Role, GrantedBy: claims.UserID}
+
+	// keep an earlier on-chain grant when the role is assigned again without mirroring
+	existing, err := handler.db.GetRoleAssignments(user.ID)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, a := range existing {
+		if a.Role == req.Role {
+			assignment.OnChain, assignment.TxHash = a.OnChain, a.TxHash
+		}
+	}
+
+	if req.MirrorOnChain {
+		if handler.chain == nil {
+			http.Error(w, "Blockchain service not available", http.StatusServiceUnavailable)
+			return
+		}
+		if user.WalletAddress == "" {
+			http.Error(w, "User has no wallet address", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := handler.chain.GrantRole(r.Context(), onChainRole, user.WalletAddress)
+		if err != nil {
+			writeChainError(w, "Failed to grant on-chain role", err)
+			return
+		}
+		assignment.OnChain = true
+		if tx != nil {
+			assignment.TxHash = tx.Hash().Hex()
+		}
+	}
+
+	if err := handler.db.AssignRole(assignment); err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Info: Role %s assigned to user %s by %s (on-chain: %v)", req.Role, user.ID, claims.UserID, assignment.OnChain)
+	render.JSON(w, r, assignment)
+}
+
+// RemoveUserRole handles DELETE /users/{id}/roles/{role} (admin)
+// Removes a staff role, a contract role mirrored for it is revoked from the user's wallet too
+func (handler *RequestHandler) RemoveUserRole(w http.ResponseWriter, r *http.Request) {
+	role := models.UserRole(chi.URLParam(r, "role"))
+	onChainRole, isStaffRole := staffRoleOnChain[role]
+	if !isStaffRole {
+		http.Error(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := handler.roleTarget(w, r)
+	if !ok {
+		return
+	}
+
+	assignments, err := handler.db.GetRoleAssignments(user.ID)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	idx := slices.IndexFunc(assignments, func(a models.RoleAssignment) bool { return a.Role == role })
+	if idx < 0 {
+		http.Error(w, "User does not have this role", http.StatusNotFound)
+		return
+	}
+
+	// a mirrored contract role is revoked first, the assignment keeps recording it until that went through
+	response := map[string]any{"user_id": user.ID, "role": role, "removed": true}
+	if assignments[idx].OnChain {
+		if handler.chain == nil {
+			http.Error(w, "Blockchain service not available to revoke the on-chain role, the role was kept", http.StatusServiceUnavailable)
+			return
+		}
+		tx, err := handler.chain.RevokeRole(r.Context(), onChainRole, user.WalletAddress)
+		if err != nil {
+			writeChainError(w, "Revoking the on-chain role failed, the role was kept", err)
+			return
+		}
+		if tx != nil {
+			response["tx_hash"] = tx.Hash().Hex()
+		}
+	}
+
+	if _, err := handler.db.RemoveRole(user.ID, role); err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Info: Role %s removed from user %s", role, user.ID)
+	render.JSON(w, r, response)
+}
+
+// roleTarget - user named by the {id} URL parameter, writes the error response if there is none
+func (handler *RequestHandler) roleTarget(w http.ResponseWriter, r *http.Request) (models.User, bool) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		http.Error(w, "Invalid user id", http.StatusBadRequest)
+		return models.User{}, false
+	}
+	user, err := handler.db.GetUserById(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return models.User{}, false
+		}
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return models.User{}, false
+	}
+	return user, true
+}
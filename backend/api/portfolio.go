@@ -0,0 +1,164 @@
+package api
+
+import (
+	"backend/db"
+	"backend/db/models"
+	"context"
+	"log"
+	"math/big"
+	"net/http"
+
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+// PortfolioHolding - the caller's position in one property in GET /users/me/portfolio
+// token and ETH figures are decimal strings, revenue is in the stablecoin's smallest unit
+type PortfolioHolding struct {
+	PropertyID          string  `json:"property_id"`
+	PropertyName        string  `json:"property_name"`
+	TokenAddress        string  `json:"token_address"`
+	Balance             string  `json:"balance"`              // tokens held on-chain
+	OwnershipPercentage float64 `json:"ownership_percentage"` // balance of the total supply
+	TokensAcquired      string  `json:"tokens_acquired"`      // bought through transferred purchases and settled trades
+	AverageCost         string  `json:"average_cost"`         // ETH per token over those acquisitions, "" without any
+	CostBasis           string  `json:"cost_basis"`           // average_cost x balance, ETH
+	Valuation           float64 `json:"valuation"`
+	ImpliedValue        float64 `json:"implied_value"` // valuation x ownership share
+	RevenueClaimed      string  `json:"revenue_claimed"`
+	RevenueClaimable    string  `json:"revenue_claimable"`
+	Error               string  `json:"error,omitempty"` // set if the on-chain figures could not be read
+}
+
+// GetMyPortfolio handles GET /users/me/portfolio
+// Holdings, cost, implied value and revenue of the caller's wallet across all properties,
+// the chain reads are batched through Multicall3 where the chain has it
+func (handler *RequestHandler) GetMyPortfolio(w http.ResponseWriter, r *http.Request) {
+	user, ok := handler.walletUser(w, r)
+	if !ok {
+		return
+	}
+	if handler.chain == nil {
+		http.Error(w, "Blockchain service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	props, err := handler.db.GetTokenizedProperties()
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	acquisitions, err := handler.db.GetAcquisitionsByWallet(user.WalletAddress)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	acquired := map[uuid.UUID]db.Acquisition{}
+	for _, a := range acquisitions {
+		acquired[a.PropertyID] = a
+	}
+
+	tokens := make([]string, len(props))
+	for i, prop := range props {
+		tokens[i] = prop.OnchainTokenAddress
+	}
+	holdings, err := handler.chain.GetTokenHoldings(r.Context(), user.WalletAddress, tokens)
+	if err != nil {
+		log.Printf("Error: Failed to read token holdings of %s: %v", user.WalletAddress, err)
+		http.Error(w, "Failed to read token holdings: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	claimed, claimable, revenueErr := handler.portfolioRevenue(r.Context(), user.WalletAddress, props)
+
+	totalCost, totalClaimed, totalClaimable := new(big.Rat), new(big.Int), new(big.Int)
+	var totalValue float64
+	result := []PortfolioHolding{}
+	for i, prop := range props {
+		holding, bought := holdings[i], acquired[prop.ID]
+		propClaimed, propClaimable := claimed[prop.ID], claimable[prop.ID]
+		if propClaimed == nil {
+			propClaimed = new(big.Int)
+		}
+		if propClaimable == nil {
+			propClaimable = new(big.Int)
+		}
+
+		view := PortfolioHolding{
+			PropertyID:       prop.ID.String(),
+			PropertyName:     prop.Name,
+			TokenAddress:     prop.OnchainTokenAddress,
+			Balance:          "0",
+			TokensAcquired:   "0",
+			CostBasis:        "0",
+			Valuation:        prop.Valuation,
+			RevenueClaimed:   propClaimed.String(),
+			RevenueClaimable: propClaimable.String(),
+		}
+		balance := new(big.Rat)
+		if holding.Err != nil {
+			log.Printf("Warning: Failed to read %s holding of %s: %v", prop.OnchainTokenAddress, user.WalletAddress, holding.Err)
+			view.Error = "failed to read on-chain balance"
+		} else {
+			balance.SetFrac(holding.Balance, weiPerEther)
+			view.Balance = db.FormatDecimal(balance)
+			if holding.TotalSupply.Sign() > 0 {
+				share := new(big.Rat).SetFrac(holding.Balance, holding.TotalSupply)
+				view.OwnershipPercentage, _ = new(big.Rat).Mul(share, big.NewRat(100, 1)).Float64()
+				view.ImpliedValue, _ = new(big.Rat).Mul(share, new(big.Rat).SetFloat64(prop.Valuation)).Float64()
+			}
+		}
+
+		boughtTokens, _ := db.ParseDecimal(bought.Tokens)
+		if boughtTokens.Sign() > 0 {
+			cost, _ := db.ParseDecimal(bought.Cost)
+			average := new(big.Rat).Quo(cost, boughtTokens)
+			basis := new(big.Rat).Mul(average, balance)
+			view.TokensAcquired = db.FormatDecimal(boughtTokens)
+			view.AverageCost = db.FormatDecimal(average)
+			view.CostBasis = db.FormatDecimal(basis)
+			totalCost.Add(totalCost, basis)
+		}
+
+		// properties the wallet never touched are left out
+		if balance.Sign() == 0 && boughtTokens.Sign() == 0 && propClaimed.Sign() == 0 && propClaimable.Sign() == 0 && view.Error == "" {
+			continue
+		}
+		totalValue += view.ImpliedValue
+		totalClaimed.Add(totalClaimed, propClaimed)
+		totalClaimable.Add(totalClaimable, propClaimable)
+		result = append(result, view)
+	}
+
+	response := map[string]any{
+		"wallet":                  user.WalletAddress,
+		"total_implied_value":     totalValue,
+		"total_cost_basis":        db.FormatDecimal(totalCost),
+		"total_revenue_claimed":   totalClaimed.String(),
+		"total_revenue_claimable": totalClaimable.String(),
+		"properties":              result,
+	}
+	if revenueErr != nil {
+		response["revenue_error"] = revenueErr.Error()
+	}
+	render.JSON(w, r, response)
+}
+
+// portfolioRevenue - revenue a wallet claimed and can still claim per property, in one batch of chain reads
+// err is set if some of it could not be read, the sums then leave that part out
+func (handler *RequestHandler) portfolioRevenue(ctx context.Context, wallet string, props []models.Property) (claimed, claimable map[uuid.UUID]*big.Int, err error) {
+	claimed, claimable = map[uuid.UUID]*big.Int{}, map[uuid.UUID]*big.Int{}
+
+	entitlements, err := handler.walletEntitlements(ctx, wallet, props)
+	for _, entitlement := range entitlements {
+		sums := claimable
+		if entitlement.Claimed {
+			sums = claimed
+		}
+		if sums[entitlement.PropertyID] == nil {
+			sums[entitlement.PropertyID] = new(big.Int)
+		}
+		sums[entitlement.PropertyID].Add(sums[entitlement.PropertyID], entitlement.Amount)
+	}
+	return claimed, claimable, err
+}
@@ -1,7 +1,6 @@
 package api
 
 import (
-	"backend/blockchain"
 	"backend/db/models"
 	"backend/ipfs"
 	"encoding/json"
@@ -73,56 +72,12 @@ func (handler *RequestHandler) CreateProperty(w http.ResponseWriter, r *http.Req
 
 	log.Printf("CreateProperty: Processed %d files, main hash: %s", len(dbDocs), mainHash)
 
-	// Submit to blockchain and wait for confirmation
-	result, err := handler.createPropertyOnChain(payload, mainHash)
-	if err != nil {
-		log.Printf("CreateProperty: Blockchain transaction failed: %v", err)
-		http.Error(w, "Blockchain Error: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	log.Printf("CreateProperty: Blockchain transaction confirmed - Hash: %s", result.TxHash)
-	log.Printf("CreateProperty: Asset: %s, Token: %s", result.AssetAddress, result.TokenAddress)
-
-	// Create property record in databse
-	property := models.Property{
-		ID:                  uuid.New(),
-		Name:                result.PropertyName,
-		OnchainAssetAddress: result.AssetAddress,
-		OnchainTokenAddress: result.TokenAddress,
-		OwnerWallet:         payload.OwnerAddress,
-		MetadataHash:        mainHash,
-		Valuation:           float64(payload.Valuation),
-		Status:              models.StatusActive,
-		TxHash:              result.TxHash,
-		CreatedAt:           time.Now(),
-	}
-
-	if err := handler.db.CreateProperty(property); err != nil {
-		log.Printf("CreateProperty: Database save failed: %v", err)
-		// Property exists on blockchain but not in DB - log error but don't fail the request
-		// The event listener can pick it up later
-		log.Printf("Warning: Property created on blockchain but DB save failed. Event listener will retry.")
-	} else {
-		log.Printf("CreateProperty: Property saved to database - ID: %s", property.ID)
-	}
-
-	// Link documents to property
-	for i := range dbDocs {
-		dbDocs[i].PropertyID = property.ID
-		if err := handler.db.CreatePropertyDocument(dbDocs[i]); err != nil {
-			log.Printf("Warning: Failed to save document %d: %v", i, err)
-		}
-	}
-
-	render.JSON(w, r, map[string]any{
-		"status":        "success",
-		"tx_hash":       result.TxHash,
-		"property_id":   property.ID.String(),
-		"asset_address": result.AssetAddress,
-		"token_address": result.TokenAddress,
-		"files_count":   len(dbDocs),
-		"message":       "Property created successfully on blockchain and saved to database.",
+	// Submit to blockchain from a job worker, the client polls GET /jobs/{id} for the property ID
+	// the same owner and documents map to the same job, so a retried request can't mint twice
+	handler.enqueueJob(w, r, models.JobCreateProperty, strings.ToLower(payload.OwnerAddress)+":"+mainHash, createPropertyJob{
+		Property:     *payload,
+		MetadataHash: mainHash,
+		Documents:    dbDocs,
 	})
 }
 
@@ -160,7 +115,7 @@ func (handler *RequestHandler) UpdatePropertyApproval(w http.ResponseWriter, r *
 	if handler.chain != nil {
 		switch req.Status {
 		case models.ApprovalApproved:
-			tx, err = handler.chain.ApproveProperty(prop.OnchainAssetAddress)
+			tx, err = handler.chain.ApproveProperty(r.Context(), prop.OnchainAssetAddress)
 			if err != nil {
 				log.Printf("Warning: Blockchain approval failed: %v", err)
 				// Continue with DB update even if blockchain fails
@@ -168,7 +123,7 @@ func (handler *RequestHandler) UpdatePropertyApproval(w http.ResponseWriter, r *
 				log.Printf("Blockchain approval transaction: %s", tx.Hash().Hex())
 			}
 		case models.ApprovalRejected:
-			tx, err = handler.chain.RejectProperty(prop.OnchainAssetAddress)
+			tx, err = handler.chain.RejectProperty(r.Context(), prop.OnchainAssetAddress)
 			if err != nil {
 				log.Printf("Warning: Blockchain rejection failed: %v", err)
 				// Continue with DB update even if blockchain fails
@@ -300,18 +255,3 @@ func inferDocType(filename string) string {
 		return "General Document"
 	}
 }
-
-func (handler *RequestHandler) createPropertyOnChain(p *PropertyPayload, dataHash string) (*blockchain.PropertyCreationResult, error) {
-	if handler.chain == nil {
-		return nil, fmt.Errorf("blockchain service not available")
-	}
-
-	return handler.chain.CreateProperty(
-		p.OwnerAddress,
-		p.Name,
-		p.Symbol,
-		dataHash,
-		p.Valuation,
-		p.TokenSupply,
-	)
-}
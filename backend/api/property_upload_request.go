@@ -119,10 +119,16 @@ func (handler *RequestHandler) GetPropertyUploadRequests(w http.ResponseWriter,
 		return
 	}
 
+	canManage, err := handler.hasPermission(user.ID, PermManageProperties)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	var requests []models.PropertyUploadRequest
 
-	// Admin sees all requests, users see only their own
-	if user.Role == models.RoleAdmin {
+	// Property managers see all requests, users see only their own
+	if canManage {
 		requests, err = handler.db.GetPropertyUploadRequests()
 		if err != nil {
 			log.Printf("❌ GetPropertyUploadRequests: Failed to fetch requests: %v", err)
@@ -164,8 +170,13 @@ func (handler *RequestHandler) GetPropertyUploadRequest(w http.ResponseWriter, r
 		return
 	}
 
-	// Users can only see their own requests, admins can see any
-	if user.Role != models.RoleAdmin && request.WalletAddress != user.WalletAddress {
+	// Users can only see their own requests, property managers can see any
+	canManage, err := handler.hasPermission(user.ID, PermManageProperties)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !canManage && request.WalletAddress != user.WalletAddress {
 		http.Error(w, "Forbidden: You can only view your own requests", http.StatusForbidden)
 		return
 	}
@@ -174,7 +185,7 @@ func (handler *RequestHandler) GetPropertyUploadRequest(w http.ResponseWriter, r
 }
 
 // ApprovePropertyUploadRequest handles POST /property-upload-requests/{id}/approve
-// Admin-only: Creates the property on blockchain and updates request status
+// Admin-only: Queues the on-chain property creation, the request is marked approved when it is mined
 func (handler *RequestHandler) ApprovePropertyUploadRequest(w http.ResponseWriter, r *http.Request) {
 	defer func() {
 		if rec := recover(); rec != nil {
@@ -208,65 +219,9 @@ func (handler *RequestHandler) ApprovePropertyUploadRequest(w http.ResponseWrite
 		return
 	}
 
-	// Create property on blockchain using existing logic
-	payload := PropertyPayload{
-		OwnerAddress: request.WalletAddress,
-		Name:         request.Name,
-		Symbol:       request.Symbol,
-		Valuation:    int64(request.Valuation),
-		TokenSupply:  request.TokenSupply,
-	}
-
-	result, err := handler.createPropertyOnChain(&payload, request.MetadataHash)
-	if err != nil {
-		log.Printf("❌ ApprovePropertyUploadRequest: Blockchain transaction failed: %v", err)
-		http.Error(w, "Blockchain Error: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	log.Printf("✅ ApprovePropertyUploadRequest: Blockchain transaction confirmed - Hash: %s", result.TxHash)
-
-	// Create property record in database
-	property := models.Property{
-		ID:                  uuid.New(),
-		Name:                result.PropertyName,
-		OnchainAssetAddress: result.AssetAddress,
-		OnchainTokenAddress: result.TokenAddress,
-		OwnerWallet:         request.WalletAddress,
-		MetadataHash:        request.MetadataHash,
-		Valuation:           request.Valuation,
-		Status:              models.StatusActive,
-		TxHash:              result.TxHash,
-		CreatedAt:           time.Now(),
-	}
-
-	if err := handler.db.CreateProperty(property); err != nil {
-		log.Printf("❌ ApprovePropertyUploadRequest: Database save failed: %v", err)
-		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	log.Printf("✅ ApprovePropertyUploadRequest: Property saved to database - ID: %s", property.ID)
-
-	// Copy documents from request to property
-	// Get request documents (we need to fetch them, but for now we'll create them from request metadata)
-	// In a real scenario, you'd fetch the request documents and copy them
-	// For simplicity, we'll note that documents are already on IPFS via MetadataHash
-
-	// Update request status to approved
-	if err := handler.db.UpdatePropertyUploadRequestStatus(id, models.ApprovalApproved, ""); err != nil {
-		log.Printf("⚠️ ApprovePropertyUploadRequest: Failed to update request status: %v", err)
-		// Don't fail the request since property was created
-	}
-
-	render.JSON(w, r, map[string]any{
-		"status":        "success",
-		"message":       "Property created successfully and request approved",
-		"request_id":    id,
-		"property_id":   property.ID.String(),
-		"tx_hash":       result.TxHash,
-		"asset_address": result.AssetAddress,
-		"token_address": result.TokenAddress,
+	// Create property on blockchain from a job worker, the request is marked approved once it is mined
+	handler.enqueueJob(w, r, models.JobApproveUploadRequest, id, approveUploadRequestJob{
+		RequestID: id,
 	})
 }
 
@@ -0,0 +1,307 @@
+package api
+
+import (
+	"backend/auth"
+	"backend/blockchain"
+	"backend/db"
+	"backend/db/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+type purchaseReasonRequest struct {
+	Reason string `json:"reason" validate:"max=1000"`
+}
+
+type purchaseRefundRequest struct {
+	RefundTxHash string `json:"refund_tx_hash" validate:"required,len=66,hexadecimal"`
+}
+
+// purchaseApprovalWindow - how long the owner has to approve a purchase, PURCHASE_APPROVAL_HOURS (72)
+func purchaseApprovalWindow() time.Duration {
+	return time.Duration(envInt("PURCHASE_APPROVAL_HOURS", 72)) * time.Hour
+}
+
+// purchasePaymentWindow - how long an awaiting_payment purchase holds its tokens, PURCHASE_PAYMENT_MINUTES (30)
+// the approval window only starts once the payment is confirmed
+func purchasePaymentWindow() time.Duration {
+	return time.Duration(envInt("PURCHASE_PAYMENT_MINUTES", 30)) * time.Minute
+}
+
+// VerifyTokenPurchasePayment handles POST /properties/{id}/purchases/{purchaseId}/payment
+// Checks the payment of an awaiting_payment purchase again, it becomes paid once the payment is confirmed
+func (handler *RequestHandler) VerifyTokenPurchasePayment(w http.ResponseWriter, r *http.Request) {
+	prop, purchase, user, ok := handler.purchaseTarget(w, r)
+	if !ok {
+		return
+	}
+	if !strings.EqualFold(user.WalletAddress, purchase.BuyerWallet) && !strings.EqualFold(user.WalletAddress, prop.OwnerWallet) {
+		http.Error(w, "Forbidden: Only the buyer or the property owner can check the payment", http.StatusForbidden)
+		return
+	}
+	if purchase.Status != models.PurchaseAwaitingPayment {
+		http.Error(w, "Purchase is "+string(purchase.Status)+", the payment was already handled", http.StatusConflict)
+		return
+	}
+	if handler.chain == nil {
+		http.Error(w, "Blockchain service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	priceWei, err := parseUnits(purchase.PurchasePrice, 18)
+	if err != nil {
+		http.Error(w, "Invalid purchase price", http.StatusInternalServerError)
+		return
+	}
+	if _, err := handler.chain.VerifyPayment(r.Context(), purchase.PaymentTxHash, purchase.BuyerWallet, prop.OwnerWallet, priceWei); err != nil {
+		writeSettlementError(w, "Payment", err)
+		return
+	}
+
+	expiresAt := time.Now().Add(purchaseApprovalWindow())
+	if !handler.transitionPurchase(w, purchase, models.PurchasePaid, map[string]any{"expires_at": expiresAt}) {
+		return
+	}
+	render.JSON(w, r, map[string]any{"id": purchase.ID, "status": models.PurchasePaid, "expires_at": expiresAt})
+}
+
+// DeclineTokenPurchase handles POST /properties/{id}/purchases/{purchaseId}/decline
+// Owner turns down a paid or approved purchase, the payment then has to be refunded
+func (handler *RequestHandler) DeclineTokenPurchase(w http.ResponseWriter, r *http.Request) {
+	var req purchaseReasonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid Body", http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		http.Error(w, "Validation Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	_, purchase, _, ok := handler.ownerPurchaseTarget(w, r)
+	if !ok {
+		return
+	}
+	if !handler.transitionPurchase(w, purchase, models.PurchaseDeclined, map[string]any{"status_reason": req.Reason}) {
+		return
+	}
+
+	log.Printf("Info: Token purchase %s declined by the owner", purchase.ID)
+	render.JSON(w, r, map[string]any{"id": purchase.ID, "status": models.PurchaseDeclined})
+}
+
+// CancelTokenPurchase handles POST /properties/{id}/purchases/{purchaseId}/cancel
+// Buyer withdraws a purchase the owner hasn't approved yet, a paid purchase then has to be refunded
+func (handler *RequestHandler) CancelTokenPurchase(w http.ResponseWriter, r *http.Request) {
+	// the body with a reason is optional
+	var req purchaseReasonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid Body", http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		http.Error(w, "Validation Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	_, purchase, user, ok := handler.purchaseTarget(w, r)
+	if !ok {
+		return
+	}
+	if !strings.EqualFold(user.WalletAddress, purchase.BuyerWallet) {
+		http.Error(w, "Forbidden: Only the buyer can cancel a purchase", http.StatusForbidden)
+		return
+	}
+	if !handler.transitionPurchase(w, purchase, models.PurchaseCancelled, map[string]any{"status_reason": req.Reason}) {
+		return
+	}
+
+	log.Printf("Info: Token purchase %s cancelled by the buyer", purchase.ID)
+	render.JSON(w, r, map[string]any{"id": purchase.ID, "status": models.PurchaseCancelled})
+}
+
+// RefundTokenPurchase handles POST /properties/{id}/purchases/{purchaseId}/refund
+// Owner records the refund of a declined, cancelled or expired purchase
+// The transaction must return purchase_price ETH from the owner wallet to the buyer,
+// a purchase whose payment was never verified is refunded if that payment has confirmed since
+func (handler *RequestHandler) RefundTokenPurchase(w http.ResponseWriter, r *http.Request) {
+	var req purchaseRefundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid Body", http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		http.Error(w, "Validation Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	prop, purchase, _, ok := handler.ownerPurchaseTarget(w, r)
+	if !ok {
+		return
+	}
+	if !purchase.Status.CanTransitionTo(models.PurchaseRefunded) {
+		http.Error(w, "Purchase is "+string(purchase.Status)+", only declined, cancelled or expired purchases are refunded", http.StatusConflict)
+		return
+	}
+	if handler.chain == nil {
+		http.Error(w, "Blockchain service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	priceWei, err := parseUnits(purchase.PurchasePrice, 18)
+	if err != nil {
+		http.Error(w, "Invalid purchase price", http.StatusInternalServerError)
+		return
+	}
+
+	// a payment that confirmed only after the purchase expired or was cancelled is still owed back
+	fields := map[string]any{"refund_tx_hash": req.RefundTxHash}
+	if purchase.PaymentVerifiedAt == nil {
+		_, err := handler.chain.VerifyPayment(r.Context(), purchase.PaymentTxHash, purchase.BuyerWallet, prop.OwnerWallet, priceWei)
+		var settleErr *blockchain.SettlementError
+		switch {
+		case err == nil:
+			fields["payment_verified_at"] = time.Now()
+		case errors.As(err, &settleErr) && settleErr.Kind == blockchain.SettlementPending:
+			writeSettlementError(w, "Payment", err)
+			return
+		default:
+			log.Printf("Info: Purchase %s payment %s not refundable: %v", purchase.ID, purchase.PaymentTxHash, err)
+			http.Error(w, "Purchase was never paid, there is nothing to refund", http.StatusConflict)
+			return
+		}
+	}
+
+	if handler.txHashUsed(w, req.RefundTxHash) {
+		return
+	}
+
+	// a refund is the payment in reverse
+	if _, err := handler.chain.VerifyPayment(r.Context(), req.RefundTxHash, prop.OwnerWallet, purchase.BuyerWallet, priceWei); err != nil {
+		writeSettlementError(w, "Refund", err)
+		return
+	}
+
+	if !handler.transitionPurchase(w, purchase, models.PurchaseRefunded, fields) {
+		return
+	}
+
+	log.Printf("Info: Token purchase %s refunded in %s", purchase.ID, req.RefundTxHash)
+	render.JSON(w, r, map[string]any{"id": purchase.ID, "status": models.PurchaseRefunded})
+}
+
+// txHashUsed - whether a transaction already settles a purchase or a trade, writes the 409 or error response if so
+// one transaction can only settle one of them, whichever table it was recorded in
+func (handler *RequestHandler) txHashUsed(w http.ResponseWriter, txHash string) bool {
+	usedByTrade, err := handler.db.TradeTxUsed(txHash)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return true
+	}
+	usedByPurchase, err := handler.db.TokenPurchaseTxUsed(txHash)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return true
+	}
+	if usedByTrade || usedByPurchase {
+		http.Error(w, "Transaction already used for a trade or purchase", http.StatusConflict)
+		return true
+	}
+	return false
+}
+
+// transitionPurchase - move a purchase to status to, writes a 409 and returns false if it can't
+func (handler *RequestHandler) transitionPurchase(w http.ResponseWriter, purchase models.TokenPurchase, to models.PurchaseStatus, fields map[string]any) bool {
+	if !purchase.Status.CanTransitionTo(to) {
+		http.Error(w, "Purchase is "+string(purchase.Status)+" and can't become "+string(to), http.StatusConflict)
+		return false
+	}
+
+	updated, err := handler.db.TransitionTokenPurchase(purchase.ID, purchase.Status, to, fields)
+	if errors.Is(err, db.ErrTxHashUsed) {
+		http.Error(w, "Transaction already used for a purchase", http.StatusConflict)
+		return false
+	}
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if !updated {
+		http.Error(w, "Purchase changed, reload and try again", http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// purchaseTarget - property, purchase and calling user of a /properties/{id}/purchases/{purchaseId} route,
+// writes the error response if one is missing
+func (handler *RequestHandler) purchaseTarget(w http.ResponseWriter, r *http.Request) (models.Property, models.TokenPurchase, models.User, bool) {
+	claims, ok := r.Context().Value(auth.ClaimsKey).(*auth.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return models.Property{}, models.TokenPurchase{}, models.User{}, false
+	}
+
+	prop, err := handler.db.GetPropertyByID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Property not found", http.StatusNotFound)
+		return models.Property{}, models.TokenPurchase{}, models.User{}, false
+	}
+
+	purchase, err := handler.db.GetTokenPurchaseByID(chi.URLParam(r, "purchaseId"))
+	if err != nil || purchase.PropertyID != prop.ID {
+		http.Error(w, "Purchase not found", http.StatusNotFound)
+		return models.Property{}, models.TokenPurchase{}, models.User{}, false
+	}
+
+	user, err := handler.db.GetUserById(claims.UserID.String())
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return models.Property{}, models.TokenPurchase{}, models.User{}, false
+	}
+	return prop, purchase, user, true
+}
+
+// ownerPurchaseTarget - purchaseTarget for routes only the property owner may use
+func (handler *RequestHandler) ownerPurchaseTarget(w http.ResponseWriter, r *http.Request) (models.Property, models.TokenPurchase, models.User, bool) {
+	prop, purchase, user, ok := handler.purchaseTarget(w, r)
+	if !ok {
+		return prop, purchase, user, false
+	}
+	if user.WalletAddress == "" || !strings.EqualFold(user.WalletAddress, prop.OwnerWallet) {
+		http.Error(w, "Forbidden: Only the property owner can manage purchases", http.StatusForbidden)
+		return prop, purchase, user, false
+	}
+	return prop, purchase, user, true
+}
+
+// startPurchaseExpiry - expire purchases the owner didn't approve in time, every PURCHASE_EXPIRY_INTERVAL seconds (60)
+func (handler *RequestHandler) startPurchaseExpiry(ctx context.Context) {
+	interval := time.Duration(envInt("PURCHASE_EXPIRY_INTERVAL", 60)) * time.Second
+
+	go func() {
+		for {
+			expired, err := handler.db.ExpireTokenPurchases(time.Now())
+			if err != nil {
+				log.Printf("Error: Failed to expire token purchases: %v", err)
+			} else if expired > 0 {
+				log.Printf("Info: Expired %d token purchase(s) not approved in time", expired)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"backend/auth"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// clientIP - address of the client without the port
+// behind a proxy set TRUST_PROXY=true so RemoteAddr is taken from X-Forwarded-For / X-Real-IP
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// tooManyRequests - 429 with Retry-After in whole seconds
+func tooManyRequests(w http.ResponseWriter, wait time.Duration, message string) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", fmt.Sprint(seconds))
+	http.Error(w, fmt.Sprintf("%s, try again in %d seconds", message, seconds), http.StatusTooManyRequests)
+}
+
+// RateLimit - middleware limiting how many requests one IP can make to the login and registration routes
+// keeps bcrypt from being used to exhaust the CPU
+func (handler *RequestHandler) RateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wait, err := handler.limiter.Allow(clientIP(r))
+		if err != nil {
+			log.Printf("Error: Rate limit check failed: %v", err)
+			http.Error(w, "Server Error", http.StatusInternalServerError)
+			return
+		}
+		if wait > 0 {
+			tooManyRequests(w, wait, "Too many requests")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rejectLocked - write a 429 and return true while the client IP or the account is locked out
+func (handler *RequestHandler) rejectLocked(w http.ResponseWriter, r *http.Request, email string) bool {
+	wait, err := handler.limiter.Locked(auth.IPKey(clientIP(r)), auth.AccountKey(email))
+	if err != nil {
+		log.Printf("Error: Lockout check failed: %v", err)
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return true
+	}
+	if wait > 0 {
+		tooManyRequests(w, wait, "Too many failed attempts")
+		return true
+	}
+	return false
+}
+
+// loginFailed - count a failed password or second factor against the client IP and the account
+func (handler *RequestHandler) loginFailed(r *http.Request, email string) {
+	if err := handler.limiter.Fail(clientIP(r), email); err != nil {
+		log.Printf("Error: Failed to record failed login: %v", err)
+	}
+}
+
+// loginSucceeded - forget the failed attempts of an account once it is fully logged in
+func (handler *RequestHandler) loginSucceeded(email string) {
+	if err := handler.limiter.Succeeded(email); err != nil {
+		log.Printf("Warning: Failed to clear failed logins: %v", err)
+	}
+}
+
+// GetLockouts handles GET /lockouts (admin)
+// Lists IPs and accounts with recent failed logins and whether they are locked
+func (handler *RequestHandler) GetLockouts(w http.ResponseWriter, r *http.Request) {
+	lockouts, err := handler.limiter.Lockouts()
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	result := make([]map[string]any, len(lockouts))
+	for i, l := range lockouts {
+		result[i] = map[string]any{
+			"key":             l.Key,
+			"failures":        l.Failures,
+			"locked":          l.LockedUntil != nil && l.LockedUntil.After(now),
+			"locked_until":    l.LockedUntil,
+			"last_failure_at": l.LastFailureAt,
+		}
+	}
+
+	render.JSON(w, r, result)
+}
+
+// ClearLockout handles DELETE /lockouts/{key} (admin)
+// Unlocks an IP ("ip:<address>") or account ("account:<email>") and resets its failure count
+func (handler *RequestHandler) ClearLockout(w http.ResponseWriter, r *http.Request) {
+	key, err := url.PathUnescape(chi.URLParam(r, "key"))
+	if err != nil || key == "" {
+		http.Error(w, "Invalid key", http.StatusBadRequest)
+		return
+	}
+
+	if err := handler.limiter.Clear(key); err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Info: Lockout %s cleared", key)
+	render.JSON(w, r, map[string]any{"key": key, "cleared": true})
+}
@@ -0,0 +1,298 @@
+package api
+
+import (
+	"backend/auth"
+	"backend/blockchain"
+	"backend/db"
+	"backend/db/models"
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+// EntitlementResponse - a wallet's share of one distribution
+// amounts are in the stablecoin's smallest unit, as decimal strings
+type EntitlementResponse struct {
+	DistributionID int64  `json:"distribution_id"`
+	PropertyID     string `json:"property_id,omitempty"`
+	Wallet         string `json:"wallet"`
+	TokenAddress   string `json:"token_address"`
+	Stablecoin     string `json:"stablecoin"`
+	SnapshotID     string `json:"snapshot_id"`
+	TotalAmount    string `json:"total_amount"`
+	HolderBalance  string `json:"holder_balance"`
+	TotalSupplyAt  string `json:"total_supply_at"`
+	Entitlement    string `json:"entitlement"`
+	Claimed        bool   `json:"claimed"`
+}
+
+// PropertyRevenueSummary - revenue totals for one property in GET /users/me/revenue
+type PropertyRevenueSummary struct {
+	PropertyID    string                `json:"property_id"`
+	PropertyName  string                `json:"property_name"`
+	TokenAddress  string                `json:"token_address"`
+	Unclaimed     string                `json:"unclaimed"`
+	Claimed       string                `json:"claimed"`
+	Distributions []EntitlementResponse `json:"distributions"`
+}
+
+func newEntitlementResponse(e *blockchain.RevenueEntitlement, wallet, propertyID string) EntitlementResponse {
+	return EntitlementResponse{
+		DistributionID: e.DistributionID,
+		PropertyID:     propertyID,
+		Wallet:         wallet,
+		TokenAddress:   e.TokenAddress,
+		Stablecoin:     e.Stablecoin,
+		SnapshotID:     e.SnapshotID.String(),
+		TotalAmount:    e.TotalAmount.String(),
+		HolderBalance:  e.HolderBalance.String(),
+		TotalSupplyAt:  e.TotalSupplyAt.String(),
+		Entitlement:    e.Amount.String(),
+		Claimed:        e.Claimed,
+	}
+}
+
+// GetPropertyDistributions handles GET /properties/{id}/distributions
+// Returns the revenue distributions recorded for a property
+func (handler *RequestHandler) GetPropertyDistributions(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if _, err := handler.db.GetPropertyByID(id); err != nil {
+		http.Error(w, "Property not found", http.StatusNotFound)
+		return
+	}
+
+	distributions, err := handler.db.GetRevenueDistributionsByProperty(id)
+	if err != nil {
+		log.Printf("Failed to get distributions: %v", err)
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Always return an array, even if empty
+	if distributions == nil {
+		distributions = []models.RevenueDistribution{}
+	}
+
+	render.JSON(w, r, distributions)
+}
+
+// GetDistributionEntitlement handles GET /properties/{id}/distributions/{distributionId}/entitlement
+// distributionId is the on-chain distribution ID, ?wallet= defaults to the authenticated user's wallet
+func (handler *RequestHandler) GetDistributionEntitlement(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	distributionID, err := strconv.ParseInt(chi.URLParam(r, "distributionId"), 10, 64)
+	if err != nil || distributionID < 0 {
+		http.Error(w, "Invalid distribution id", http.StatusBadRequest)
+		return
+	}
+
+	prop, err := handler.db.GetPropertyByID(id)
+	if err != nil {
+		http.Error(w, "Property not found", http.StatusNotFound)
+		return
+	}
+
+	wallet := r.URL.Query().Get("wallet")
+	if wallet == "" {
+		claims, ok := r.Context().Value(auth.ClaimsKey).(*auth.Claims)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		user, err := handler.db.GetUserById(claims.UserID.String())
+		if err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		wallet = user.WalletAddress
+	}
+	if err := validate.Var(wallet, "eth_addr"); err != nil {
+		http.Error(w, "Invalid wallet address", http.StatusBadRequest)
+		return
+	}
+
+	if handler.chain == nil {
+		http.Error(w, "Blockchain service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	entitlement, err := handler.chain.GetRevenueEntitlement(distributionID, wallet)
+	if err != nil {
+		log.Printf("Failed to get revenue entitlement: %v", err)
+		http.Error(w, "Failed to get entitlement: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// the distribution ID is global to the contract, make sure it belongs to this property
+	if !strings.EqualFold(entitlement.TokenAddress, prop.OnchainTokenAddress) {
+		http.Error(w, "Distribution not found for this property", http.StatusNotFound)
+		return
+	}
+
+	render.JSON(w, r, newEntitlementResponse(entitlement, wallet, prop.ID.String()))
+}
+
+// GetMyRevenue handles GET /users/me/revenue
+// Returns claimed and unclaimed revenue for the authenticated user across all properties
+func (handler *RequestHandler) GetMyRevenue(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(auth.ClaimsKey).(*auth.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := handler.db.GetUserById(claims.UserID.String())
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if user.WalletAddress == "" {
+		http.Error(w, "User wallet address not found", http.StatusBadRequest)
+		return
+	}
+
+	if handler.chain == nil {
+		http.Error(w, "Blockchain service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	props, err := handler.db.GetTokenizedProperties()
+	if err != nil {
+		log.Printf("Failed to get properties: %v", err)
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	propByID := map[uuid.UUID]models.Property{}
+	for _, prop := range props {
+		propByID[prop.ID] = prop
+	}
+
+	entitlements, revenueErr := handler.walletEntitlements(r.Context(), user.WalletAddress, props)
+
+	totalUnclaimed := new(big.Int)
+	totalClaimed := new(big.Int)
+	summaries := map[uuid.UUID]*PropertyRevenueSummary{}
+	unclaimedByProperty := map[uuid.UUID]*big.Int{}
+	claimedByProperty := map[uuid.UUID]*big.Int{}
+	var order []uuid.UUID
+
+	for _, entitlement := range entitlements {
+		propertyID := entitlement.PropertyID
+		summary, ok := summaries[propertyID]
+		if !ok {
+			prop := propByID[propertyID]
+			summary = &PropertyRevenueSummary{
+				PropertyID:    propertyID.String(),
+				PropertyName:  prop.Name,
+				TokenAddress:  prop.OnchainTokenAddress,
+				Distributions: []EntitlementResponse{},
+			}
+			summaries[propertyID] = summary
+			unclaimedByProperty[propertyID] = new(big.Int)
+			claimedByProperty[propertyID] = new(big.Int)
+			order = append(order, propertyID)
+		}
+
+		if entitlement.Claimed {
+			claimedByProperty[propertyID].Add(claimedByProperty[propertyID], entitlement.Amount)
+			totalClaimed.Add(totalClaimed, entitlement.Amount)
+		} else {
+			unclaimedByProperty[propertyID].Add(unclaimedByProperty[propertyID], entitlement.Amount)
+			totalUnclaimed.Add(totalUnclaimed, entitlement.Amount)
+		}
+		summary.Distributions = append(summary.Distributions, newEntitlementResponse(entitlement.RevenueEntitlement, user.WalletAddress, summary.PropertyID))
+	}
+
+	properties := []PropertyRevenueSummary{}
+	for _, propertyID := range order {
+		summary := summaries[propertyID]
+		summary.Unclaimed = unclaimedByProperty[propertyID].String()
+		summary.Claimed = claimedByProperty[propertyID].String()
+		properties = append(properties, *summary)
+	}
+
+	response := map[string]interface{}{
+		"wallet":          user.WalletAddress,
+		"total_unclaimed": totalUnclaimed.String(),
+		"total_claimed":   totalClaimed.String(),
+		"properties":      properties,
+	}
+	if revenueErr != nil {
+		response["error"] = revenueErr.Error()
+	}
+	render.JSON(w, r, response)
+}
+
+// walletEntitlement - a wallet's entitlement to one indexed distribution, with the property it belongs to
+type walletEntitlement struct {
+	*blockchain.RevenueEntitlement
+	PropertyID uuid.UUID
+}
+
+// walletEntitlements - a wallet's non-zero entitlements to the indexed distributions of props, in one batch of chain reads
+// shared by GET /users/me/revenue and GET /users/me/portfolio so both report the same figures
+// err is set if some of them could not be read, the result then leaves those out
+func (handler *RequestHandler) walletEntitlements(ctx context.Context, wallet string, props []models.Property) ([]walletEntitlement, error) {
+	tokenOf := map[uuid.UUID]string{}
+	for _, prop := range props {
+		tokenOf[prop.ID] = prop.OnchainTokenAddress
+	}
+
+	distributions, err := handler.db.GetIndexedRevenueDistributions()
+	if err != nil {
+		return nil, err
+	}
+	var refs []blockchain.DistributionRef
+	var owners []uuid.UUID // property of each ref
+	for _, dist := range distributions {
+		token, found := tokenOf[dist.PropertyID]
+		total, ok := db.ParseDecimal(dist.TotalAmount)
+		if !found || !ok || !total.IsInt() {
+			continue
+		}
+		refs = append(refs, blockchain.DistributionRef{
+			DistributionID: *dist.OnchainDistributionID,
+			TokenAddress:   token,
+			SnapshotID:     big.NewInt(int64(dist.SnapshotID)),
+			TotalAmount:    total.Num(),
+		})
+		owners = append(owners, dist.PropertyID)
+	}
+	if len(refs) == 0 {
+		return nil, nil
+	}
+
+	entitlements, err := handler.chain.GetRevenueEntitlements(ctx, wallet, refs)
+	if err != nil {
+		log.Printf("Warning: Failed to read revenue entitlements of %s: %v", wallet, err)
+		return nil, err
+	}
+	var result []walletEntitlement
+	failed := 0
+	for i, entitlement := range entitlements {
+		if entitlement == nil {
+			failed++
+			continue
+		}
+		// skip distributions the wallet held nothing for
+		if entitlement.Amount.Sign() == 0 {
+			continue
+		}
+		result = append(result, walletEntitlement{RevenueEntitlement: entitlement, PropertyID: owners[i]})
+	}
+	if failed > 0 {
+		err = fmt.Errorf("%d of %d distributions could not be read", failed, len(refs))
+	}
+	return result, err
+}
@@ -0,0 +1,231 @@
+package api
+
+import (
+	"backend/auth"
+	"backend/db/models"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type createServiceAccountRequest struct {
+	Name        string `json:"name" validate:"required,min=3,max=100"`
+	Description string `json:"description" validate:"max=500"`
+}
+
+type createAPIKeyRequest struct {
+	Name          string               `json:"name" validate:"max=100"`
+	Scopes        []models.APIKeyScope `json:"scopes" validate:"required,min=1,dive,oneof=read revenue:distribute"`
+	ExpiresInDays int                  `json:"expires_in_days" validate:"gte=0,lte=3650"` // 0 for a key that doesn't expire
+}
+
+// GetServiceAccounts handles GET /service-accounts (admin)
+func (handler *RequestHandler) GetServiceAccounts(w http.ResponseWriter, r *http.Request) {
+	accounts, err := handler.db.GetServiceAccounts()
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if accounts == nil {
+		accounts = []models.ServiceAccount{}
+	}
+	render.JSON(w, r, accounts)
+}
+
+// CreateServiceAccount handles POST /service-accounts (admin)
+// Creates an account for a machine client, keys are issued for it separately
+func (handler *RequestHandler) CreateServiceAccount(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(auth.ClaimsKey).(*auth.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req createServiceAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		http.Error(w, "Validation Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	exists, err := handler.db.ServiceAccountExists(strings.TrimSpace(req.Name))
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if exists {
+		http.Error(w, "A service account with this name already exists", http.StatusConflict)
+		return
+	}
+
+	account := models.ServiceAccount{
+		ID:          uuid.New(),
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		CreatedBy:   claims.UserID,
+	}
+	if err := handler.db.CreateServiceAccount(&account); err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Info: Service account %s (%s) created by %s", account.Name, account.ID, claims.UserID)
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, account)
+}
+
+// DisableServiceAccount handles DELETE /service-accounts/{id} (admin)
+// Disables the account and revokes all of its keys, the record is kept for the audit trail
+func (handler *RequestHandler) DisableServiceAccount(w http.ResponseWriter, r *http.Request) {
+	account, ok := handler.serviceAccountTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if err := handler.db.DisableServiceAccount(account.ID); err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Info: Service account %s (%s) disabled", account.Name, account.ID)
+	render.JSON(w, r, map[string]any{"id": account.ID, "disabled": true})
+}
+
+// GetAPIKeys handles GET /service-accounts/{id}/keys (admin)
+// Lists the keys of a service account, the keys themselves are never shown again after creation
+func (handler *RequestHandler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	account, ok := handler.serviceAccountTarget(w, r)
+	if !ok {
+		return
+	}
+
+	keys, err := handler.db.GetAPIKeys(account.ID)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if keys == nil {
+		keys = []models.APIKey{}
+	}
+	render.JSON(w, r, keys)
+}
+
+// CreateAPIKey handles POST /service-accounts/{id}/keys (admin)
+// Issues a scoped key, the response is the only time the key is shown
+func (handler *RequestHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(auth.ClaimsKey).(*auth.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req createAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		http.Error(w, "Validation Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	account, ok := handler.serviceAccountTarget(w, r)
+	if !ok {
+		return
+	}
+	if account.DisabledAt != nil {
+		http.Error(w, "Service account is disabled", http.StatusBadRequest)
+		return
+	}
+
+	rawKey, prefix, hash, err := auth.NewAPIKey()
+	if err != nil {
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	slices.Sort(req.Scopes)
+	key := models.APIKey{
+		ID:               uuid.New(),
+		ServiceAccountID: account.ID,
+		Name:             req.Name,
+		Prefix:           prefix,
+		KeyHash:          hash,
+		Scopes:           slices.Compact(req.Scopes),
+		CreatedBy:        claims.UserID,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		key.ExpiresAt = &expiresAt
+	}
+
+	if err := handler.db.CreateAPIKey(&key); err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Info: API key %s (%v) issued for service account %s by %s", key.Prefix, key.Scopes, account.ID, claims.UserID)
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, map[string]any{
+		"key":     rawKey,
+		"api_key": key,
+		"message": "Store the key now, it can't be shown again",
+	})
+}
+
+// RevokeAPIKey handles DELETE /service-accounts/{id}/keys/{keyId} (admin)
+func (handler *RequestHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	account, ok := handler.serviceAccountTarget(w, r)
+	if !ok {
+		return
+	}
+	keyID, err := uuid.Parse(chi.URLParam(r, "keyId"))
+	if err != nil {
+		http.Error(w, "Invalid key id", http.StatusBadRequest)
+		return
+	}
+
+	revoked, err := handler.db.RevokeAPIKey(account.ID, keyID)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !revoked {
+		http.Error(w, "API key not found or already revoked", http.StatusNotFound)
+		return
+	}
+
+	log.Printf("Info: API key %s of service account %s revoked", keyID, account.ID)
+	render.JSON(w, r, map[string]any{"id": keyID, "revoked": true})
+}
+
+// serviceAccountTarget - service account named by the {id} URL parameter, writes the error response if there is none
+func (handler *RequestHandler) serviceAccountTarget(w http.ResponseWriter, r *http.Request) (models.ServiceAccount, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid service account id", http.StatusBadRequest)
+		return models.ServiceAccount{}, false
+	}
+	account, err := handler.db.GetServiceAccount(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Service account not found", http.StatusNotFound)
+			return models.ServiceAccount{}, false
+		}
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return models.ServiceAccount{}, false
+	}
+	return account, true
+}
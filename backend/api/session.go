@@ -0,0 +1,231 @@
+package api
+
+import (
+	"backend/auth"
+	"backend/db/models"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TokenPair - access and refresh token returned by login and refresh
+type TokenPair struct {
+	Token            string    `json:"token"`
+	RefreshToken     string    `json:"refresh_token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// loginResponse - tokens plus a safe view of the user (no password hash)
+func loginResponse(user models.User, tokens TokenPair) map[string]any {
+	// Handle potential empty/null values safely
+	approvalStatus := string(user.ApprovalStatus)
+	if approvalStatus == "" {
+		approvalStatus = "pending" // Default value
+		log.Printf("Warning Login: ApprovalStatus was empty, defaulting to 'pending'")
+	}
+
+	return map[string]any{
+		"token":              tokens.Token,
+		"refresh_token":      tokens.RefreshToken,
+		"expires_at":         tokens.ExpiresAt,
+		"refresh_expires_at": tokens.RefreshExpiresAt,
+		"user": map[string]any{
+			"ID":             user.ID.String(),
+			"Email":          user.Email,
+			"Name":           user.Name,
+			"WalletAddress":  user.WalletAddress,
+			"Role":           string(user.Role),
+			"ApprovalStatus": approvalStatus,
+			"CreatedAt":      user.CreatedAt,
+			"UpdatedAt":      user.UpdatedAt,
+		},
+	}
+}
+
+// issueTokens - create a refresh token in familyID (a new session when nil) and an access token bound to it
+// mfa marks a session whose login passed the second factor, it carries over to refreshed tokens
+func (handler *RequestHandler) issueTokens(r *http.Request, user models.User, familyID uuid.UUID, mfa bool) (TokenPair, models.RefreshToken, error) {
+	if familyID == uuid.Nil {
+		familyID = uuid.New()
+	}
+
+	refresh, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return TokenPair{}, models.RefreshToken{}, err
+	}
+
+	now := time.Now()
+	record := models.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hash,
+		MFA:       mfa,
+		ExpiresAt: now.Add(auth.RefreshTokenTTL()),
+		UserAgent: truncate(r.UserAgent(), 255),
+		IPAddress: r.RemoteAddr,
+	}
+
+	access, err := auth.GenerateToken(user.ID, user.Email, familyID, mfa)
+	if err != nil {
+		return TokenPair{}, models.RefreshToken{}, err
+	}
+
+	return TokenPair{
+		Token:            access,
+		RefreshToken:     refresh,
+		ExpiresAt:        now.Add(auth.AccessTokenTTL),
+		RefreshExpiresAt: record.ExpiresAt,
+	}, record, nil
+}
+
+// startSession - issue tokens for a fresh login and persist the refresh token
+func (handler *RequestHandler) startSession(r *http.Request, user models.User, mfa bool) (TokenPair, error) {
+	tokens, record, err := handler.issueTokens(r, user, uuid.Nil, mfa)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if err := handler.db.CreateRefreshToken(record); err != nil {
+		return TokenPair{}, err
+	}
+	return tokens, nil
+}
+
+// RefreshToken handles POST /auth/refresh
+// Exchanges a refresh token for a new access token and a rotated refresh token
+// Reusing an already rotated token revokes the whole session
+func (handler *RequestHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		http.Error(w, "Validation Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	current, err := handler.db.GetRefreshTokenByHash(auth.HashOpaqueToken(req.RefreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if current.UsedAt != nil || current.RevokedAt != nil {
+		handler.revokeReusedFamily(current)
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	if time.Now().After(current.ExpiresAt) {
+		http.Error(w, "Refresh token expired", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := handler.db.GetUserById(current.UserID.String())
+	if err != nil {
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	tokens, next, err := handler.issueTokens(r, user, current.FamilyID, current.MFA)
+	if err != nil {
+		log.Printf("Error: Failed to issue tokens: %v", err)
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	rotated, err := handler.db.RotateRefreshToken(current, next)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !rotated {
+		// another request rotated the same token first
+		handler.revokeReusedFamily(current)
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	render.JSON(w, r, tokens)
+}
+
+// revokeReusedFamily - a rotated or revoked refresh token came back, assume it leaked and end the session
+func (handler *RequestHandler) revokeReusedFamily(token models.RefreshToken) {
+	if token.RevokedAt == nil {
+		log.Printf("Warning: Refresh token reuse detected for user %s, revoking session %s", token.UserID, token.FamilyID)
+	}
+	if err := handler.db.RevokeRefreshFamily(token.FamilyID); err != nil {
+		log.Printf("Error: Failed to revoke session %s: %v", token.FamilyID, err)
+	}
+}
+
+// Logout handles POST /auth/logout
+// Revokes the current access token and its session, including the session's refresh token
+func (handler *RequestHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(auth.ClaimsKey).(*auth.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := handler.db.RevokeAccessToken(claims.ID, claims.UserID, claims.ExpiresAt.Time); err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := handler.db.RevokeRefreshFamily(claims.SessionID); err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	render.JSON(w, r, map[string]string{"message": "Logged out"})
+}
+
+// LogoutAll handles POST /auth/logout-all
+// Revokes every session of the authenticated user ("log out all devices")
+func (handler *RequestHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(auth.ClaimsKey).(*auth.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := handler.db.RevokeAccessToken(claims.ID, claims.UserID, claims.ExpiresAt.Time); err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := handler.db.RevokeUserSessions(claims.UserID, uuid.Nil); err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	render.JSON(w, r, map[string]string{"message": "Logged out of all sessions"})
+}
+
+// GetJWKS handles GET /.well-known/jwks.json
+// Publishes the public keys access tokens are signed with, for services verifying them without a shared secret
+func (handler *RequestHandler) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	render.JSON(w, r, map[string]any{"keys": auth.JWKS()})
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
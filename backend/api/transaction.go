@@ -0,0 +1,168 @@
+package api
+
+import (
+	"backend/auth"
+	"backend/blockchain"
+	"backend/db"
+	"backend/db/models"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/render"
+)
+
+// writeChainError - map a blockchain error to a response
+// reverts are the caller's fault (422), a transaction still pending at the deadline is a 504
+func writeChainError(w http.ResponseWriter, prefix string, err error) {
+	var txErr *blockchain.TxError
+	if !errors.As(err, &txErr) {
+		http.Error(w, prefix+": "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch txErr.Kind {
+	case blockchain.TxReverted:
+		http.Error(w, prefix+": "+txErr.Error(), http.StatusUnprocessableEntity)
+	case blockchain.TxTimeout:
+		http.Error(w, prefix+": "+txErr.Error()+", it may still be mined", http.StatusGatewayTimeout)
+	case blockchain.TxCanceled:
+		// client is gone, nobody reads the response
+		log.Printf("Info: Client disconnected while waiting for %s", txErr.TxHash.Hex())
+	default:
+		http.Error(w, prefix+": "+txErr.Error(), http.StatusInternalServerError)
+	}
+}
+
+// writeSettlementError - map a failed payment or transfer verification to a response
+// a mismatch rejects the request (422), a transaction without enough confirmations can be retried (409)
+func writeSettlementError(w http.ResponseWriter, prefix string, err error) {
+	var settleErr *blockchain.SettlementError
+	if !errors.As(err, &settleErr) {
+		http.Error(w, "Blockchain error: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	switch settleErr.Kind {
+	case blockchain.SettlementPending:
+		w.Header().Set("Retry-After", "15")
+		http.Error(w, prefix+" not confirmed yet: "+settleErr.Error(), http.StatusConflict)
+	default:
+		http.Error(w, prefix+" rejected: "+settleErr.Error(), http.StatusUnprocessableEntity)
+	}
+}
+
+// parseTransactionFilter - read ?type, ?status, ?wallet, ?limit and ?offset
+func parseTransactionFilter(r *http.Request) (db.TransactionFilter, string) {
+	query := r.URL.Query()
+	filter := db.TransactionFilter{
+		Wallet: query.Get("wallet"),
+		Type:   models.TransactionType(query.Get("type")),
+		Status: models.TransactionStatus(query.Get("status")),
+	}
+
+	switch filter.Type {
+	case "", models.TxTypeApproveUser, models.TxTypeCreateProperty, models.TxTypeDepositRevenue,
+		models.TxTypeClaimRevenue, models.TxTypeTransferToken, models.TxTypeApproveProperty, models.TxTypeRejectProperty,
+		models.TxTypeGrantRole, models.TxTypeRevokeRole, models.TxTypeRejectUser, models.TxTypeRevokeUser:
+	default:
+		return filter, "Invalid transaction type"
+	}
+
+	switch filter.Status {
+	case "", models.TxStatusPending, models.TxStatusConfirmed, models.TxStatusFailed:
+	default:
+		return filter, "Invalid transaction status"
+	}
+
+	if filter.Wallet != "" {
+		if err := validate.Var(filter.Wallet, "eth_addr"); err != nil {
+			return filter, "Invalid wallet address"
+		}
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			return filter, "Invalid limit"
+		}
+		filter.Limit = limit
+	}
+	if v := query.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return filter, "Invalid offset"
+		}
+		filter.Offset = offset
+	}
+
+	return filter, ""
+}
+
+// GetTransactions handles GET /transactions (admin)
+// Lists recorded blockchain transactions, filterable by type, status and wallet
+func (handler *RequestHandler) GetTransactions(w http.ResponseWriter, r *http.Request) {
+	filter, msg := parseTransactionFilter(r)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	transactions, err := handler.db.ListTransactions(filter)
+	if err != nil {
+		log.Printf("Failed to list transactions: %v", err)
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Always return an array, even if empty
+	if transactions == nil {
+		transactions = []models.Transaction{}
+	}
+
+	render.JSON(w, r, transactions)
+}
+
+// GetMyTransactions handles GET /users/me/transactions
+// Lists blockchain transactions concerning the authenticated user's wallet
+func (handler *RequestHandler) GetMyTransactions(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(auth.ClaimsKey).(*auth.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := handler.db.GetUserById(claims.UserID.String())
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if user.WalletAddress == "" {
+		http.Error(w, "User wallet address not found", http.StatusBadRequest)
+		return
+	}
+
+	filter, msg := parseTransactionFilter(r)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	// users only ever see their own wallet
+	filter.Wallet = user.WalletAddress
+
+	transactions, err := handler.db.ListTransactions(filter)
+	if err != nil {
+		log.Printf("Failed to list transactions: %v", err)
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Always return an array, even if empty
+	if transactions == nil {
+		transactions = []models.Transaction{}
+	}
+
+	render.JSON(w, r, transactions)
+}
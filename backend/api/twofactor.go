@@ -0,0 +1,361 @@
+package api
+
+import (
+	"backend/auth"
+	"backend/db/models"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+const recoveryCodeCount = 10
+
+// requireAdmin2FA - REQUIRE_ADMIN_2FA=true makes admins complete a second factor before using admin routes
+func requireAdmin2FA() bool {
+	return os.Getenv("REQUIRE_ADMIN_2FA") == "true"
+}
+
+// secondFactorMissing - write a 403 and return true when user is an admin whose token skipped 2FA
+// while REQUIRE_ADMIN_2FA is on
+func secondFactorMissing(w http.ResponseWriter, user models.User, claims *auth.Claims) bool {
+	if !requireAdmin2FA() || user.Role != models.RoleAdmin || claims.MFA {
+		return false
+	}
+	if user.TOTPEnabledAt == nil {
+		http.Error(w, "Forbidden: Two-factor authentication required, enroll at /users/me/2fa/setup", http.StatusForbidden)
+	} else {
+		http.Error(w, "Forbidden: Two-factor authentication required, log in again with your authenticator code", http.StatusForbidden)
+	}
+	return true
+}
+
+// respondMFAChallenge - answer a login whose first factor passed with a token for /login/2fa
+func (handler *RequestHandler) respondMFAChallenge(w http.ResponseWriter, r *http.Request, user models.User) {
+	token, err := auth.GenerateMFAToken(user.ID, user.Email)
+	if err != nil {
+		log.Printf("Error: Failed to generate mfa token: %v", err)
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+	render.JSON(w, r, map[string]any{
+		"mfa_required": true,
+		"mfa_token":    token,
+		"expires_at":   time.Now().Add(auth.MFATokenTTL),
+	})
+}
+
+// verifySecondFactor - check an authenticator code, or a recovery code when code is empty
+// both are single-use
+func (handler *RequestHandler) verifySecondFactor(user models.User, code, recoveryCode string) (bool, error) {
+	if user.TOTPEnabledAt == nil {
+		return false, nil
+	}
+	if code != "" {
+		step, ok := auth.VerifyTOTP(user.TOTPSecret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		return handler.db.UseTOTPStep(user.ID, step)
+	}
+	if recoveryCode != "" {
+		return handler.db.UseRecoveryCode(user.ID, auth.HashOpaqueToken(auth.NormalizeRecoveryCode(recoveryCode)))
+	}
+	return false, nil
+}
+
+// newRecoveryCodes - plain codes to show the user once, and the hashes to store
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := auth.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = auth.HashOpaqueToken(code)
+	}
+	return codes, hashes, nil
+}
+
+type secondFactorRequest struct {
+	MFAToken     string `json:"mfa_token" validate:"required"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recovery_code" validate:"required_without=Code"`
+}
+
+// LoginSecondFactor handles POST /login/2fa
+// Completes a login that answered with mfa_required, using an authenticator or recovery code
+func (handler *RequestHandler) LoginSecondFactor(w http.ResponseWriter, r *http.Request) {
+	var req secondFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		http.Error(w, "Validation Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	claims, err := auth.ParseMFAToken(req.MFAToken)
+	if err != nil {
+		http.Error(w, "Invalid or expired mfa token, log in again", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := handler.db.GetUserById(claims.UserID.String())
+	if err != nil {
+		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		return
+	}
+
+	// codes are guessed like passwords, so they count towards the same lockout
+	if handler.rejectLocked(w, r, user.Email) {
+		return
+	}
+
+	ok, err := handler.verifySecondFactor(user, req.Code, req.RecoveryCode)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		handler.loginFailed(r, user.Email)
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+		return
+	}
+
+	tokens, err := handler.startSession(r, user, true)
+	if err != nil {
+		log.Printf("Error: Failed to start session: %v", err)
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+	handler.loginSucceeded(user.Email)
+
+	response := loginResponse(user, tokens)
+	if req.Code == "" {
+		remaining, err := handler.db.CountRecoveryCodes(user.ID)
+		if err == nil {
+			response["recovery_codes_remaining"] = remaining
+		}
+		log.Printf("Info: User %s logged in with a recovery code, %d left", user.ID, remaining)
+	}
+	render.JSON(w, r, response)
+}
+
+// GetTwoFactorStatus handles GET /users/me/2fa
+func (handler *RequestHandler) GetTwoFactorStatus(w http.ResponseWriter, r *http.Request) {
+	user, ok := handler.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	remaining, err := handler.db.CountRecoveryCodes(user.ID)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	render.JSON(w, r, map[string]any{
+		"enabled":                  user.TOTPEnabledAt != nil,
+		"enabled_at":               user.TOTPEnabledAt,
+		"recovery_codes_remaining": remaining,
+		"required":                 requireAdmin2FA() && user.Role == models.RoleAdmin,
+	})
+}
+
+// SetupTwoFactor handles POST /users/me/2fa/setup
+// Generates an authenticator secret, 2FA is enabled once a code from it is confirmed
+func (handler *RequestHandler) SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := handler.currentUser(w, r)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabledAt != nil {
+		http.Error(w, "Two-factor authentication is already enabled, disable it first", http.StatusConflict)
+		return
+	}
+
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err := handler.db.SetTOTPSecret(user.ID, secret); err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	issuer := os.Getenv("TOTP_ISSUER")
+	if issuer == "" {
+		issuer = "RWA Platform"
+	}
+	render.JSON(w, r, map[string]string{
+		"secret":      secret,
+		"otpauth_url": auth.TOTPProvisioningURI(issuer, user.Email, secret),
+	})
+}
+
+type codeRequest struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+// EnableTwoFactor handles POST /users/me/2fa/enable
+// Confirms enrollment with a code from the authenticator and returns the recovery codes, shown only once
+func (handler *RequestHandler) EnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req codeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		http.Error(w, "Validation Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, ok := handler.currentUser(w, r)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabledAt != nil {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+	if user.TOTPSecret == "" {
+		http.Error(w, "Start enrollment at /users/me/2fa/setup first", http.StatusBadRequest)
+		return
+	}
+
+	step, valid := auth.VerifyTOTP(user.TOTPSecret, req.Code, time.Now())
+	if !valid {
+		http.Error(w, "Invalid code", http.StatusBadRequest)
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err := handler.db.EnableTOTP(user.ID, step, hashes); err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Info: Two-factor authentication enabled for user %s", user.ID)
+	render.JSON(w, r, map[string]any{
+		"enabled":        true,
+		"recovery_codes": codes,
+		"message":        "Store the recovery codes somewhere safe. Log in again to get a session that passed the second factor.",
+	})
+}
+
+type disableTwoFactorRequest struct {
+	Password     string `json:"password" validate:"required"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recovery_code" validate:"required_without=Code"`
+}
+
+// DisableTwoFactor handles POST /users/me/2fa/disable
+// Needs the password and a current code, so a stolen session alone can't turn 2FA off
+func (handler *RequestHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req disableTwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		http.Error(w, "Validation Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, ok := handler.currentUser(w, r)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabledAt == nil {
+		http.Error(w, "Two-factor authentication is not enabled", http.StatusBadRequest)
+		return
+	}
+	if !auth.CheckPasswordHash(req.Password, user.PasswordHash) {
+		http.Error(w, "Incorrect password", http.StatusUnauthorized)
+		return
+	}
+
+	valid, err := handler.verifySecondFactor(user, req.Code, req.RecoveryCode)
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+		return
+	}
+
+	if err := handler.db.DisableTOTP(user.ID); err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Info: Two-factor authentication disabled for user %s", user.ID)
+	render.JSON(w, r, map[string]any{"enabled": false})
+}
+
+// RegenerateRecoveryCodes handles POST /users/me/2fa/recovery-codes
+// Replaces all recovery codes after checking a current authenticator code
+func (handler *RequestHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	var req codeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		http.Error(w, "Validation Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, ok := handler.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	valid, err := handler.verifySecondFactor(user, req.Code, "")
+	if err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err := handler.db.ReplaceRecoveryCodes(user.ID, hashes); err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	render.JSON(w, r, map[string]any{"recovery_codes": codes})
+}
+
+// currentUser - the authenticated user, writes the error response if there is none
+func (handler *RequestHandler) currentUser(w http.ResponseWriter, r *http.Request) (models.User, bool) {
+	claims, ok := r.Context().Value(auth.ClaimsKey).(*auth.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return models.User{}, false
+	}
+	user, err := handler.db.GetUserById(claims.UserID.String())
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return models.User{}, false
+	}
+	return user, true
+}
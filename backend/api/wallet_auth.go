@@ -0,0 +1,173 @@
+package api
+
+import (
+	"backend/auth"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+	"gorm.io/gorm"
+)
+
+// nonceTTL - how long a SIWE nonce can be used after it was issued
+const nonceTTL = 10 * time.Minute
+
+// errSIWEDisabled - wallet sign-in is refused until the domains it may be issued for are configured
+var errSIWEDisabled = errors.New("wallet sign-in is not configured on this server")
+
+// siweDomains - domains SIWE messages may be issued for, SIWE_DOMAIN as a comma separated list
+// Never taken from the request: a phishing page could get a message for its own domain signed
+// and replay it with a matching Origin header
+func siweDomains() []string {
+	var domains []string
+	for _, domain := range strings.Split(os.Getenv("SIWE_DOMAIN"), ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
+
+// siweChainID - chain SIWE messages must be signed for, the connected chain or SIWE_CHAIN_ID (default Sepolia)
+func (handler *RequestHandler) siweChainID() int64 {
+	if handler.chain != nil {
+		return handler.chain.ChainID.Int64()
+	}
+	if v := os.Getenv("SIWE_CHAIN_ID"); v != "" {
+		if id, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return id
+		}
+		log.Printf("Warning: Invalid SIWE_CHAIN_ID %q, using Sepolia", v)
+	}
+	return 11155111
+}
+
+// verifyWalletSignature - check a signed SIWE message and consume its nonce
+// returns the verified message, or an error that is safe to show to the client (errSIWEDisabled if SIWE_DOMAIN is unset)
+func (handler *RequestHandler) verifyWalletSignature(message, signature string) (*auth.SIWEMessage, error) {
+	domains := siweDomains()
+	if len(domains) == 0 {
+		return nil, errSIWEDisabled
+	}
+	msg, err := auth.ParseSIWEMessage(message)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SIWE message: %w", err)
+	}
+	domain := domains[0]
+	for _, allowed := range domains {
+		if strings.EqualFold(allowed, msg.Domain) {
+			domain = allowed
+		}
+	}
+	if err := msg.Validate(domain, handler.siweChainID(), time.Now()); err != nil {
+		return nil, fmt.Errorf("invalid SIWE message: %w", err)
+	}
+	if err := auth.VerifyWalletSignature(message, signature, msg.Address); err != nil {
+		return nil, err
+	}
+
+	// consumed last, so a bad signature doesn't burn the nonce of the real wallet owner
+	ok, err := handler.db.ConsumeAuthNonce(msg.Nonce)
+	if err != nil {
+		log.Printf("Error: Failed to consume SIWE nonce: %v", err)
+		return nil, errors.New("server error")
+	}
+	if !ok {
+		return nil, errors.New("nonce is invalid, expired or already used")
+	}
+	return msg, nil
+}
+
+// GetAuthNonce handles GET /auth/nonce
+// Issues a single-use nonce to embed in a Sign-In with Ethereum message
+func (handler *RequestHandler) GetAuthNonce(w http.ResponseWriter, r *http.Request) {
+	domains := siweDomains()
+	if len(domains) == 0 {
+		http.Error(w, "Wallet sign-in is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+	nonce := hex.EncodeToString(buf)
+	expiresAt := time.Now().Add(nonceTTL)
+
+	if err := handler.db.CreateAuthNonce(nonce, expiresAt); err != nil {
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	render.JSON(w, r, map[string]any{
+		"nonce":      nonce,
+		"expires_at": expiresAt,
+		"domain":     domains[0],
+		"domains":    domains,
+		"chain_id":   handler.siweChainID(),
+	})
+}
+
+// LoginWallet handles POST /login/wallet
+// Verifies a signed SIWE message and logs in the user registered with that wallet
+func (handler *RequestHandler) LoginWallet(w http.ResponseWriter, r *http.Request) {
+	var req auth.WalletSignaturePayload
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		http.Error(w, "Validation Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	msg, err := handler.verifyWalletSignature(req.Message, req.Signature)
+	if errors.Is(err, errSIWEDisabled) {
+		http.Error(w, "Wallet sign-in is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	user, err := handler.db.GetUserByWalletAddress(msg.Address.Hex())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "No account registered for this wallet", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Database Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if requireEmailVerification() && user.EmailVerifiedAt == nil {
+		http.Error(w, "Email address not verified", http.StatusForbidden)
+		return
+	}
+
+	if user.TOTPEnabledAt != nil {
+		handler.respondMFAChallenge(w, r, user)
+		return
+	}
+
+	tokens, err := handler.startSession(r, user, false)
+	if err != nil {
+		log.Printf("Error: Failed to start session: %v", err)
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Success: Wallet login for user %s (%s)", user.Email, msg.Address.Hex())
+	render.JSON(w, r, loginResponse(user, tokens))
+}
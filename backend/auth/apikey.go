@@ -0,0 +1,98 @@
+// auth apikey - API keys of service accounts, accepted by Middleware next to JWTs
+package auth
+
+import (
+	"backend/db/models"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyPrefix - start of every API key, tells them apart from JWTs in the Authorization header
+const APIKeyPrefix = "rwa_"
+
+// APIKeyStore - where Middleware looks up API keys
+type APIKeyStore interface {
+	// GetActiveAPIKey returns the key with this hash unless it is revoked, expired or its service account is disabled
+	GetActiveAPIKey(keyHash string) (key models.APIKey, found bool, err error)
+	// TouchAPIKey records that the key was just used
+	TouchAPIKey(id uuid.UUID, ip string) error
+}
+
+var apiKeys APIKeyStore
+
+// SetAPIKeyStore - install the store Middleware checks API keys against, without one API keys are refused
+func SetAPIKeyStore(store APIKeyStore) {
+	apiKeys = store
+}
+
+// NewAPIKey - random API key to show once, the prefix to identify it by and the hash to store
+func NewAPIKey() (key, prefix, hash string, err error) {
+	token, _, err := NewOpaqueToken()
+	if err != nil {
+		return "", "", "", err
+	}
+	key = APIKeyPrefix + token
+	return key, key[:len(APIKeyPrefix)+8], HashOpaqueToken(key), nil
+}
+
+// IsAPIKey - whether the request was authenticated with an API key rather than a user token
+func (c *Claims) IsAPIKey() bool {
+	return c.APIKeyID != uuid.Nil
+}
+
+// HasScope - whether an API key request holds scope
+func (c *Claims) HasScope(scope models.APIKeyScope) bool {
+	return slices.Contains(c.Scopes, scope)
+}
+
+// apiKeyFromRequest - API key sent as X-API-Key or as a bearer token, "" if the request carries none
+func apiKeyFromRequest(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "); strings.HasPrefix(token, APIKeyPrefix) {
+		return token
+	}
+	return ""
+}
+
+// authenticateAPIKey - claims of a valid API key, writes the error response and returns nil otherwise
+// the service account stands in as UserID, so routes that act as a user must reject IsAPIKey claims
+func authenticateAPIKey(w http.ResponseWriter, r *http.Request, rawKey string) *Claims {
+	if apiKeys == nil {
+		http.Error(w, "API keys are not enabled", http.StatusUnauthorized)
+		return nil
+	}
+
+	key, found, err := apiKeys.GetActiveAPIKey(HashOpaqueToken(rawKey))
+	if err != nil {
+		log.Printf("Error: Failed to look up API key: %v", err)
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return nil
+	}
+	if !found {
+		http.Error(w, "Invalid API key", http.StatusUnauthorized)
+		return nil
+	}
+
+	// read-only keys may not change anything, write scopes are checked by the route
+	if (r.Method == http.MethodGet || r.Method == http.MethodHead) && !slices.Contains(key.Scopes, models.ScopeRead) {
+		http.Error(w, "Forbidden: API key lacks the read scope", http.StatusForbidden)
+		return nil
+	}
+
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	if err := apiKeys.TouchAPIKey(key.ID, ip); err != nil {
+		log.Printf("Warning: Failed to record API key use: %v", err)
+	}
+
+	return &Claims{UserID: key.ServiceAccountID, APIKeyID: key.ID, Scopes: key.Scopes}
+}
@@ -0,0 +1,287 @@
+// auth keys - JWT signing keys loaded from configuration, with key IDs for rotation
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey - one JWT key, identified by the kid header of the tokens it signed
+// Sign is nil for verify-only keys (retired keys, or keys whose private half lives elsewhere)
+type SigningKey struct {
+	ID     string
+	Method jwt.SigningMethod
+	Sign   any // []byte for HS256, *rsa.PrivateKey for RS256, ed25519.PrivateKey for EdDSA
+	Verify any // []byte for HS256, *rsa.PublicKey for RS256, ed25519.PublicKey for EdDSA
+}
+
+// KeySet - all keys tokens are accepted from, and the one new tokens are signed with
+type KeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+	order  []string
+}
+
+// keyConfig - entry of the JWT_KEYS_FILE JSON array
+// HS256 keys take a base64 secret, RS256 and EdDSA keys take PEM files
+type keyConfig struct {
+	ID             string `json:"kid"`
+	Alg            string `json:"alg"`
+	Secret         string `json:"secret,omitempty"`
+	PrivateKeyFile string `json:"private_key_file,omitempty"`
+	PublicKeyFile  string `json:"public_key_file,omitempty"`
+}
+
+var keys *KeySet
+
+// LoadKeysEnv - load signing keys from environment
+// JWT_KEYS_FILE points to a JSON array of keys and JWT_ACTIVE_KID selects the one that signs,
+// otherwise JWT_SECRET is used as a single HS256 key (kid "default")
+// without either, a random secret is generated and tokens don't survive a restart
+func LoadKeysEnv() error {
+	var set *KeySet
+	var err error
+
+	switch {
+	case os.Getenv("JWT_KEYS_FILE") != "":
+		set, err = loadKeyFile(os.Getenv("JWT_KEYS_FILE"), os.Getenv("JWT_ACTIVE_KID"))
+	case os.Getenv("JWT_SECRET") != "":
+		set, err = secretKeySet(os.Getenv("JWT_SECRET"))
+	default:
+		log.Printf("Warning: JWT_KEYS_FILE and JWT_SECRET not set, using a random key, tokens are invalidated on restart")
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return err
+		}
+		set = newKeySet()
+		set.add(&SigningKey{ID: "ephemeral", Method: jwt.SigningMethodHS256, Sign: secret, Verify: secret})
+		set.active = set.keys["ephemeral"]
+	}
+	if err != nil {
+		return err
+	}
+
+	keys = set
+	log.Printf("Info: Loaded %d JWT key(s), signing with %s (%s)", len(set.keys), set.active.ID, set.active.Method.Alg())
+	return nil
+}
+
+func newKeySet() *KeySet {
+	return &KeySet{keys: map[string]*SigningKey{}}
+}
+
+func (ks *KeySet) add(key *SigningKey) error {
+	if key.ID == "" {
+		return errors.New("JWT key without kid")
+	}
+	if _, exists := ks.keys[key.ID]; exists {
+		return fmt.Errorf("duplicate JWT kid %q", key.ID)
+	}
+	ks.keys[key.ID] = key
+	ks.order = append(ks.order, key.ID)
+	return nil
+}
+
+func secretKeySet(secret string) (*KeySet, error) {
+	if len(secret) < 32 {
+		return nil, errors.New("JWT_SECRET must be at least 32 characters")
+	}
+	set := newKeySet()
+	set.add(&SigningKey{ID: "default", Method: jwt.SigningMethodHS256, Sign: []byte(secret), Verify: []byte(secret)})
+	set.active = set.keys["default"]
+	return set, nil
+}
+
+func loadKeyFile(path, activeID string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT_KEYS_FILE: %w", err)
+	}
+	var configs []keyConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse JWT_KEYS_FILE: %w", err)
+	}
+
+	set := newKeySet()
+	for _, cfg := range configs {
+		key, err := parseKey(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("JWT key %q: %w", cfg.ID, err)
+		}
+		if err := set.add(key); err != nil {
+			return nil, err
+		}
+	}
+
+	// default to the first key that can sign
+	if activeID == "" {
+		for _, id := range set.order {
+			if set.keys[id].Sign != nil {
+				activeID = id
+				break
+			}
+		}
+	}
+	active, ok := set.keys[activeID]
+	if !ok || active.Sign == nil {
+		return nil, fmt.Errorf("no signing key %q in JWT_KEYS_FILE", activeID)
+	}
+	set.active = active
+	return set, nil
+}
+
+func parseKey(cfg keyConfig) (*SigningKey, error) {
+	key := &SigningKey{ID: cfg.ID}
+
+	switch cfg.Alg {
+	case "HS256":
+		secret, err := base64.StdEncoding.DecodeString(cfg.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("secret must be base64: %w", err)
+		}
+		if len(secret) < 32 {
+			return nil, errors.New("HS256 secret must be at least 32 bytes")
+		}
+		key.Method = jwt.SigningMethodHS256
+		key.Sign, key.Verify = secret, secret
+
+	case "RS256":
+		key.Method = jwt.SigningMethodRS256
+		if cfg.PrivateKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			private, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			key.Sign, key.Verify = private, &private.PublicKey
+		} else {
+			pem, err := os.ReadFile(cfg.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			public, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			key.Verify = public
+		}
+
+	case "EdDSA":
+		key.Method = jwt.SigningMethodEdDSA
+		if cfg.PrivateKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			private, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			edPrivate := private.(ed25519.PrivateKey)
+			key.Sign, key.Verify = edPrivate, edPrivate.Public().(ed25519.PublicKey)
+		} else {
+			pem, err := os.ReadFile(cfg.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			public, err := jwt.ParseEdPublicKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			key.Verify = public.(ed25519.PublicKey)
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported alg %q, use HS256, RS256 or EdDSA", cfg.Alg)
+	}
+
+	return key, nil
+}
+
+// signToken - sign claims with the active key and set its kid header
+func signToken(claims jwt.Claims) (string, error) {
+	if keys == nil {
+		return "", errors.New("JWT keys not loaded")
+	}
+	token := jwt.NewWithClaims(keys.active.Method, claims)
+	token.Header["kid"] = keys.active.ID
+	return token.SignedString(keys.active.Sign)
+}
+
+// parseToken - verify a token with the key named by its kid header
+// the key's own algorithm is enforced, so an HS256 token can't be passed off against a public key
+func parseToken(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	if keys == nil {
+		return nil, errors.New("JWT keys not loaded")
+	}
+	return jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := keys.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown kid %q", kid)
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s for kid %q", token.Method.Alg(), kid)
+		}
+		return key.Verify, nil
+	}, jwt.WithValidMethods([]string{"HS256", "RS256", "EdDSA"}))
+}
+
+// JWK - public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA exponent
+	Crv string `json:"crv,omitempty"` // OKP curve
+	X   string `json:"x,omitempty"`   // OKP public key
+}
+
+// JWKS - public keys other services can verify tokens with
+// HS256 secrets are never published, so an HS256-only deployment returns an empty set
+func JWKS() []JWK {
+	set := []JWK{}
+	if keys == nil {
+		return set
+	}
+
+	for _, id := range keys.order {
+		key := keys.keys[id]
+		switch public := key.Verify.(type) {
+		case *rsa.PublicKey:
+			set = append(set, JWK{
+				Kty: "RSA",
+				Kid: key.ID,
+				Alg: key.Method.Alg(),
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set = append(set, JWK{
+				Kty: "OKP",
+				Kid: key.ID,
+				Alg: key.Method.Alg(),
+				Use: "sig",
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+	return set
+}
@@ -2,15 +2,14 @@
 package auth
 
 import (
+	"backend/db/models"
+	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
-// jwtKey - secret key for signing JWT tokens
-var jwtKey = []byte("7umbl2i2Zy6AR6HjVaE2jN2RBmtzAZXrY4CgHmIFls4=")
-
 // LoginCredentials - struct for login request data
 type LoginCredentials struct {
 	Email    string `json:"email" binding:"required" validate:"required,email"`
@@ -18,9 +17,17 @@ type LoginCredentials struct {
 }
 
 // Claims - JWT token payload structure
+// RegisteredClaims.ID is the jti checked against the revocation store,
+// SessionID is the refresh token family the access token was issued for,
+// MFA is set when the login completed a second factor,
+// for API key requests UserID is the service account and only APIKeyID and Scopes are set besides it
 type Claims struct {
-	UserID    uuid.UUID `json:"userId"`
-	UserEmail string    `json:"userEmail"`
+	UserID    uuid.UUID            `json:"userId"`
+	UserEmail string               `json:"userEmail"`
+	SessionID uuid.UUID            `json:"sid"`
+	MFA       bool                 `json:"mfa,omitempty"`
+	APIKeyID  uuid.UUID            `json:"-"`
+	Scopes    []models.APIKeyScope `json:"-"`
 	jwt.RegisteredClaims
 }
 
@@ -31,18 +38,54 @@ type ClaimsKeyType int
 var TokenKey TokenKeyType
 var ClaimsKey ClaimsKeyType
 
-// GenerateToken - create JWT token for authenticated user
-func GenerateToken(id uuid.UUID, email string) (string, error) {
-	expirationTime := time.Now().Add(15 * time.Minute)
+// AccessTokenTTL - lifetime of an access token, clients renew it through /auth/refresh
+const AccessTokenTTL = 15 * time.Minute
+
+// MFATokenTTL - time a user has to enter the second factor after the password was accepted
+const MFATokenTTL = 5 * time.Minute
+
+// GenerateToken - create JWT token for authenticated user within a session
+func GenerateToken(id uuid.UUID, email string, sessionID uuid.UUID, mfa bool) (string, error) {
+	now := time.Now()
 	claims := &Claims{
 		UserID:    id,
 		UserEmail: email,
+		SessionID: sessionID,
+		MFA:       mfa,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			Subject:   "access",
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
+		},
+	}
+
+	return signToken(claims)
+}
+
+// GenerateMFAToken - short-lived token proving the first factor passed, exchanged at /login/2fa
+// its subject is "mfa", so Middleware never accepts it as an access token
+func GenerateMFAToken(id uuid.UUID, email string) (string, error) {
+	now := time.Now()
+	claims := &Claims{
+		UserID:    id,
+		UserEmail: email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   "mfa",
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(MFATokenTTL)),
 		},
 	}
+	return signToken(claims)
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
+// ParseMFAToken - verify a token from GenerateMFAToken
+func ParseMFAToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := parseToken(tokenString, claims)
+	if err != nil || !token.Valid || claims.Subject != "mfa" {
+		return nil, errors.New("invalid or expired mfa token")
+	}
+	return claims, nil
 }
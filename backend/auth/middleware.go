@@ -3,17 +3,25 @@ package auth
 
 import (
 	"context"
+	"log"
 	"net/http"
 	"strings"
-	"time"
-
-	"github.com/golang-jwt/jwt/v5"
 )
 
-// Middleware - JWT authentication middleware
-// validates bearer token and adds claims to request context
+// Middleware - JWT and API key authentication middleware
+// validates bearer token, rejects revoked tokens and adds claims to request context
+// API keys (X-API-Key header, or a bearer token starting with APIKeyPrefix) are accepted too
 func Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rawKey := apiKeyFromRequest(r); rawKey != "" {
+			claims := authenticateAPIKey(w, r, rawKey)
+			if claims == nil {
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ClaimsKey, claims)))
+			return
+		}
+
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
 			http.Error(w, "Authorization header required", http.StatusUnauthorized)
@@ -22,21 +30,25 @@ func Middleware(next http.Handler) http.Handler {
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 
 		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
-			return jwtKey, nil
-		})
+		token, err := parseToken(tokenString, claims)
 
-		if err != nil || !token.Valid {
+		// tokens without a jti predate server-side sessions and can't be revoked,
+		// mfa challenge tokens only work at /login/2fa
+		if err != nil || !token.Valid || claims.ID == "" || claims.Subject != "access" {
 			http.Error(w, "Invalid token", http.StatusUnauthorized)
 			return
 		}
 
-		timeUntilExpiry := time.Until(claims.ExpiresAt.Time)
-
-		if timeUntilExpiry < 2*time.Minute {
-			newToken, err := GenerateToken(claims.UserID, claims.UserEmail)
-			if err == nil {
-				w.Header().Set("X-Access-Token", newToken)
+		if revocations != nil {
+			revoked, err := revocations.IsTokenRevoked(claims.ID, claims.SessionID)
+			if err != nil {
+				log.Printf("Error: Failed to check token revocation: %v", err)
+				http.Error(w, "Server Error", http.StatusInternalServerError)
+				return
+			}
+			if revoked {
+				http.Error(w, "Token revoked", http.StatusUnauthorized)
+				return
 			}
 		}
 
@@ -0,0 +1,264 @@
+// auth ratelimit - request rate limits and exponential lockout against password guessing
+package auth
+
+import (
+	"backend/db/models"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AttemptStore - where rate limit counters and lockouts are kept
+// *db.Database keeps them in Postgres (shared by every instance), MemoryAttemptStore in process
+type AttemptStore interface {
+	// Hit counts one request against key in the fixed window containing now, returns the count and when the window ends
+	Hit(key string, window time.Duration, now time.Time) (count int, resetAt time.Time, err error)
+	// AddFailure counts one failed attempt against key, the count restarts when the last failure is older than resetAfter
+	AddFailure(key string, now time.Time, resetAfter time.Duration) (models.AuthLockout, error)
+	LockUntil(key string, until time.Time) error
+	GetLockout(key string) (lockout models.AuthLockout, found bool, err error)
+	// ListLockouts returns the keys that failed since the given time, most recent first
+	ListLockouts(since time.Time) ([]models.AuthLockout, error)
+	ClearLockout(key string) error
+}
+
+// LimiterConfig - thresholds of a Limiter
+type LimiterConfig struct {
+	RequestsPerMinute int           // requests per IP to the login and registration routes
+	AccountThreshold  int           // failed logins before an account is locked
+	IPThreshold       int           // failed logins before an IP is locked, higher since IPs are shared
+	BaseLockout       time.Duration // first lock, doubled with every further failure
+	MaxLockout        time.Duration
+	ResetAfter        time.Duration // failures are forgotten this long after the last one
+}
+
+// LimiterConfigEnv - LimiterConfig from the environment
+// RATE_LIMIT_PER_MINUTE (20), LOCKOUT_ACCOUNT_THRESHOLD (5), LOCKOUT_IP_THRESHOLD (20),
+// LOCKOUT_BASE_SECONDS (30), LOCKOUT_MAX_SECONDS (3600), LOCKOUT_RESET_MINUTES (60)
+func LimiterConfigEnv() LimiterConfig {
+	return LimiterConfig{
+		RequestsPerMinute: envInt("RATE_LIMIT_PER_MINUTE", 20),
+		AccountThreshold:  envInt("LOCKOUT_ACCOUNT_THRESHOLD", 5),
+		IPThreshold:       envInt("LOCKOUT_IP_THRESHOLD", 20),
+		BaseLockout:       time.Duration(envInt("LOCKOUT_BASE_SECONDS", 30)) * time.Second,
+		MaxLockout:        time.Duration(envInt("LOCKOUT_MAX_SECONDS", 3600)) * time.Second,
+		ResetAfter:        time.Duration(envInt("LOCKOUT_RESET_MINUTES", 60)) * time.Minute,
+	}
+}
+
+func envInt(name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		log.Printf("Warning: Invalid %s %q, using default %d", name, v, def)
+		return def
+	}
+	return n
+}
+
+// IPKey - lockout and rate limit key of a client address
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// AccountKey - lockout key of an account, by login email
+func AccountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// Limiter - per-IP request rate limit and per-IP / per-account lockout after failed logins
+type Limiter struct {
+	store AttemptStore
+	cfg   LimiterConfig
+}
+
+// NewLimiter - create a Limiter on top of store
+func NewLimiter(store AttemptStore, cfg LimiterConfig) *Limiter {
+	return &Limiter{store: store, cfg: cfg}
+}
+
+// Allow - count a request from ip, returns how long to wait when it is over the limit (0 if allowed)
+func (l *Limiter) Allow(ip string) (time.Duration, error) {
+	now := time.Now()
+	count, resetAt, err := l.store.Hit("rate:"+ip, time.Minute, now)
+	if err != nil || count <= l.cfg.RequestsPerMinute {
+		return 0, err
+	}
+	return resetAt.Sub(now), nil
+}
+
+// Locked - how long until every given key is unlocked, 0 if none is locked
+func (l *Limiter) Locked(keys ...string) (time.Duration, error) {
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range keys {
+		lockout, found, err := l.store.GetLockout(key)
+		if err != nil {
+			return 0, err
+		}
+		if found && lockout.LockedUntil != nil && lockout.LockedUntil.Sub(now) > wait {
+			wait = lockout.LockedUntil.Sub(now)
+		}
+	}
+	return wait, nil
+}
+
+// Fail - record a failed login from ip against account
+// once a key reaches its threshold it is locked, each further failure doubles the lock up to MaxLockout
+func (l *Limiter) Fail(ip, account string) error {
+	if err := l.fail(IPKey(ip), l.cfg.IPThreshold); err != nil {
+		return err
+	}
+	return l.fail(AccountKey(account), l.cfg.AccountThreshold)
+}
+
+func (l *Limiter) fail(key string, threshold int) error {
+	now := time.Now()
+	lockout, err := l.store.AddFailure(key, now, l.cfg.ResetAfter)
+	if err != nil || lockout.Failures < threshold {
+		return err
+	}
+
+	lock := lockDuration(l.cfg.BaseLockout, l.cfg.MaxLockout, lockout.Failures-threshold)
+	log.Printf("Warning: %s locked for %s after %d failed logins", key, lock, lockout.Failures)
+	return l.store.LockUntil(key, now.Add(lock))
+}
+
+// lockDuration - base doubled once per failure past the threshold, capped at ceiling
+// doubled step by step rather than shifted, a large shift overflows time.Duration
+func lockDuration(base, ceiling time.Duration, extra int) time.Duration {
+	lock := base
+	for ; extra > 0 && lock < ceiling; extra-- {
+		lock *= 2
+	}
+	return min(lock, ceiling)
+}
+
+// Succeeded - forget the failures of an account after a complete login
+// the IP key is kept, so logging into one's own account doesn't reset guessing at others
+func (l *Limiter) Succeeded(account string) error {
+	return l.store.ClearLockout(AccountKey(account))
+}
+
+// Lockouts - keys with failures that haven't been forgotten yet
+func (l *Limiter) Lockouts() ([]models.AuthLockout, error) {
+	return l.store.ListLockouts(time.Now().Add(-l.cfg.ResetAfter))
+}
+
+// Clear - remove a lockout and its failure count
+func (l *Limiter) Clear(key string) error {
+	return l.store.ClearLockout(key)
+}
+
+type rateCounter struct {
+	windowStart time.Time
+	count       int
+}
+
+// MemoryAttemptStore - AttemptStore kept in process, for single instance deployments
+// counters are lost on restart
+type MemoryAttemptStore struct {
+	mu        sync.Mutex
+	counters  map[string]*rateCounter
+	lockouts  map[string]*models.AuthLockout
+	lastPrune time.Time
+}
+
+// NewMemoryAttemptStore - create an empty MemoryAttemptStore
+func NewMemoryAttemptStore() *MemoryAttemptStore {
+	return &MemoryAttemptStore{
+		counters: make(map[string]*rateCounter),
+		lockouts: make(map[string]*models.AuthLockout),
+	}
+}
+
+func (s *MemoryAttemptStore) Hit(key string, window time.Duration, now time.Time) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// finished windows are dropped once a minute so the map doesn't grow with every client
+	if now.Sub(s.lastPrune) > time.Minute {
+		for k, c := range s.counters {
+			if now.Sub(c.windowStart) >= window {
+				delete(s.counters, k)
+			}
+		}
+		s.lastPrune = now
+	}
+
+	c, ok := s.counters[key]
+	if !ok || now.Sub(c.windowStart) >= window {
+		c = &rateCounter{windowStart: now.Truncate(window)}
+		s.counters[key] = c
+	}
+	c.count++
+	return c.count, c.windowStart.Add(window), nil
+}
+
+func (s *MemoryAttemptStore) AddFailure(key string, now time.Time, resetAfter time.Duration) (models.AuthLockout, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, l := range s.lockouts {
+		if now.Sub(l.LastFailureAt) > resetAfter && (l.LockedUntil == nil || l.LockedUntil.Before(now)) {
+			delete(s.lockouts, k)
+		}
+	}
+
+	l, ok := s.lockouts[key]
+	if !ok {
+		l = &models.AuthLockout{Key: key}
+		s.lockouts[key] = l
+	}
+	l.Failures++
+	l.LastFailureAt = now
+	l.UpdatedAt = now
+	return *l, nil
+}
+
+func (s *MemoryAttemptStore) LockUntil(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l, ok := s.lockouts[key]; ok {
+		l.LockedUntil = &until
+		l.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+func (s *MemoryAttemptStore) GetLockout(key string) (models.AuthLockout, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l, ok := s.lockouts[key]; ok {
+		return *l, true, nil
+	}
+	return models.AuthLockout{}, false, nil
+}
+
+func (s *MemoryAttemptStore) ListLockouts(since time.Time) ([]models.AuthLockout, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []models.AuthLockout
+	for _, l := range s.lockouts {
+		if !l.LastFailureAt.Before(since) {
+			result = append(result, *l)
+		}
+	}
+	// most recent failure first, like the Postgres store
+	slices.SortFunc(result, func(a, b models.AuthLockout) int { return b.LastFailureAt.Compare(a.LastFailureAt) })
+	return result, nil
+}
+
+func (s *MemoryAttemptStore) ClearLockout(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.lockouts, key)
+	return nil
+}
@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockDuration(t *testing.T) {
+	base, ceiling := 30*time.Second, time.Hour
+	tests := []struct {
+		extra int
+		want  time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, time.Minute},
+		{3, 4 * time.Minute},
+		{6, 32 * time.Minute},
+		{7, time.Hour}, // 64 minutes, capped
+		{29, time.Hour},
+		{30, time.Hour},
+		{64, time.Hour},
+		{1 << 20, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := lockDuration(base, ceiling, tt.extra); got != tt.want {
+			t.Errorf("lockDuration(%d) = %s, want %s", tt.extra, got, tt.want)
+		}
+	}
+
+	if got := lockDuration(2*time.Hour, ceiling, 0); got != ceiling {
+		t.Errorf("base above the ceiling = %s, want %s", got, ceiling)
+	}
+}
@@ -8,4 +8,7 @@ type RegisterUserPayload struct {
 	Email         string `json:"email" binding:"required" validate:"required,email"`
 	Password      string `json:"password" binding:"required" validate:"required,min=8"`
 	Name          string `json:"name" validate:"required,min=2"`
+	// SIWE message and signature proving control of WalletAddress, nonce from GET /auth/nonce
+	SiweMessage   string `json:"siwe_message" validate:"required"`
+	SiweSignature string `json:"siwe_signature" validate:"required"`
 }
@@ -0,0 +1,59 @@
+// auth session - refresh tokens and server-side revocation of access tokens
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RevocationStore - server-side session state consulted by Middleware on every request
+// a token is revoked when its jti was logged out or its session (refresh family) was revoked
+type RevocationStore interface {
+	IsTokenRevoked(jti string, sessionID uuid.UUID) (bool, error)
+}
+
+var revocations RevocationStore
+
+// SetRevocationStore - install the store Middleware checks tokens against
+func SetRevocationStore(store RevocationStore) {
+	revocations = store
+}
+
+// RefreshTokenTTL - lifetime of a refresh token, REFRESH_TOKEN_TTL in hours (default 30 days)
+// each refresh rotates the token, so this bounds how long a session can stay idle
+func RefreshTokenTTL() time.Duration {
+	hours := 720
+	if v := os.Getenv("REFRESH_TOKEN_TTL"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			hours = n
+		} else {
+			log.Printf("Warning: Invalid REFRESH_TOKEN_TTL %q, using default %d", v, hours)
+		}
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// NewOpaqueToken - random token (refresh, email verification, password reset) and the hash stored in the database
+// only the hash is persisted, the token itself is handed to the client once
+func NewOpaqueToken() (token string, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashOpaqueToken(token), nil
+}
+
+// HashOpaqueToken - SHA-256 of an opaque token, used to look it up
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,168 @@
+// auth siwe - Sign-In with Ethereum (EIP-4361) message parsing and signature verification
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const siweHeaderSuffix = " wants you to sign in with your Ethereum account:"
+
+// SIWEMessage - fields of a signed EIP-4361 message
+type SIWEMessage struct {
+	Domain         string
+	Address        common.Address
+	Statement      string
+	URI            string
+	Version        string
+	ChainID        int64
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+	RequestID      string
+	Resources      []string
+}
+
+// WalletSignaturePayload - SIWE message and the wallet's personal_sign signature of it
+type WalletSignaturePayload struct {
+	Message   string `json:"message" validate:"required"`
+	Signature string `json:"signature" validate:"required"`
+}
+
+// ParseSIWEMessage - parse the plain-text EIP-4361 format
+func ParseSIWEMessage(raw string) (*SIWEMessage, error) {
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+	if len(lines) < 3 || !strings.HasSuffix(lines[0], siweHeaderSuffix) {
+		return nil, errors.New("not a sign-in with ethereum message")
+	}
+
+	msg := &SIWEMessage{Domain: strings.TrimSuffix(lines[0], siweHeaderSuffix)}
+	if i := strings.Index(msg.Domain, "://"); i >= 0 {
+		msg.Domain = msg.Domain[i+3:]
+	}
+
+	// the spec requires the EIP-55 checksummed form
+	if !common.IsHexAddress(lines[1]) || common.HexToAddress(lines[1]).Hex() != lines[1] {
+		return nil, errors.New("address must be a checksummed ethereum address")
+	}
+	msg.Address = common.HexToAddress(lines[1])
+
+	// optional statement between the address and the first field
+	i := 2
+	var statement []string
+	for ; i < len(lines) && !strings.HasPrefix(lines[i], "URI: "); i++ {
+		if lines[i] != "" {
+			statement = append(statement, lines[i])
+		}
+	}
+	msg.Statement = strings.Join(statement, "\n")
+
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if line == "" {
+			continue
+		}
+		if line == "Resources:" {
+			for i++; i < len(lines) && strings.HasPrefix(lines[i], "- "); i++ {
+				msg.Resources = append(msg.Resources, strings.TrimPrefix(lines[i], "- "))
+			}
+			i--
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			return nil, fmt.Errorf("malformed line %q", line)
+		}
+		var err error
+		switch key {
+		case "URI":
+			msg.URI = value
+		case "Version":
+			msg.Version = value
+		case "Chain ID":
+			msg.ChainID, err = strconv.ParseInt(value, 10, 64)
+		case "Nonce":
+			msg.Nonce = value
+		case "Issued At":
+			msg.IssuedAt, err = time.Parse(time.RFC3339, value)
+		case "Expiration Time":
+			var t time.Time
+			t, err = time.Parse(time.RFC3339, value)
+			msg.ExpirationTime = &t
+		case "Not Before":
+			var t time.Time
+			t, err = time.Parse(time.RFC3339, value)
+			msg.NotBefore = &t
+		case "Request ID":
+			msg.RequestID = value
+		default:
+			return nil, fmt.Errorf("unknown field %q", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+
+	if msg.URI == "" || msg.Version == "" || msg.ChainID == 0 || msg.Nonce == "" || msg.IssuedAt.IsZero() {
+		return nil, errors.New("message is missing URI, Version, Chain ID, Nonce or Issued At")
+	}
+	return msg, nil
+}
+
+// Validate - check the message was meant for this server, chain and moment
+// the nonce is only checked for presence here, the caller consumes it from its store
+func (m *SIWEMessage) Validate(domain string, chainID int64, now time.Time) error {
+	if m.Version != "1" {
+		return fmt.Errorf("unsupported version %q", m.Version)
+	}
+	if !strings.EqualFold(m.Domain, domain) {
+		return fmt.Errorf("message is for domain %q, expected %q", m.Domain, domain)
+	}
+	if m.ChainID != chainID {
+		return fmt.Errorf("message is for chain %d, expected %d", m.ChainID, chainID)
+	}
+	if m.ExpirationTime != nil && !now.Before(*m.ExpirationTime) {
+		return errors.New("message has expired")
+	}
+	if m.NotBefore != nil && now.Before(*m.NotBefore) {
+		return errors.New("message is not valid yet")
+	}
+	// a little clock skew between wallet and server is tolerated
+	if m.IssuedAt.After(now.Add(time.Minute)) {
+		return errors.New("message is issued in the future")
+	}
+	return nil
+}
+
+// VerifyWalletSignature - recover the signer of a personal_sign (EIP-191) signature over message
+// and check it is address, only externally owned accounts can sign this way
+func VerifyWalletSignature(message string, signature string, address common.Address) error {
+	sig, err := hexutil.Decode(signature)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return errors.New("signature must be 65 bytes of hex")
+	}
+	// wallets return v as 27/28, Ecrecover expects 0/1
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+
+	pub, err := crypto.Ecrecover(accounts.TextHash([]byte(message)), sig)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	signer := common.BytesToAddress(crypto.Keccak256(pub[1:])[12:])
+	if signer != address {
+		return errors.New("signature does not match the wallet address")
+	}
+	return nil
+}
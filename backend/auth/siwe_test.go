@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const siweAddress = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed" // EIP-55 checksummed
+
+// siweLines - a complete message, line by line, for the cases below to modify
+func siweLines() []string {
+	return []string{
+		"app.example.com wants you to sign in with your Ethereum account:",
+		siweAddress,
+		"",
+		"Sign in to the property platform.",
+		"",
+		"URI: https://app.example.com/login",
+		"Version: 1",
+		"Chain ID: 11155111",
+		"Nonce: 32891756",
+		"Issued At: 2026-01-01T12:00:00Z",
+		"Expiration Time: 2026-01-01T12:10:00Z",
+		"Not Before: 2026-01-01T11:59:00Z",
+		"Request ID: req-1",
+		"Resources:",
+		"- ipfs://bafybeiemxf5abjwjbikoz4mc3a3dla6ual3jsgpdr4cjr3oz3evfyavhwq",
+		"- https://example.com/terms",
+	}
+}
+
+func TestParseSIWEMessage(t *testing.T) {
+	msg, err := ParseSIWEMessage(strings.Join(siweLines(), "\n"))
+	if err != nil {
+		t.Fatalf("ParseSIWEMessage: %v", err)
+	}
+
+	if msg.Domain != "app.example.com" || msg.Address.Hex() != siweAddress || msg.Statement != "Sign in to the property platform." {
+		t.Errorf("header = %q %s %q", msg.Domain, msg.Address.Hex(), msg.Statement)
+	}
+	if msg.URI != "https://app.example.com/login" || msg.Version != "1" || msg.ChainID != 11155111 || msg.Nonce != "32891756" || msg.RequestID != "req-1" {
+		t.Errorf("fields = %+v", msg)
+	}
+	if !msg.IssuedAt.Equal(time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("IssuedAt = %s", msg.IssuedAt)
+	}
+	if msg.ExpirationTime == nil || !msg.ExpirationTime.Equal(time.Date(2026, 1, 1, 12, 10, 0, 0, time.UTC)) {
+		t.Errorf("ExpirationTime = %v", msg.ExpirationTime)
+	}
+	if msg.NotBefore == nil || !msg.NotBefore.Equal(time.Date(2026, 1, 1, 11, 59, 0, 0, time.UTC)) {
+		t.Errorf("NotBefore = %v", msg.NotBefore)
+	}
+	if len(msg.Resources) != 2 || msg.Resources[1] != "https://example.com/terms" {
+		t.Errorf("Resources = %q", msg.Resources)
+	}
+}
+
+func TestParseSIWEMessageVariants(t *testing.T) {
+	tests := []struct {
+		name  string
+		edit  func([]string) []string
+		check func(*SIWEMessage) bool
+	}{
+		{"CRLF line endings", func(l []string) []string { return []string{strings.Join(l, "\r\n")} },
+			func(m *SIWEMessage) bool { return m.Nonce == "32891756" }},
+		{"scheme before the domain", func(l []string) []string { l[0] = "https://" + l[0]; return l },
+			func(m *SIWEMessage) bool { return m.Domain == "app.example.com" }},
+		{"no statement", func(l []string) []string { return append(l[:3], l[5:]...) },
+			func(m *SIWEMessage) bool { return m.Statement == "" && m.URI != "" }},
+		{"only required fields", func(l []string) []string { return l[:10] },
+			func(m *SIWEMessage) bool { return m.ExpirationTime == nil && m.NotBefore == nil && m.Resources == nil }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := ParseSIWEMessage(strings.Join(tt.edit(siweLines()), "\n"))
+			if err != nil {
+				t.Fatalf("ParseSIWEMessage: %v", err)
+			}
+			if !tt.check(msg) {
+				t.Errorf("unexpected message %+v", msg)
+			}
+		})
+	}
+}
+
+func TestParseSIWEMessageInvalid(t *testing.T) {
+	without := func(prefix string) func([]string) []string {
+		return func(l []string) []string {
+			var out []string
+			for _, line := range l {
+				if !strings.HasPrefix(line, prefix) {
+					out = append(out, line)
+				}
+			}
+			return out
+		}
+	}
+	replace := func(i int, line string) func([]string) []string {
+		return func(l []string) []string { l[i] = line; return l }
+	}
+
+	tests := []struct {
+		name string
+		edit func([]string) []string
+	}{
+		{"empty", func([]string) []string { return []string{""} }},
+		{"too short", func(l []string) []string { return l[:2] }},
+		{"wrong header", replace(0, "app.example.com asks you to sign in:")},
+		{"address not hex", replace(1, "0xnotanaddress")},
+		{"address not checksummed", replace(1, strings.ToLower(siweAddress))},
+		{"missing URI", without("URI: ")},
+		{"missing Version", without("Version: ")},
+		{"missing Chain ID", without("Chain ID: ")},
+		{"missing Nonce", without("Nonce: ")},
+		{"missing Issued At", without("Issued At: ")},
+		{"chain id not a number", replace(7, "Chain ID: sepolia")},
+		{"issued at not RFC 3339", replace(9, "Issued At: yesterday")},
+		{"expiration time not RFC 3339", replace(10, "Expiration Time: 2026-01-01")},
+		{"not before not RFC 3339", replace(11, "Not Before: soon")},
+		{"unknown field", replace(12, "Referrer: phishing.example")},
+		{"line without a value", replace(12, "Request ID")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if msg, err := ParseSIWEMessage(strings.Join(tt.edit(siweLines()), "\n")); err == nil {
+				t.Errorf("parsed %+v, want an error", msg)
+			}
+		})
+	}
+}
+
+func TestSIWEMessageValidate(t *testing.T) {
+	msg, err := ParseSIWEMessage(strings.Join(siweLines(), "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 1, 1, 12, 5, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		domain  string
+		chainID int64
+		now     time.Time
+		wantErr bool
+	}{
+		{"valid", "app.example.com", 11155111, now, false},
+		{"domain is case insensitive", "APP.example.com", 11155111, now, false},
+		{"other domain", "phishing.example", 11155111, now, true},
+		{"other chain", "app.example.com", 1, now, true},
+		{"expired", "app.example.com", 11155111, now.Add(10 * time.Minute), true},
+		{"not valid yet", "app.example.com", 11155111, time.Date(2026, 1, 1, 11, 58, 0, 0, time.UTC), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := msg.Validate(tt.domain, tt.chainID, tt.now); (err != nil) != tt.wantErr {
+				t.Errorf("Validate = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
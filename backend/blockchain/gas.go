@@ -0,0 +1,180 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var gwei = big.NewInt(1000000000)
+
+// GasConfig - fee caps and replacement policy for backend transactions
+type GasConfig struct {
+	MaxFeeCap       *big.Int      // upper bound for maxFeePerGas (or gasPrice on legacy chains), in wei
+	MaxTipCap       *big.Int      // upper bound for maxPriorityFeePerGas, in wei
+	LimitMargin     uint64        // percent added on top of EstimateGas
+	BumpPercent     uint64        // fee increase per replacement, nodes require at least 10
+	StuckAfter      time.Duration // time without a receipt before a transaction is replaced
+	MaxReplacements int           // replacements per transaction before giving up and just waiting
+}
+
+// NewGasConfigEnv - load gas settings from environment
+// GAS_MAX_FEE_GWEI, GAS_MAX_PRIORITY_FEE_GWEI, GAS_LIMIT_MARGIN_PERCENT,
+// GAS_BUMP_PERCENT, TX_STUCK_TIMEOUT (seconds), TX_MAX_REPLACEMENTS
+func NewGasConfigEnv() GasConfig {
+	cfg := GasConfig{
+		MaxFeeCap:       new(big.Int).Mul(big.NewInt(int64(envUint("GAS_MAX_FEE_GWEI", 200))), gwei),
+		MaxTipCap:       new(big.Int).Mul(big.NewInt(int64(envUint("GAS_MAX_PRIORITY_FEE_GWEI", 5))), gwei),
+		LimitMargin:     envUint("GAS_LIMIT_MARGIN_PERCENT", 20),
+		BumpPercent:     envUint("GAS_BUMP_PERCENT", 15),
+		StuckAfter:      time.Duration(envUint("TX_STUCK_TIMEOUT", 120)) * time.Second,
+		MaxReplacements: int(envUint("TX_MAX_REPLACEMENTS", 3)),
+	}
+	if cfg.BumpPercent < 10 {
+		log.Printf("Warning: GAS_BUMP_PERCENT must be at least 10 for nodes to accept a replacement, using 10")
+		cfg.BumpPercent = 10
+	}
+	return cfg
+}
+
+// gasFees - fee fields for one transaction
+// TipCap/FeeCap are set on EIP-1559 chains, GasPrice on legacy chains
+type gasFees struct {
+	TipCap   *big.Int
+	FeeCap   *big.Int
+	GasPrice *big.Int
+}
+
+// suggestFees - current EIP-1559 fees (maxFee = 2 * baseFee + tip), limited by the configured caps
+func (s *Signer) suggestFees(ctx context.Context) (gasFees, error) {
+	header, err := s.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return gasFees{}, err
+	}
+
+	if header.BaseFee == nil {
+		price, err := s.client.SuggestGasPrice(ctx)
+		if err != nil {
+			return gasFees{}, err
+		}
+		if price.Cmp(s.gas.MaxFeeCap) > 0 {
+			return gasFees{}, fmt.Errorf("gas price %s exceeds GAS_MAX_FEE_GWEI cap %s", price, s.gas.MaxFeeCap)
+		}
+		return gasFees{GasPrice: price}, nil
+	}
+
+	if header.BaseFee.Cmp(s.gas.MaxFeeCap) >= 0 {
+		return gasFees{}, fmt.Errorf("base fee %s exceeds GAS_MAX_FEE_GWEI cap %s", header.BaseFee, s.gas.MaxFeeCap)
+	}
+
+	tip, err := s.client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return gasFees{}, err
+	}
+	tip = minBig(tip, s.gas.MaxTipCap)
+
+	feeCap := new(big.Int).Mul(header.BaseFee, big.NewInt(2))
+	feeCap.Add(feeCap, tip)
+	feeCap = minBig(feeCap, s.gas.MaxFeeCap)
+	tip = minBig(tip, feeCap)
+
+	return gasFees{TipCap: tip, FeeCap: feeCap}, nil
+}
+
+// bumpFees - fees for a replacement of tx, at least BumpPercent above the original
+// and no lower than what the network currently suggests
+func (s *Signer) bumpFees(ctx context.Context, tx *types.Transaction) (gasFees, error) {
+	current, err := s.suggestFees(ctx)
+	if err != nil {
+		return gasFees{}, err
+	}
+
+	if tx.Type() == types.LegacyTxType {
+		suggested := current.GasPrice
+		if suggested == nil {
+			suggested = current.FeeCap
+		}
+		price := maxBig(bumpBig(tx.GasPrice(), s.gas.BumpPercent), suggested)
+		if price.Cmp(s.gas.MaxFeeCap) > 0 {
+			return gasFees{}, fmt.Errorf("replacement gas price %s exceeds GAS_MAX_FEE_GWEI cap", price)
+		}
+		return gasFees{GasPrice: price}, nil
+	}
+
+	// the tip cap is raised too, nodes require both fields to go up for a replacement
+	maxTip := maxBig(s.gas.MaxTipCap, bumpBig(tx.GasTipCap(), s.gas.BumpPercent))
+	tip := minBig(maxBig(bumpBig(tx.GasTipCap(), s.gas.BumpPercent), current.TipCap), maxTip)
+	feeCap := maxBig(bumpBig(tx.GasFeeCap(), s.gas.BumpPercent), current.FeeCap)
+	if feeCap.Cmp(s.gas.MaxFeeCap) > 0 {
+		return gasFees{}, fmt.Errorf("replacement max fee %s exceeds GAS_MAX_FEE_GWEI cap", feeCap)
+	}
+	return gasFees{TipCap: tip, FeeCap: feeCap}, nil
+}
+
+// withGas - copy of tx with a new gas limit and fees, unsigned
+func withGas(tx *types.Transaction, chainID *big.Int, gas uint64, fees gasFees) *types.Transaction {
+	if fees.GasPrice != nil {
+		return types.NewTx(&types.LegacyTx{
+			Nonce:    tx.Nonce(),
+			GasPrice: fees.GasPrice,
+			Gas:      gas,
+			To:       tx.To(),
+			Value:    tx.Value(),
+			Data:     tx.Data(),
+		})
+	}
+	return types.NewTx(&types.DynamicFeeTx{
+		ChainID:    chainID,
+		Nonce:      tx.Nonce(),
+		GasTipCap:  fees.TipCap,
+		GasFeeCap:  fees.FeeCap,
+		Gas:        gas,
+		To:         tx.To(),
+		Value:      tx.Value(),
+		Data:       tx.Data(),
+		AccessList: tx.AccessList(),
+	})
+}
+
+func bumpBig(v *big.Int, percent uint64) *big.Int {
+	bumped := new(big.Int).Mul(v, new(big.Int).SetUint64(100+percent))
+	bumped.Div(bumped, big.NewInt(100))
+	// always move by at least 1 wei, tiny values would otherwise round back down
+	if bumped.Cmp(v) <= 0 {
+		bumped.Add(v, big.NewInt(1))
+	}
+	return bumped
+}
+
+func minBig(a, b *big.Int) *big.Int {
+	if a.Cmp(b) < 0 {
+		return a
+	}
+	return b
+}
+
+func maxBig(a, b *big.Int) *big.Int {
+	if a.Cmp(b) > 0 {
+		return a
+	}
+	return b
+}
+
+func envUint(name string, def uint64) uint64 {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		log.Printf("Warning: Invalid %s %q, using default %d", name, v, def)
+		return def
+	}
+	return n
+}
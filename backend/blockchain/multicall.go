@@ -0,0 +1,255 @@
+package blockchain
+
+import (
+	"backend/blockchain/property_token"
+	"backend/blockchain/revenue_distribution"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// multicall3Address - where Multicall3 is deployed on most chains, MULTICALL3_ADDRESS overrides it
+const multicall3Address = "0xcA11bde05977b3631167028862bE2a173976CA11"
+
+// multicall3ABI - the aggregate3 function of Multicall3, the only one used
+const multicall3ABI = `[{"inputs":[{"components":[{"name":"target","type":"address"},{"name":"allowFailure","type":"bool"},{"name":"callData","type":"bytes"}],"name":"calls","type":"tuple[]"}],"name":"aggregate3","outputs":[{"components":[{"name":"success","type":"bool"},{"name":"returnData","type":"bytes"}],"name":"returnData","type":"tuple[]"}],"stateMutability":"payable","type":"function"}]`
+
+var multicallABI = sync.OnceValues(func() (abi.ABI, error) { return abi.JSON(strings.NewReader(multicall3ABI)) })
+
+// ContractCall - one contract read of a batch
+type ContractCall struct {
+	Target common.Address
+	ABI    *abi.ABI
+	Method string
+	Args   []any
+}
+
+// CallResult - outputs of one read of a batch, Err is set if only that read failed
+type CallResult struct {
+	Values []any
+	Err    error
+}
+
+type multicall3Call struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+type multicall3Result struct {
+	Success    bool
+	ReturnData []byte
+}
+
+// BatchCall runs contract reads through Multicall3, MULTICALL_BATCH_SIZE (200) per RPC call
+// On chains without Multicall3, or if a batch fails as a whole, the reads are sent one by one
+func (s *ChainService) BatchCall(ctx context.Context, calls []ContractCall) ([]CallResult, error) {
+	if s.Client == nil {
+		return nil, fmt.Errorf("blockchain client not available")
+	}
+
+	results := make([]CallResult, len(calls))
+	inputs := make([][]byte, len(calls))
+	for i, call := range calls {
+		inputs[i], results[i].Err = call.ABI.Pack(call.Method, call.Args...)
+	}
+
+	size := max(int(envUint("MULTICALL_BATCH_SIZE", 200)), 1)
+	multicall := s.multicall3()
+	for start := 0; start < len(calls); start += size {
+		end := min(start+size, len(calls))
+		if multicall != nil {
+			err := s.aggregate3(ctx, *multicall, calls[start:end], inputs[start:end], results[start:end])
+			if err == nil {
+				continue
+			}
+			log.Printf("Warning: Multicall3 batch failed, reading one by one: %v", err)
+		}
+		for i := start; i < end; i++ {
+			if results[i].Err != nil {
+				continue
+			}
+			out, err := s.Client.CallContract(ctx, ethereum.CallMsg{To: &calls[i].Target, Data: inputs[i]}, nil)
+			if err != nil {
+				results[i].Err = err
+				continue
+			}
+			results[i].Values, results[i].Err = calls[i].ABI.Unpack(calls[i].Method, out)
+		}
+	}
+	return results, nil
+}
+
+// aggregate3 - send one batch of packed reads through Multicall3, each read may fail on its own
+func (s *ChainService) aggregate3(ctx context.Context, multicall common.Address, calls []ContractCall, inputs [][]byte, results []CallResult) error {
+	mcABI, err := multicallABI()
+	if err != nil {
+		return err
+	}
+
+	var batch []multicall3Call
+	var index []int // position in calls of each entry of batch
+	for i := range calls {
+		if results[i].Err != nil {
+			continue // could not be packed
+		}
+		batch = append(batch, multicall3Call{Target: calls[i].Target, AllowFailure: true, CallData: inputs[i]})
+		index = append(index, i)
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+
+	input, err := mcABI.Pack("aggregate3", batch)
+	if err != nil {
+		return err
+	}
+	out, err := s.Client.CallContract(ctx, ethereum.CallMsg{To: &multicall, Data: input}, nil)
+	if err != nil {
+		return err
+	}
+	values, err := mcABI.Unpack("aggregate3", out)
+	if err != nil {
+		return err
+	}
+	returned := *abi.ConvertType(values[0], new([]multicall3Result)).(*[]multicall3Result)
+	if len(returned) != len(batch) {
+		return fmt.Errorf("multicall returned %d results for %d calls", len(returned), len(batch))
+	}
+
+	for j, i := range index {
+		if !returned[j].Success {
+			results[i].Err = errors.New("call reverted")
+			continue
+		}
+		results[i].Values, results[i].Err = calls[i].ABI.Unpack(calls[i].Method, returned[j].ReturnData)
+	}
+	return nil
+}
+
+// multicall3 - address of Multicall3 if it is deployed on the chain, checked once
+func (s *ChainService) multicall3() *common.Address {
+	s.multicallOnce.Do(func() {
+		addr := common.HexToAddress(multicall3Address)
+		if v := os.Getenv("MULTICALL3_ADDRESS"); v != "" {
+			addr = common.HexToAddress(v)
+		}
+		code, err := s.Client.CodeAt(context.Background(), addr, nil)
+		if err != nil || len(code) == 0 {
+			log.Printf("Warning: Multicall3 not found at %s, chain reads are sent one by one (err: %v)", addr.Hex(), err)
+			return
+		}
+		s.multicall = &addr
+	})
+	return s.multicall
+}
+
+// TokenHolding - a wallet's position in one property token, Err is set if it could not be read
+type TokenHolding struct {
+	TokenAddress string
+	Balance      *big.Int
+	TotalSupply  *big.Int
+	Err          error
+}
+
+// GetTokenHoldings reads a wallet's balance and the total supply of many property tokens in one batch
+func (s *ChainService) GetTokenHoldings(ctx context.Context, walletAddrStr string, tokenAddrs []string) ([]TokenHolding, error) {
+	tokenABI, err := property_token.PropertyTokenMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+
+	wallet := common.HexToAddress(walletAddrStr)
+	calls := make([]ContractCall, 0, 2*len(tokenAddrs))
+	for _, addr := range tokenAddrs {
+		token := common.HexToAddress(addr)
+		calls = append(calls,
+			ContractCall{Target: token, ABI: tokenABI, Method: "balanceOf", Args: []any{wallet}},
+			ContractCall{Target: token, ABI: tokenABI, Method: "totalSupply"},
+		)
+	}
+	results, err := s.BatchCall(ctx, calls)
+	if err != nil {
+		return nil, err
+	}
+
+	holdings := make([]TokenHolding, len(tokenAddrs))
+	for i, addr := range tokenAddrs {
+		holdings[i].TokenAddress = addr
+		balance, supply := results[2*i], results[2*i+1]
+		if holdings[i].Err = errors.Join(balance.Err, supply.Err); holdings[i].Err != nil {
+			continue
+		}
+		holdings[i].Balance = balance.Values[0].(*big.Int)
+		holdings[i].TotalSupply = supply.Values[0].(*big.Int)
+	}
+	return holdings, nil
+}
+
+// DistributionRef - an indexed revenue distribution, enough to work out entitlements without reading it on-chain
+type DistributionRef struct {
+	DistributionID int64
+	TokenAddress   string
+	SnapshotID     *big.Int
+	TotalAmount    *big.Int
+}
+
+// GetRevenueEntitlements is GetRevenueEntitlement for many distributions in one batch
+// the result has the order of dists, with nil where a distribution could not be read
+func (s *ChainService) GetRevenueEntitlements(ctx context.Context, walletAddrStr string, dists []DistributionRef) ([]*RevenueEntitlement, error) {
+	if s.RevenueDistribution == nil {
+		return nil, fmt.Errorf("revenue distribution contract not deployed - deploy contracts to enable revenue claims")
+	}
+	tokenABI, err := property_token.PropertyTokenMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	revenueABI, err := revenue_distribution.RevenueDistributionMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+
+	// the snapshot and amount come from the index, the distribution itself is read for its stablecoin
+	wallet := common.HexToAddress(walletAddrStr)
+	calls := make([]ContractCall, 0, 4*len(dists))
+	for _, dist := range dists {
+		token := common.HexToAddress(dist.TokenAddress)
+		id := big.NewInt(dist.DistributionID)
+		calls = append(calls,
+			ContractCall{Target: s.RevenueAddress, ABI: revenueABI, Method: "distributions", Args: []any{id}},
+			ContractCall{Target: token, ABI: tokenABI, Method: "balanceOfAt", Args: []any{wallet, dist.SnapshotID}},
+			ContractCall{Target: token, ABI: tokenABI, Method: "totalSupplyAt", Args: []any{dist.SnapshotID}},
+			ContractCall{Target: s.RevenueAddress, ABI: revenueABI, Method: "claimed", Args: []any{id, wallet}},
+		)
+	}
+	results, err := s.BatchCall(ctx, calls)
+	if err != nil {
+		return nil, err
+	}
+
+	entitlements := make([]*RevenueEntitlement, len(dists))
+	for i, dist := range dists {
+		info, balance, supply, claimed := results[4*i], results[4*i+1], results[4*i+2], results[4*i+3]
+		if err := errors.Join(info.Err, balance.Err, supply.Err, claimed.Err); err != nil {
+			log.Printf("Warning: Failed to read entitlement for distribution %d: %v", dist.DistributionID, err)
+			continue
+		}
+		token, stablecoin := info.Values[0].(common.Address), info.Values[3].(common.Address)
+		if token != common.HexToAddress(dist.TokenAddress) {
+			log.Printf("Warning: Distribution %d is for token %s on-chain, indexed for %s", dist.DistributionID, token.Hex(), dist.TokenAddress)
+			continue
+		}
+		entitlements[i] = newRevenueEntitlement(dist.DistributionID, token, stablecoin, dist.SnapshotID, dist.TotalAmount,
+			balance.Values[0].(*big.Int), supply.Values[0].(*big.Int), claimed.Values[0].(bool))
+	}
+	return entitlements, nil
+}
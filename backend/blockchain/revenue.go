@@ -0,0 +1,89 @@
+package blockchain
+
+import (
+	"backend/blockchain/property_token"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// RevenueEntitlement - a wallet's share of one on-chain revenue distribution
+// Amount mirrors RevenueDistribution.claimRevenue: totalAmount * balanceOfAt / totalSupplyAt
+type RevenueEntitlement struct {
+	DistributionID int64
+	TokenAddress   string
+	Stablecoin     string
+	SnapshotID     *big.Int
+	TotalAmount    *big.Int
+	HolderBalance  *big.Int
+	TotalSupplyAt  *big.Int
+	Amount         *big.Int
+	Claimed        bool
+}
+
+// GetRevenueEntitlement calculates how much of a distribution a wallet can claim
+func (s *ChainService) GetRevenueEntitlement(distributionID int64, walletAddrStr string) (*RevenueEntitlement, error) {
+	if s.RevenueDistribution == nil {
+		return nil, fmt.Errorf("revenue distribution contract not deployed - deploy contracts to enable revenue claims")
+	}
+
+	id := big.NewInt(distributionID)
+	wallet := common.HexToAddress(walletAddrStr)
+
+	count, err := s.RevenueDistribution.DistributionsCount(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get distributions count: %v", err)
+	}
+	if id.Sign() < 0 || id.Cmp(count) >= 0 {
+		return nil, fmt.Errorf("distribution %d does not exist", distributionID)
+	}
+
+	dist, err := s.RevenueDistribution.Distributions(nil, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get distribution %d: %v", distributionID, err)
+	}
+
+	token, err := property_token.NewPropertyToken(dist.Token, s.Client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to property token contract: %v", err)
+	}
+
+	balance, err := token.BalanceOfAt(nil, wallet, dist.SnapshotId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance at snapshot: %v", err)
+	}
+
+	supply, err := token.TotalSupplyAt(nil, dist.SnapshotId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get total supply at snapshot: %v", err)
+	}
+
+	claimed, err := s.RevenueDistribution.Claimed(nil, id, wallet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get claim status: %v", err)
+	}
+
+	return newRevenueEntitlement(distributionID, dist.Token, dist.Stablecoin, dist.SnapshotId, dist.TotalAmount, balance, supply, claimed), nil
+}
+
+// newRevenueEntitlement - entitlement to a distribution with the same integer math as the contract,
+// so Amount matches what claimRevenue pays out; GetRevenueEntitlement and GetRevenueEntitlements both use it
+func newRevenueEntitlement(distributionID int64, token, stablecoin common.Address, snapshotID, totalAmount, balance, supply *big.Int, claimed bool) *RevenueEntitlement {
+	amount := new(big.Int)
+	if supply.Sign() > 0 {
+		amount.Mul(totalAmount, balance)
+		amount.Quo(amount, supply)
+	}
+	return &RevenueEntitlement{
+		DistributionID: distributionID,
+		TokenAddress:   token.Hex(),
+		Stablecoin:     stablecoin.Hex(),
+		SnapshotID:     snapshotID,
+		TotalAmount:    totalAmount,
+		HolderBalance:  balance,
+		TotalSupplyAt:  supply,
+		Amount:         amount,
+		Claimed:        claimed,
+	}
+}
@@ -0,0 +1,115 @@
+package blockchain
+
+import (
+	"backend/db/models"
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// OnChainRole - AccessControl role on one of the platform contracts
+type OnChainRole string
+
+const (
+	RoleApprovalAdmin OnChainRole = "approval_admin" // ApprovalService ADMIN_ROLE, may approve and revoke users
+	RoleCreator       OnChainRole = "creator"        // PropertyFactory CREATOR_ROLE, may create properties
+	RoleDistributor   OnChainRole = "distributor"    // RevenueDistribution DISTRIBUTOR_ROLE, may deposit revenue
+)
+
+// accessControl - the OpenZeppelin AccessControl methods every binding exposes
+type accessControl interface {
+	HasRole(opts *bind.CallOpts, role [32]byte, account common.Address) (bool, error)
+	GrantRole(opts *bind.TransactOpts, role [32]byte, account common.Address) (*types.Transaction, error)
+	RevokeRole(opts *bind.TransactOpts, role [32]byte, account common.Address) (*types.Transaction, error)
+}
+
+// roleContract - contract and role id behind an OnChainRole
+func (s *ChainService) roleContract(role OnChainRole) (accessControl, [32]byte, error) {
+	switch role {
+	case RoleApprovalAdmin:
+		if s.Approval == nil {
+			return nil, [32]byte{}, fmt.Errorf("approval contract not deployed")
+		}
+		id, err := s.Approval.ADMINROLE(nil)
+		return s.Approval, id, err
+	case RoleCreator:
+		if s.PropertyFactory == nil {
+			return nil, [32]byte{}, fmt.Errorf("property factory not deployed")
+		}
+		id, err := s.PropertyFactory.CREATORROLE(nil)
+		return s.PropertyFactory, id, err
+	case RoleDistributor:
+		if s.RevenueDistribution == nil {
+			return nil, [32]byte{}, fmt.Errorf("revenue distribution contract not deployed")
+		}
+		id, err := s.RevenueDistribution.DISTRIBUTORROLE(nil)
+		return s.RevenueDistribution, id, err
+	}
+	return nil, [32]byte{}, fmt.Errorf("unknown on-chain role %q", role)
+}
+
+// HasRole checks whether account holds role on its contract
+func (s *ChainService) HasRole(role OnChainRole, accountStr string) (bool, error) {
+	contract, id, err := s.roleContract(role)
+	if err != nil {
+		return false, err
+	}
+	return contract.HasRole(nil, id, common.HexToAddress(accountStr))
+}
+
+// GrantRole grants role to account and waits for the transaction, the backend wallet must be the role's admin
+// returns nil without sending anything if the account already holds the role
+func (s *ChainService) GrantRole(ctx context.Context, role OnChainRole, accountStr string) (*types.Transaction, error) {
+	return s.setRole(ctx, role, accountStr, true)
+}
+
+// RevokeRole revokes role from account and waits for the transaction
+// returns nil without sending anything if the account doesn't hold the role
+func (s *ChainService) RevokeRole(ctx context.Context, role OnChainRole, accountStr string) (*types.Transaction, error) {
+	return s.setRole(ctx, role, accountStr, false)
+}
+
+func (s *ChainService) setRole(ctx context.Context, role OnChainRole, accountStr string, grant bool) (*types.Transaction, error) {
+	contract, id, err := s.roleContract(role)
+	if err != nil {
+		return nil, err
+	}
+	account := common.HexToAddress(accountStr)
+
+	has, err := contract.HasRole(nil, id, account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check role: %w", err)
+	}
+	if has == grant {
+		return nil, nil
+	}
+
+	kind := models.TxTypeGrantRole
+	if !grant {
+		kind = models.TxTypeRevokeRole
+	}
+	tx, err := s.transact(ctx, func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		if grant {
+			return contract.GrantRole(auth, id, account)
+		}
+		return contract.RevokeRole(auth, id, account)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to send %s: %w", kind, err)
+	}
+
+	log.Printf("Info: %s %s for %s sent, hash: %s", kind, role, account.Hex(), tx.Hash().Hex())
+	s.track(kind, account.Hex(), tx, map[string]any{
+		"role":    string(role),
+		"account": account.Hex(),
+	})
+
+	if _, err := s.WaitForTx(ctx, tx.Hash()); err != nil {
+		return tx, fmt.Errorf("transaction confirmation failed: %w", err)
+	}
+	return tx, nil
+}
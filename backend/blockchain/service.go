@@ -7,6 +7,7 @@ import (
 	"backend/blockchain/property_factory"
 	"backend/blockchain/property_token"
 	"backend/blockchain/revenue_distribution"
+	"backend/db/models"
 	"context"
 	"crypto/ecdsa"
 	"errors"
@@ -15,8 +16,10 @@ import (
 	"math/big"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -36,6 +39,16 @@ type ChainService struct {
 	PropertyFactory     *property_factory.PropertyFactory
 	Approval            *approval_service.ApprovalService
 	RevenueDistribution *revenue_distribution.RevenueDistribution
+	RevenueAddress      common.Address // address of RevenueDistribution, for batched reads
+
+	// Tracker records every transaction sent by the backend wallet (optional)
+	Tracker TxTracker
+
+	signerOnce sync.Once
+	signer     *Signer
+
+	multicallOnce sync.Once
+	multicall     *common.Address // Multicall3, nil if the chain has none
 }
 
 // NewChainServiceEnv - create service from environment variables
@@ -180,33 +193,100 @@ func NewChainService(rpcUrl, privateKeyHex, registryAddr string) (*ChainService,
 		PropertyFactory:     factory,
 		Approval:            approval,
 		RevenueDistribution: revenue,
+		RevenueAddress:      revenueAddr,
 	}, nil
 }
 
-func (s *ChainService) GetTransactor() (*bind.TransactOpts, error) {
-	auth, err := bind.NewKeyedTransactorWithChainID(s.PrivateKey, s.ChainID)
-	if err != nil {
-		return nil, err
-	}
-	log.Printf("Backend using signer address: %s", auth.From.Hex())
-	return auth, nil
+// Signer - the nonce-managing signer for the backend wallet, created on first use
+func (s *ChainService) Signer() *Signer {
+	s.signerOnce.Do(func() {
+		s.signer = NewSigner(s.Client, s.PrivateKey, s.ChainID, NewGasConfigEnv())
+		log.Printf("Backend using signer address: %s", s.signer.Address().Hex())
+	})
+	return s.signer
+}
+
+// transact - send a contract write through the serialized signer
+func (s *ChainService) transact(ctx context.Context, send func(auth *bind.TransactOpts) (*types.Transaction, error)) (*types.Transaction, error) {
+	return s.Signer().Transact(ctx, send)
 }
 
 // WaitForTx waits for a transaction to be mined and returns the receipt
-func (s *ChainService) WaitForTx(txHash common.Hash) (*types.Receipt, error) {
-	// Wait for the transaction to be mined
+// a transaction sent by the backend that has no receipt after TX_STUCK_TIMEOUT is replaced
+// with bumped fees on the same nonce, whichever version is mined first is returned
+// stops with a *TxError when ctx is done (TX_WAIT_TIMEOUT seconds if ctx has no deadline)
+// or when the transaction reverted, in which case the receipt is returned as well
+func (s *ChainService) WaitForTx(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(envUint("TX_WAIT_TIMEOUT", 300))*time.Second)
+		defer cancel()
+	}
+
+	hashes := []common.Hash{txHash}
+	lastSent := time.Now()
+	canReplace := s.PrivateKey != nil
+
 	for {
-		receipt, err := s.Client.TransactionReceipt(context.Background(), txHash)
-		if err != nil {
-			if strings.Contains(err.Error(), "not found") {
-				// Transaction not yet mined, wait and try again
-				time.Sleep(2 * time.Second)
-				continue
+		for _, hash := range hashes {
+			receipt, err := s.Client.TransactionReceipt(ctx, hash)
+			if err != nil {
+				if errors.Is(err, ethereum.NotFound) || strings.Contains(err.Error(), "not found") {
+					continue
+				}
+				if ctx.Err() != nil {
+					return nil, waitError(ctx, hashes[len(hashes)-1])
+				}
+				return nil, err
+			}
+
+			if canReplace {
+				s.Signer().Forget(hashes...)
+			}
+			// the audit log follows the latest replacement, point it at the one that was mined
+			if latest := hashes[len(hashes)-1]; hash != latest {
+				s.trackReplacement(latest, hash)
+			}
+
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				return receipt, &TxError{Kind: TxReverted, TxHash: hash, Reason: s.replayRevert(ctx, receipt)}
+			}
+			return receipt, nil
+		}
+
+		if canReplace && time.Since(lastSent) > s.Signer().StuckAfter() {
+			if len(hashes) > s.Signer().MaxReplacements() {
+				log.Printf("Warning: Transaction %s still pending after %d replacement(s), waiting without further bumps", txHash.Hex(), len(hashes)-1)
+				canReplace = false
+			} else {
+				latest := hashes[len(hashes)-1]
+				replacement, err := s.Signer().Replace(ctx, latest)
+				if err != nil {
+					log.Printf("Warning: Failed to replace stuck transaction %s: %v", latest.Hex(), err)
+				} else {
+					hashes = append(hashes, replacement.Hash())
+					s.trackReplacement(latest, replacement.Hash())
+				}
+				lastSent = time.Now()
 			}
-			return nil, err
 		}
-		return receipt, nil
+
+		// Transaction not yet mined, wait and try again
+		select {
+		case <-ctx.Done():
+			return nil, waitError(ctx, hashes[len(hashes)-1])
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// waitError - TxError for a wait that ended because ctx is done
+func waitError(ctx context.Context, txHash common.Hash) error {
+	kind := TxTimeout
+	if errors.Is(ctx.Err(), context.Canceled) {
+		kind = TxCanceled
 	}
+	return &TxError{Kind: kind, TxHash: txHash, Err: ctx.Err()}
 }
 
 // PropertyCreationResult holds the result of creating a property on-chain
@@ -219,19 +299,20 @@ type PropertyCreationResult struct {
 
 // CreateProperty - deploy new property contracts on blockchain
 // creates PropertyAsset (NFT) and PropertyToken (ERC20), links them
-func (s *ChainService) CreateProperty(ownerStr, name, symbol, dataHash string, valuation, supply int64) (*PropertyCreationResult, error) {
-	if s.PropertyFactory == nil {
-		log.Printf("Warning: Property factory contract not available - blockchain service in limited mode")
-		return nil, fmt.Errorf("property factory contract not deployed - deploy contracts to enable property creation")
-	}
-
-	auth, err := s.GetTransactor()
+func (s *ChainService) CreateProperty(ctx context.Context, ownerStr, name, symbol, dataHash string, valuation, supply int64) (*PropertyCreationResult, error) {
+	tx, err := s.SubmitCreateProperty(ctx, ownerStr, name, symbol, dataHash, valuation, supply)
 	if err != nil {
 		return nil, err
 	}
+	return s.WaitPropertyCreated(ctx, tx.Hash(), name)
+}
 
-	// Set higher gas limit for contract deployments
-	auth.GasLimit = 8000000 // 8 million gas
+// SubmitCreateProperty - send the property creation transaction without waiting for it
+func (s *ChainService) SubmitCreateProperty(ctx context.Context, ownerStr, name, symbol, dataHash string, valuation, supply int64) (*types.Transaction, error) {
+	if s.PropertyFactory == nil {
+		log.Printf("Warning: Property factory contract not available - blockchain service in limited mode")
+		return nil, fmt.Errorf("property factory contract not deployed - deploy contracts to enable property creation")
+	}
 
 	owner := common.HexToAddress(ownerStr)
 
@@ -247,23 +328,36 @@ func (s *ChainService) CreateProperty(ownerStr, name, symbol, dataHash string, v
 	log.Printf("Submitting property creation transaction to blockchain...")
 
 	// Submit transaction
-	tx, err := s.PropertyFactory.CreateProperty(auth, owner, name, symbol, dataHash, valBig, supplyBig, name+" Token", "TKN")
+	tx, err := s.transact(ctx, func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return s.PropertyFactory.CreateProperty(auth, owner, name, symbol, dataHash, valBig, supplyBig, name+" Token", "TKN")
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to submit transaction: %v", err)
+		return nil, fmt.Errorf("failed to submit transaction: %w", err)
 	}
 
 	log.Printf("Transaction submitted: %s", tx.Hash().Hex())
-	log.Printf("Waiting for transaction to be mined...")
+	s.track(models.TxTypeCreateProperty, ownerStr, tx, map[string]any{
+		"name":         name,
+		"symbol":       symbol,
+		"data_hash":    dataHash,
+		"valuation":    valuation,
+		"token_supply": supply,
+	})
+	return tx, nil
+}
 
-	// Wait for transaction to be mined
-	receipt, err := s.WaitForTx(tx.Hash())
-	if err != nil {
-		return nil, fmt.Errorf("failed to wait for transaction: %v", err)
+// WaitPropertyCreated - wait for a property creation transaction and read the new contract addresses
+func (s *ChainService) WaitPropertyCreated(ctx context.Context, txHash common.Hash, name string) (*PropertyCreationResult, error) {
+	if s.PropertyFactory == nil {
+		return nil, fmt.Errorf("property factory contract not deployed - deploy contracts to enable property creation")
 	}
 
-	// Check if transaction succeeded
-	if receipt.Status != types.ReceiptStatusSuccessful {
-		return nil, fmt.Errorf("transaction failed on-chain with status: %d", receipt.Status)
+	log.Printf("Waiting for transaction to be mined...")
+
+	// Wait for transaction to be mined, a revert comes back as a *TxError
+	receipt, err := s.WaitForTx(ctx, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wait for transaction: %w", err)
 	}
 
 	log.Printf("Transaction mined successfully in block: %d", receipt.BlockNumber)
@@ -284,7 +378,7 @@ func (s *ChainService) CreateProperty(ownerStr, name, symbol, dataHash string, v
 		return &PropertyCreationResult{
 			AssetAddress: event.PropertyAsset.Hex(),
 			TokenAddress: event.PropertyToken.Hex(),
-			TxHash:       tx.Hash().Hex(),
+			TxHash:       receipt.TxHash.Hex(),
 			PropertyName: name,
 		}, nil
 	}
@@ -293,17 +387,12 @@ func (s *ChainService) CreateProperty(ownerStr, name, symbol, dataHash string, v
 }
 
 // DistributeRevenue deposits funds into the Revenue contract
-func (s *ChainService) DistributeRevenue(tokenAddrStr, stablecoinAddrStr string, amount int64) (*types.Transaction, error) {
+func (s *ChainService) DistributeRevenue(ctx context.Context, tokenAddrStr, stablecoinAddrStr string, amount int64) (*types.Transaction, error) {
 	if s.RevenueDistribution == nil {
 		log.Printf("Warning: Revenue distribution contract not available - blockchain service in limited mode")
 		return nil, fmt.Errorf("revenue distribution contract not deployed - deploy contracts to enable revenue distribution")
 	}
 
-	auth, err := s.GetTransactor()
-	if err != nil {
-		return nil, err
-	}
-
 	tokenAddr := common.HexToAddress(tokenAddrStr)
 	stablecoinAddr := common.HexToAddress(stablecoinAddrStr)
 	amountBig := big.NewInt(amount)
@@ -316,18 +405,29 @@ func (s *ChainService) DistributeRevenue(tokenAddrStr, stablecoinAddrStr string,
 	} else {
 		// Check if RevenueDistribution has SNAPSHOT_ROLE on the PropertyToken
 		// If not, grant it automatically
-		err = s.ensureSnapshotRole(tokenAddr, revenueDistributionAddr, auth)
+		err = s.ensureSnapshotRole(ctx, tokenAddr, revenueDistributionAddr)
 		if err != nil {
 			log.Printf("Warning: Failed to ensure SNAPSHOT_ROLE (will try distribution anyway): %v", err)
 			// Continue anyway - the error might be that role is already granted
 		}
 	}
 
-	return s.RevenueDistribution.DepositRevenue(auth, tokenAddr, stablecoinAddr, amountBig)
+	tx, err := s.transact(ctx, func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return s.RevenueDistribution.DepositRevenue(auth, tokenAddr, stablecoinAddr, amountBig)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.track(models.TxTypeDepositRevenue, "", tx, map[string]any{
+		"token_address":      tokenAddr.Hex(),
+		"stablecoin_address": stablecoinAddr.Hex(),
+		"amount":             amountBig.String(),
+	})
+	return tx, nil
 }
 
 // ensureSnapshotRole ensures that RevenueDistribution has SNAPSHOT_ROLE on the PropertyToken
-func (s *ChainService) ensureSnapshotRole(tokenAddr, revenueDistributionAddr common.Address, auth *bind.TransactOpts) error {
+func (s *ChainService) ensureSnapshotRole(ctx context.Context, tokenAddr, revenueDistributionAddr common.Address) error {
 	// Create PropertyToken instance
 	token, err := property_token.NewPropertyToken(tokenAddr, s.Client)
 	if err != nil {
@@ -373,7 +473,9 @@ func (s *ChainService) ensureSnapshotRole(tokenAddr, revenueDistributionAddr com
 	
 	// Try to grant the role directly on the PropertyToken first
 	// This will work if the caller (backend wallet) is the admin of the PropertyToken
-	tx, err := token.GrantRole(auth, roleBytes, revenueDistributionAddr)
+	tx, err := s.transact(ctx, func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return token.GrantRole(auth, roleBytes, revenueDistributionAddr)
+	})
 	if err != nil {
 		// Direct grant failed - PropertyFactory is likely the admin
 		// Use PropertyFactory's grantSnapshotRoleToRevenue function via raw transaction
@@ -387,16 +489,18 @@ func (s *ChainService) ensureSnapshotRole(tokenAddr, revenueDistributionAddr com
 		// Function signature: grantSnapshotRoleToRevenue(address tokenAddress)
 		// PropertyFactoryRaw allows calling methods by name even if not in bindings
 		rawFactory := &property_factory.PropertyFactoryRaw{Contract: s.PropertyFactory}
-		tx, err = rawFactory.Transact(auth, "grantSnapshotRoleToRevenue", tokenAddr)
+		tx, err = s.transact(ctx, func(auth *bind.TransactOpts) (*types.Transaction, error) {
+			return rawFactory.Transact(auth, "grantSnapshotRoleToRevenue", tokenAddr)
+		})
 		if err != nil {
 			log.Printf("Failed to call PropertyFactory.grantSnapshotRoleToRevenue: %v", err)
 			return fmt.Errorf("failed to grant SNAPSHOT_ROLE via PropertyFactory: %v", err)
 		}
 		
 		log.Printf("PropertyFactory.grantSnapshotRoleToRevenue transaction sent, waiting for confirmation...")
-		receipt, err := s.WaitForTx(tx.Hash())
+		receipt, err := s.WaitForTx(ctx, tx.Hash())
 		if err != nil {
-			return fmt.Errorf("failed to wait for grant role transaction: %v", err)
+			return fmt.Errorf("failed to wait for grant role transaction: %w", err)
 		}
 		
 		if receipt.Status == 0 {
@@ -408,9 +512,9 @@ func (s *ChainService) ensureSnapshotRole(tokenAddr, revenueDistributionAddr com
 	}
 	
 	log.Printf("SNAPSHOT_ROLE grant transaction sent, waiting for confirmation...")
-	receipt, err := s.WaitForTx(tx.Hash())
+	receipt, err := s.WaitForTx(ctx, tx.Hash())
 	if err != nil {
-		return fmt.Errorf("failed to wait for grant role transaction: %v", err)
+		return fmt.Errorf("failed to wait for grant role transaction: %w", err)
 	}
 	
 	if receipt.Status == 0 {
@@ -422,7 +526,26 @@ func (s *ChainService) ensureSnapshotRole(tokenAddr, revenueDistributionAddr com
 }
 
 // ApproveUser allows a specific wallet address to participate in the platform
-func (s *ChainService) ApproveUser(userAddressStr string) (*types.Transaction, error) {
+func (s *ChainService) ApproveUser(ctx context.Context, userAddressStr string) (*types.Transaction, error) {
+	tx, err := s.SubmitApproveUser(ctx, userAddressStr)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Waiting for transaction confirmation...")
+
+	// Wait for transaction to be mined
+	receipt, err := s.WaitForTx(ctx, tx.Hash())
+	if err != nil {
+		log.Printf("Warning: Transaction confirmation failed: %v", err)
+		return tx, fmt.Errorf("transaction confirmation failed: %w", err)
+	}
+
+	log.Printf("Transaction mined in block: %d", receipt.BlockNumber)
+	return tx, nil
+}
+
+// SubmitApproveUser - send the approve transaction without waiting for it
+func (s *ChainService) SubmitApproveUser(ctx context.Context, userAddressStr string) (*types.Transaction, error) {
 	log.Printf("Starting approval for address: %s", userAddressStr)
 
 	if s.Approval == nil {
@@ -430,34 +553,91 @@ func (s *ChainService) ApproveUser(userAddressStr string) (*types.Transaction, e
 		return nil, fmt.Errorf("approval contract not deployed - deploy contracts to enable blockchain functionality")
 	}
 
-	auth, err := s.GetTransactor()
-	if err != nil {
-		log.Printf("Failed to get transactor: %v", err)
-		return nil, fmt.Errorf("failed to get transactor: %v", err)
-	}
-
 	userAddr := common.HexToAddress(userAddressStr)
 	log.Printf("Debug: Calling smart contract Approve() for address: %s", userAddressStr)
-	log.Printf("Backend signer address: %s", auth.From.Hex())
+	log.Printf("Backend signer address: %s", s.Signer().Address().Hex())
 
-	tx, err := s.Approval.Approve(auth, userAddr)
+	tx, err := s.transact(ctx, func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return s.Approval.Approve(auth, userAddr)
+	})
 	if err != nil {
 		log.Printf("Smart contract Approve() failed for %s: %v", userAddressStr, err)
-		log.Printf("Debug: Auth signer address: %s", auth.From.Hex())
-		return nil, fmt.Errorf("smart contract approve failed: %v", err)
+		log.Printf("Debug: Auth signer address: %s", s.Signer().Address().Hex())
+		return nil, fmt.Errorf("smart contract approve failed: %w", err)
 	}
 
 	log.Printf("Smart contract approve() transaction sent, hash: %s", tx.Hash().Hex())
-	log.Printf("Waiting for transaction confirmation...")
+	s.track(models.TxTypeApproveUser, userAddr.Hex(), tx, map[string]any{
+		"wallet_address": userAddr.Hex(),
+	})
+	return tx, nil
+}
 
-	// Wait for transaction to be mined
-	receipt, err := s.WaitForTx(tx.Hash())
+// RevokeUser calls ApprovalService.revoke and waits for it to be mined
+// PropertyToken refuses transfers to the wallet until it is approved again
+func (s *ChainService) RevokeUser(ctx context.Context, userAddressStr string) (*types.Transaction, error) {
+	tx, err := s.SubmitRevokeUser(ctx, userAddressStr)
 	if err != nil {
-		log.Printf("Warning: Transaction confirmation failed: %v", err)
-		return tx, fmt.Errorf("transaction confirmation failed: %v", err)
+		return nil, err
+	}
+	if _, err := s.WaitForTx(ctx, tx.Hash()); err != nil {
+		return tx, fmt.Errorf("transaction confirmation failed: %w", err)
 	}
+	return tx, nil
+}
 
-	log.Printf("Transaction mined in block: %d", receipt.BlockNumber)
+// SubmitRevokeUser - send the revoke transaction without waiting for it
+func (s *ChainService) SubmitRevokeUser(ctx context.Context, userAddressStr string) (*types.Transaction, error) {
+	if s.Approval == nil {
+		return nil, fmt.Errorf("approval contract not deployed - deploy contracts to enable blockchain functionality")
+	}
+
+	userAddr := common.HexToAddress(userAddressStr)
+	tx, err := s.transact(ctx, func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return s.Approval.Revoke(auth, userAddr)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("smart contract revoke failed: %w", err)
+	}
+
+	log.Printf("Smart contract revoke() transaction sent, hash: %s", tx.Hash().Hex())
+	s.track(models.TxTypeRevokeUser, userAddr.Hex(), tx, map[string]any{
+		"wallet_address": userAddr.Hex(),
+	})
+	return tx, nil
+}
+
+// RejectUser calls ApprovalService.rejectUser and waits for it to be mined
+// clears the approval like revoke and also emits UserRejected
+func (s *ChainService) RejectUser(ctx context.Context, userAddressStr string) (*types.Transaction, error) {
+	tx, err := s.SubmitRejectUser(ctx, userAddressStr)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.WaitForTx(ctx, tx.Hash()); err != nil {
+		return tx, fmt.Errorf("transaction confirmation failed: %w", err)
+	}
+	return tx, nil
+}
+
+// SubmitRejectUser - send the rejectUser transaction without waiting for it
+func (s *ChainService) SubmitRejectUser(ctx context.Context, userAddressStr string) (*types.Transaction, error) {
+	if s.Approval == nil {
+		return nil, fmt.Errorf("approval contract not deployed - deploy contracts to enable blockchain functionality")
+	}
+
+	userAddr := common.HexToAddress(userAddressStr)
+	tx, err := s.transact(ctx, func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return s.Approval.RejectUser(auth, userAddr)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("smart contract rejectUser failed: %w", err)
+	}
+
+	log.Printf("Smart contract rejectUser() transaction sent, hash: %s", tx.Hash().Hex())
+	s.track(models.TxTypeRejectUser, userAddr.Hex(), tx, map[string]any{
+		"wallet_address": userAddr.Hex(),
+	})
 	return tx, nil
 }
 
@@ -477,7 +657,7 @@ func (s *ChainService) IsApproved(userAddressStr string) (bool, error) {
 }
 
 // ApproveProperty sets a property status to Active on-chain
-func (s *ChainService) ApproveProperty(propertyAssetAddrStr string) (*types.Transaction, error) {
+func (s *ChainService) ApproveProperty(ctx context.Context, propertyAssetAddrStr string) (*types.Transaction, error) {
 	if s.Client == nil {
 		return nil, fmt.Errorf("blockchain client not available")
 	}
@@ -488,17 +668,22 @@ func (s *ChainService) ApproveProperty(propertyAssetAddrStr string) (*types.Tran
 		return nil, fmt.Errorf("failed to connect to property asset contract: %v", err)
 	}
 
-	auth, err := s.GetTransactor()
+	// Status.Active = 0
+	tx, err := s.transact(ctx, func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return propertyAsset.SetStatus(auth, 0)
+	})
 	if err != nil {
 		return nil, err
 	}
-
-	// Status.Active = 0
-	return propertyAsset.SetStatus(auth, 0)
+	s.track(models.TxTypeApproveProperty, "", tx, map[string]any{
+		"asset_address": propertyAssetAddr.Hex(),
+		"status":        models.StatusActive,
+	})
+	return tx, nil
 }
 
 // RejectProperty sets a property status to Closed on-chain
-func (s *ChainService) RejectProperty(propertyAssetAddrStr string) (*types.Transaction, error) {
+func (s *ChainService) RejectProperty(ctx context.Context, propertyAssetAddrStr string) (*types.Transaction, error) {
 	if s.Client == nil {
 		return nil, fmt.Errorf("blockchain client not available")
 	}
@@ -509,13 +694,18 @@ func (s *ChainService) RejectProperty(propertyAssetAddrStr string) (*types.Trans
 		return nil, fmt.Errorf("failed to connect to property asset contract: %v", err)
 	}
 
-	auth, err := s.GetTransactor()
+	// Status.Closed = 3
+	tx, err := s.transact(ctx, func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return propertyAsset.SetStatus(auth, 3)
+	})
 	if err != nil {
 		return nil, err
 	}
-
-	// Status.Closed = 3
-	return propertyAsset.SetStatus(auth, 3)
+	s.track(models.TxTypeRejectProperty, "", tx, map[string]any{
+		"asset_address": propertyAssetAddr.Hex(),
+		"status":        models.StatusClosed,
+	})
+	return tx, nil
 }
 
 // GetTokenBalance gets the token balance for a wallet address
@@ -542,7 +732,7 @@ func (s *ChainService) GetTokenBalance(tokenAddrStr, walletAddrStr string) (*big
 
 // TransferTokens transfers tokens from the backend wallet to another address
 // Note: This requires the backend wallet to have tokens and approval
-func (s *ChainService) TransferTokens(tokenAddrStr, toAddrStr string, amount *big.Int) (*types.Transaction, error) {
+func (s *ChainService) TransferTokens(ctx context.Context, tokenAddrStr, toAddrStr string, amount *big.Int) (*types.Transaction, error) {
 	if s.Client == nil {
 		return nil, fmt.Errorf("blockchain client not available")
 	}
@@ -555,12 +745,36 @@ func (s *ChainService) TransferTokens(tokenAddrStr, toAddrStr string, amount *bi
 		return nil, fmt.Errorf("failed to connect to property token contract: %v", err)
 	}
 
-	auth, err := s.GetTransactor()
+	tx, err := s.transact(ctx, func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return token.Transfer(auth, toAddr, amount)
+	})
 	if err != nil {
 		return nil, err
 	}
+	s.track(models.TxTypeTransferToken, toAddr.Hex(), tx, map[string]any{
+		"token_address": tokenAddr.Hex(),
+		"to_address":    toAddr.Hex(),
+		"amount":        amount.String(),
+	})
+	return tx, nil
+}
+
+// GetPropertyName reads the property name from its PropertyAsset contract
+func (s *ChainService) GetPropertyName(propertyAssetAddrStr string) (string, error) {
+	if s.Client == nil {
+		return "", fmt.Errorf("blockchain client not available")
+	}
 
-	return token.Transfer(auth, toAddr, amount)
+	propertyAsset, err := property_asset.NewPropertyAsset(common.HexToAddress(propertyAssetAddrStr), s.Client)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to property asset contract: %v", err)
+	}
+
+	name, err := propertyAsset.Name(nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to get property name: %v", err)
+	}
+	return name, nil
 }
 
 // GetTotalSupply gets the total token supply for a property token contract
@@ -583,3 +797,56 @@ func (s *ChainService) GetTotalSupply(tokenAddrStr string) (*big.Int, error) {
 
 	return totalSupply, nil
 }
+
+// propertyStatuses - PropertyAsset Status enum values in declaration order
+var propertyStatuses = []models.PropertyStatus{models.StatusActive, models.StatusPaused, models.StatusDisputed, models.StatusClosed}
+
+// GetPropertyStatus reads the status of a property from its PropertyAsset contract
+func (s *ChainService) GetPropertyStatus(propertyAssetAddrStr string) (models.PropertyStatus, error) {
+	if s.Client == nil {
+		return "", fmt.Errorf("blockchain client not available")
+	}
+
+	propertyAsset, err := property_asset.NewPropertyAsset(common.HexToAddress(propertyAssetAddrStr), s.Client)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to property asset contract: %v", err)
+	}
+
+	status, err := propertyAsset.GetStatus(nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to get property status: %v", err)
+	}
+	if int(status) >= len(propertyStatuses) {
+		return "", fmt.Errorf("unknown property status %d", status)
+	}
+	return propertyStatuses[status], nil
+}
+
+// TransferBlocker reports why PropertyToken would refuse transfers between holders right now, "" if it allows them
+// The token must not be paused and the property must be Active; the recipient must also pass IsApproved
+func (s *ChainService) TransferBlocker(tokenAddrStr, propertyAssetAddrStr string) (string, error) {
+	if s.Client == nil {
+		return "", fmt.Errorf("blockchain client not available")
+	}
+
+	token, err := property_token.NewPropertyToken(common.HexToAddress(tokenAddrStr), s.Client)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to property token contract: %v", err)
+	}
+	paused, err := token.Paused(nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if the token is paused: %v", err)
+	}
+	if paused {
+		return "token is paused", nil
+	}
+
+	status, err := s.GetPropertyStatus(propertyAssetAddrStr)
+	if err != nil {
+		return "", err
+	}
+	if status != models.StatusActive {
+		return "property is " + string(status), nil
+	}
+	return "", nil
+}
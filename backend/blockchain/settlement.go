@@ -0,0 +1,143 @@
+package blockchain
+
+import (
+	"backend/blockchain/property_token"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// SettlementErrorKind - why a purchase's payment or token transfer was not accepted
+type SettlementErrorKind string
+
+const (
+	SettlementMismatch SettlementErrorKind = "mismatch" // the transaction doesn't pay or deliver what the purchase says
+	SettlementPending  SettlementErrorKind = "pending"  // not mined yet, or fewer confirmations than SETTLEMENT_CONFIRMATIONS
+)
+
+// SettlementError - typed verification failure handlers can map to a response status
+type SettlementError struct {
+	Kind   SettlementErrorKind
+	TxHash common.Hash
+	Reason string
+}
+
+func (e *SettlementError) Error() string {
+	return fmt.Sprintf("%s (tx %s)", e.Reason, e.TxHash.Hex())
+}
+
+// VerifyPayment checks that txHash is a successful ETH transfer of exactly value wei
+// from the buyer to the seller with at least SETTLEMENT_CONFIRMATIONS confirmations
+// Sender, recipient and value are checked before confirmations, a pending result always means a matching payment
+func (s *ChainService) VerifyPayment(ctx context.Context, txHashStr, fromStr, toStr string, value *big.Int) (*types.Receipt, error) {
+	txHash := common.HexToHash(txHashStr)
+	tx, isPending, err := s.fetchTx(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	// the body of a pending transaction is already final, only whether it is mined can change
+	sender, err := types.Sender(types.LatestSignerForChainID(s.ChainID), tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to recover payment sender: %w", err)
+	}
+	if sender != common.HexToAddress(fromStr) {
+		return nil, mismatch(txHash, "payment was sent by %s, not the buyer %s", sender.Hex(), fromStr)
+	}
+	if tx.To() == nil || *tx.To() != common.HexToAddress(toStr) {
+		return nil, mismatch(txHash, "payment was not sent to the owner wallet %s", toStr)
+	}
+	if tx.Value().Cmp(value) != 0 {
+		return nil, mismatch(txHash, "payment is %s wei, expected %s wei", tx.Value(), value)
+	}
+	return s.confirmedReceipt(ctx, txHash, isPending)
+}
+
+// VerifyTokenTransfer checks that txHash emitted a Transfer of exactly amount tokens (wei units)
+// from the seller to the buyer on the property token, with at least SETTLEMENT_CONFIRMATIONS confirmations
+func (s *ChainService) VerifyTokenTransfer(ctx context.Context, txHashStr, tokenAddrStr, fromStr, toStr string, amount *big.Int) (*types.Receipt, error) {
+	txHash := common.HexToHash(txHashStr)
+	_, isPending, err := s.fetchTx(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+	receipt, err := s.confirmedReceipt(ctx, txHash, isPending)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenAddr := common.HexToAddress(tokenAddrStr)
+	token, err := property_token.NewPropertyToken(tokenAddr, s.Client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to property token contract: %v", err)
+	}
+
+	from, to := common.HexToAddress(fromStr), common.HexToAddress(toStr)
+	for _, l := range receipt.Logs {
+		if l.Address != tokenAddr {
+			continue
+		}
+		transfer, err := token.ParseTransfer(*l)
+		if err != nil {
+			continue // another event of the token
+		}
+		if transfer.From == from && transfer.To == to && transfer.Value.Cmp(amount) == 0 {
+			return receipt, nil
+		}
+	}
+	return nil, mismatch(txHash, "no Transfer of %s from %s to %s on token %s", amount, fromStr, toStr, tokenAddrStr)
+}
+
+// fetchTx - a transaction by hash, mined or still pending
+func (s *ChainService) fetchTx(ctx context.Context, txHash common.Hash) (*types.Transaction, bool, error) {
+	tx, isPending, err := s.Client.TransactionByHash(ctx, txHash)
+	if err != nil {
+		if errors.Is(err, ethereum.NotFound) || strings.Contains(err.Error(), "not found") {
+			return nil, false, mismatch(txHash, "transaction not found")
+		}
+		return nil, false, fmt.Errorf("failed to fetch transaction: %w", err)
+	}
+	return tx, isPending, nil
+}
+
+// confirmedReceipt - receipt of a mined, successful transaction, once it has enough confirmations
+func (s *ChainService) confirmedReceipt(ctx context.Context, txHash common.Hash, isPending bool) (*types.Receipt, error) {
+	if isPending {
+		return nil, &SettlementError{Kind: SettlementPending, TxHash: txHash, Reason: "transaction is not mined yet"}
+	}
+
+	receipt, err := s.Client.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch receipt: %w", err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, mismatch(txHash, "transaction reverted")
+	}
+
+	head, err := s.Client.BlockNumber(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch block number: %w", err)
+	}
+	var have uint64
+	if mined := receipt.BlockNumber.Uint64(); head >= mined {
+		have = head - mined + 1
+	}
+	if want := envUint("SETTLEMENT_CONFIRMATIONS", 3); have < want {
+		return nil, &SettlementError{
+			Kind:   SettlementPending,
+			TxHash: txHash,
+			Reason: fmt.Sprintf("transaction has %d of %d confirmations", have, want),
+		}
+	}
+	return receipt, nil
+}
+
+func mismatch(txHash common.Hash, format string, args ...any) *SettlementError {
+	return &SettlementError{Kind: SettlementMismatch, TxHash: txHash, Reason: fmt.Sprintf(format, args...)}
+}
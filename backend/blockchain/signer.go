@@ -0,0 +1,197 @@
+package blockchain
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// Signer - serializes transactions from the backend wallet and hands out nonces locally
+// sends are queued behind a mutex, so concurrent requests never submit the same nonce
+type Signer struct {
+	mu      sync.Mutex
+	client  *ethclient.Client
+	key     *ecdsa.PrivateKey
+	chainID *big.Int
+	from    common.Address
+	gas     GasConfig
+
+	nonce *uint64                            // next nonce to use, nil = fetch from the node on the next send
+	sent  map[common.Hash]*types.Transaction // unmined transactions we sent, kept so they can be replaced
+}
+
+func NewSigner(client *ethclient.Client, key *ecdsa.PrivateKey, chainID *big.Int, gas GasConfig) *Signer {
+	return &Signer{
+		client:  client,
+		key:     key,
+		chainID: chainID,
+		from:    crypto.PubkeyToAddress(key.PublicKey),
+		gas:     gas,
+		sent:    map[common.Hash]*types.Transaction{},
+	}
+}
+
+// Address - the backend wallet address
+func (s *Signer) Address() common.Address {
+	return s.from
+}
+
+// Transact - build a transaction through send with the next nonce and current fees, then submit it
+// send only builds the transaction (NoSend is set), the gas limit is its estimate plus GAS_LIMIT_MARGIN_PERCENT
+// the nonce is only consumed when the node accepts the transaction, any error resyncs from the node
+func (s *Signer) Transact(ctx context.Context, send func(auth *bind.TransactOpts) (*types.Transaction, error)) (*types.Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nonce, err := s.nextNonce(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nonce: %v", err)
+	}
+
+	fees, err := s.suggestFees(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gas fees: %v", err)
+	}
+
+	auth, err := bind.NewKeyedTransactorWithChainID(s.key, s.chainID)
+	if err != nil {
+		return nil, err
+	}
+	auth.Context = ctx
+	auth.Nonce = new(big.Int).SetUint64(nonce)
+	auth.GasTipCap = fees.TipCap
+	auth.GasFeeCap = fees.FeeCap
+	auth.GasPrice = fees.GasPrice
+	auth.NoSend = true
+
+	// bind runs EstimateGas while building, failures here are usually reverts
+	built, err := send(auth)
+	if err != nil {
+		s.nonce = nil
+		if reason, ok := RevertReason(err); ok {
+			return nil, &TxError{Kind: TxReverted, Reason: reason, Err: err}
+		}
+		return nil, err
+	}
+
+	gas := built.Gas() + built.Gas()*s.gas.LimitMargin/100
+	tx, err := s.signAndSend(ctx, withGas(built, s.chainID, gas, fees))
+	if err != nil {
+		if isNonceError(err) {
+			log.Printf("Warning: Nonce %d rejected for %s, resyncing: %v", nonce, s.from.Hex(), err)
+		}
+		s.nonce = nil
+		return nil, err
+	}
+
+	next := tx.Nonce() + 1
+	s.nonce = &next
+	return tx, nil
+}
+
+// Replace - resubmit a stuck transaction with the same nonce and bumped fees
+// only transactions sent through this signer can be replaced
+func (s *Signer) Replace(ctx context.Context, hash common.Hash) (*types.Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	original, ok := s.sent[hash]
+	if !ok {
+		return nil, fmt.Errorf("transaction %s was not sent by this signer", hash.Hex())
+	}
+
+	fees, err := s.bumpFees(ctx, original)
+	if err != nil {
+		return nil, err
+	}
+
+	replacement, err := s.signAndSend(ctx, withGas(original, s.chainID, original.Gas(), fees))
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Info: Replaced stuck transaction %s (nonce %d) with %s", hash.Hex(), original.Nonce(), replacement.Hash().Hex())
+	return replacement, nil
+}
+
+// Forget - stop tracking transactions once one of them has been mined
+func (s *Signer) Forget(hashes ...common.Hash) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, hash := range hashes {
+		delete(s.sent, hash)
+	}
+}
+
+// StuckAfter - how long to wait for a receipt before replacing a transaction
+func (s *Signer) StuckAfter() time.Duration {
+	return s.gas.StuckAfter
+}
+
+// MaxReplacements - how many times one transaction may be replaced
+func (s *Signer) MaxReplacements() int {
+	return s.gas.MaxReplacements
+}
+
+func (s *Signer) signAndSend(ctx context.Context, tx *types.Transaction) (*types.Transaction, error) {
+	signed, err := types.SignTx(tx, types.LatestSignerForChainID(s.chainID), s.key)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.client.SendTransaction(ctx, signed); err != nil {
+		return nil, err
+	}
+	s.sent[signed.Hash()] = signed
+	return signed, nil
+}
+
+// Resync - drop the local nonce so the next send starts from the node's pending nonce
+// called when a transaction was dropped and left a gap
+func (s *Signer) Resync() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nonce = nil
+}
+
+// nextNonce - local nonce, moved forward if the node has seen transactions we didn't send
+// (another process using the same key, or a replacement mined in our place)
+func (s *Signer) nextNonce(ctx context.Context) (uint64, error) {
+	pending, err := s.client.PendingNonceAt(ctx, s.from)
+	if err != nil {
+		return 0, err
+	}
+
+	// transactions nobody waited for stay in sent, drop the ones that can no longer be replaced
+	if len(s.sent) > 0 {
+		if mined, err := s.client.NonceAt(ctx, s.from, nil); err == nil {
+			for hash, tx := range s.sent {
+				if tx.Nonce() < mined {
+					delete(s.sent, hash)
+				}
+			}
+		}
+	}
+
+	if s.nonce == nil || *s.nonce < pending {
+		s.nonce = &pending
+	}
+	return *s.nonce, nil
+}
+
+func isNonceError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "nonce too low") ||
+		strings.Contains(msg, "nonce too high") ||
+		strings.Contains(msg, "already known") ||
+		strings.Contains(msg, "replacement transaction underpriced")
+}
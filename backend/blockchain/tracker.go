@@ -0,0 +1,44 @@
+package blockchain
+
+import (
+	"backend/db/models"
+	"log"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// TxTracker - records transactions sent by the backend wallet
+// implemented outside this package so the chain service doesn't depend on the database
+type TxTracker interface {
+	TrackTransaction(kind models.TransactionType, signer, relatedWallet string, tx *types.Transaction, details map[string]any) error
+	TrackReplacement(oldHash, newHash common.Hash) error
+}
+
+// track - record a submitted transaction with the configured tracker
+// failures are only logged, the transaction is already on its way
+func (s *ChainService) track(kind models.TransactionType, relatedWallet string, tx *types.Transaction, details map[string]any) {
+	if s.Tracker == nil || tx == nil {
+		return
+	}
+
+	signer := ""
+	if s.PrivateKey != nil {
+		signer = crypto.PubkeyToAddress(s.PrivateKey.PublicKey).Hex()
+	}
+
+	if err := s.Tracker.TrackTransaction(kind, signer, relatedWallet, tx, details); err != nil {
+		log.Printf("Warning: Failed to record %s transaction %s: %v", kind, tx.Hash().Hex(), err)
+	}
+}
+
+// trackReplacement - move the recorded transaction from oldHash to newHash after a fee bump
+func (s *ChainService) trackReplacement(oldHash, newHash common.Hash) {
+	if s.Tracker == nil {
+		return
+	}
+	if err := s.Tracker.TrackReplacement(oldHash, newHash); err != nil {
+		log.Printf("Warning: Failed to record replacement %s -> %s: %v", oldHash.Hex(), newHash.Hex(), err)
+	}
+}
@@ -0,0 +1,115 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// TxErrorKind - why a transaction did not produce a usable receipt
+type TxErrorKind string
+
+const (
+	TxReverted TxErrorKind = "reverted" // rejected by the contract, Reason holds the revert string if there was one
+	TxTimeout  TxErrorKind = "timeout"  // still pending when the wait deadline passed
+	TxCanceled TxErrorKind = "canceled" // the caller stopped waiting, e.g. the HTTP client disconnected
+)
+
+// TxError - typed transaction failure handlers can map to a response status
+// TxHash is empty when the transaction was rejected before it was sent
+type TxError struct {
+	Kind   TxErrorKind
+	TxHash common.Hash
+	Reason string
+	Err    error
+}
+
+func (e *TxError) Error() string {
+	var msg string
+	switch e.Kind {
+	case TxReverted:
+		msg = "transaction reverted"
+		if e.Reason != "" {
+			msg += ": " + e.Reason
+		}
+	case TxTimeout:
+		msg = "timed out waiting for transaction"
+	case TxCanceled:
+		msg = "stopped waiting for transaction"
+	default:
+		msg = "transaction failed"
+	}
+	if e.TxHash != (common.Hash{}) {
+		msg += fmt.Sprintf(" (tx %s)", e.TxHash.Hex())
+	}
+	return msg
+}
+
+func (e *TxError) Unwrap() error {
+	return e.Err
+}
+
+// RevertReason - extract the Solidity revert string from an eth_call/estimateGas error
+// ok is false when err is not a revert at all
+func RevertReason(err error) (reason string, ok bool) {
+	if err == nil {
+		return "", false
+	}
+
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		if hexData, isString := dataErr.ErrorData().(string); isString {
+			if data, decodeErr := hexutil.Decode(hexData); decodeErr == nil {
+				if reason, unpackErr := abi.UnpackRevert(data); unpackErr == nil {
+					return reason, true
+				}
+			}
+		}
+	}
+
+	// some nodes only put the reason in the message
+	msg := err.Error()
+	if i := strings.Index(msg, "execution reverted: "); i >= 0 {
+		return msg[i+len("execution reverted: "):], true
+	}
+	if strings.Contains(msg, "execution reverted") {
+		return "", true
+	}
+	return "", false
+}
+
+// replayRevert - re-run a failed transaction as a call on its parent block to recover the revert string
+func (s *ChainService) replayRevert(ctx context.Context, receipt *types.Receipt) string {
+	tx, _, err := s.Client.TransactionByHash(ctx, receipt.TxHash)
+	if err != nil {
+		return ""
+	}
+	from, err := types.Sender(types.LatestSignerForChainID(s.ChainID), tx)
+	if err != nil {
+		return ""
+	}
+
+	block := new(big.Int).Set(receipt.BlockNumber)
+	if block.Sign() > 0 {
+		block.Sub(block, big.NewInt(1))
+	}
+
+	_, err = s.Client.CallContract(ctx, ethereum.CallMsg{
+		From:  from,
+		To:    tx.To(),
+		Gas:   tx.Gas(),
+		Value: tx.Value(),
+		Data:  tx.Data(),
+	}, block)
+	reason, _ := RevertReason(err)
+	return reason
+}
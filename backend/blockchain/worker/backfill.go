@@ -0,0 +1,71 @@
+package worker
+
+import (
+	"backend/blockchain"
+	"backend/db"
+	"context"
+	"log"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// backfillRevenueLinks - attach on-chain distribution IDs to revenue rows recorded without one
+// reads the original deposit/claim transaction receipts, then links claims to their distributions
+func backfillRevenueLinks(chain *blockchain.ChainService, database *db.Database) {
+	ctx := context.Background()
+
+	dists, err := database.GetRevenueDistributionsMissingOnchainID()
+	if err != nil {
+		log.Printf("Warning: Revenue backfill could not load distributions: %v", err)
+		return
+	}
+	for _, dist := range dists {
+		receipt, err := chain.Client.TransactionReceipt(ctx, common.HexToHash(dist.StablecoinTxHash))
+		if err != nil {
+			log.Printf("Warning: Revenue backfill could not fetch deposit receipt %s: %v", dist.StablecoinTxHash, err)
+			continue
+		}
+		for _, vLog := range receipt.Logs {
+			event, err := chain.RevenueDistribution.ParseRevenueDeposited(*vLog)
+			if err != nil || event.Amount.String() != dist.TotalAmount {
+				continue
+			}
+			if err := database.SetRevenueDistributionOnchainID(dist.ID, event.DistributionId.Int64()); err != nil {
+				log.Printf("Warning: Revenue backfill could not update distribution %s: %v", dist.ID, err)
+			}
+			break
+		}
+	}
+
+	claims, err := database.GetRevenueClaimsMissingOnchainID()
+	if err != nil {
+		log.Printf("Warning: Revenue backfill could not load claims: %v", err)
+		return
+	}
+	for _, claim := range claims {
+		receipt, err := chain.Client.TransactionReceipt(ctx, common.HexToHash(claim.TxHash))
+		if err != nil {
+			log.Printf("Warning: Revenue backfill could not fetch claim receipt %s: %v", claim.TxHash, err)
+			continue
+		}
+		for _, vLog := range receipt.Logs {
+			event, err := chain.RevenueDistribution.ParseRevenueClaimed(*vLog)
+			if err != nil || event.Claimant.Hex() != common.HexToAddress(claim.WalletAddress).Hex() {
+				continue
+			}
+			if err := database.SetRevenueClaimOnchainID(claim.ID, event.DistributionId.Int64()); err != nil {
+				log.Printf("Warning: Revenue backfill could not update claim %s: %v", claim.ID, err)
+			}
+			break
+		}
+	}
+
+	linked, err := database.LinkRevenueClaims()
+	if err != nil {
+		log.Printf("Warning: Revenue backfill could not link claims: %v", err)
+		return
+	}
+	if len(dists) > 0 || len(claims) > 0 || linked > 0 {
+		log.Printf("Info: Revenue backfill done - %d distribution(s) and %d claim(s) checked, %d claim(s) linked", len(dists), len(claims), linked)
+	}
+}
@@ -0,0 +1,290 @@
+package worker
+
+import (
+	"backend/blockchain"
+	"backend/db"
+	"backend/db/models"
+	"context"
+	"errors"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"gorm.io/gorm"
+)
+
+// indexedEvent - one decoded contract event plus the DB write it triggers
+type indexedEvent struct {
+	Raw   types.Log
+	Apply func() error
+}
+
+// eventSource - a contract the indexer follows with its own block cursor
+// fetch returns every event of interest emitted by the contract in [opts.Start, *opts.End]
+// IndexedBlocks and Rollback are optional, sources that write block-tagged rows use them to undo a reorg
+type eventSource struct {
+	Name    string
+	Address common.Address
+	Fetch   func(opts *bind.FilterOpts) ([]indexedEvent, error)
+
+	IndexedBlocks func(since uint64) ([]db.IndexedBlock, error)
+	Rollback      func(blockHash string) error
+}
+
+// Indexer - durable block-cursor event indexer
+// backfills each contract from its stored cursor using Filter* calls and then polls forward,
+// so events emitted while the backend was down are never lost
+type Indexer struct {
+	chain    *blockchain.ChainService
+	database *db.Database
+	sources  []eventSource
+
+	startBlock    *uint64       // first block for contracts without a cursor (nil = current head)
+	batchSize     uint64        // max blocks per Filter* call
+	pollInterval  time.Duration // delay between polls once caught up
+	confirmations uint64        // blocks an event must be buried under before it is committed
+	reorgWindow   uint64        // how far back to re-check and re-index after a reorg is detected
+}
+
+// NewIndexer - create indexer with settings from environment
+// INDEXER_START_BLOCK, INDEXER_BATCH_SIZE, INDEXER_POLL_INTERVAL (seconds),
+// INDEXER_CONFIRMATIONS, INDEXER_REORG_WINDOW
+func NewIndexer(chain *blockchain.ChainService, database *db.Database) *Indexer {
+	idx := &Indexer{
+		chain:         chain,
+		database:      database,
+		batchSize:     envUint64("INDEXER_BATCH_SIZE", 2000),
+		pollInterval:  time.Duration(envUint64("INDEXER_POLL_INTERVAL", 12)) * time.Second,
+		confirmations: envUint64("INDEXER_CONFIRMATIONS", 6),
+		reorgWindow:   envUint64("INDEXER_REORG_WINDOW", 64),
+	}
+
+	if v := os.Getenv("INDEXER_START_BLOCK"); v != "" {
+		start, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			log.Printf("Warning: Invalid INDEXER_START_BLOCK %q, new cursors will start at the chain head", v)
+		} else {
+			idx.startBlock = &start
+		}
+	}
+	if idx.batchSize == 0 {
+		idx.batchSize = 1
+	}
+	return idx
+}
+
+// AddSource - register a contract to be indexed
+// sources are processed in registration order on every poll
+func (idx *Indexer) AddSource(source eventSource) {
+	idx.sources = append(idx.sources, source)
+}
+
+// Run - index all sources until ctx is cancelled
+func (idx *Indexer) Run(ctx context.Context) {
+	log.Printf("Info: Indexer started for %d contract(s)", len(idx.sources))
+
+	for {
+		caughtUp := true
+		for _, source := range idx.sources {
+			done, err := idx.syncSource(ctx, source)
+			if err != nil {
+				log.Printf("Error: Indexer failed for %s: %v", source.Name, err)
+				continue
+			}
+			if !done {
+				caughtUp = false
+			}
+		}
+
+		// keep going without sleeping while there is still a backlog to work through
+		delay := idx.pollInterval
+		if !caughtUp {
+			delay = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Printf("Info: Indexer stopped")
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// syncSource - process the next batch of blocks for one contract
+// returns true when the source has reached the chain head
+func (idx *Indexer) syncSource(ctx context.Context, source eventSource) (bool, error) {
+	latest, err := idx.chain.Client.BlockNumber(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// only blocks with enough confirmations are indexed
+	var head uint64
+	if latest > idx.confirmations {
+		head = latest - idx.confirmations
+	}
+
+	cursor, err := idx.loadCursor(source, head)
+	if err != nil {
+		return false, err
+	}
+
+	cursor, err = idx.checkReorg(ctx, source, cursor)
+	if err != nil {
+		return false, err
+	}
+
+	from := cursor.LastBlock + 1
+	if from > head {
+		return true, nil
+	}
+	to := from + idx.batchSize - 1
+	if to > head {
+		to = head
+	}
+
+	events, err := source.Fetch(&bind.FilterOpts{Start: from, End: &to, Context: ctx})
+	if err != nil {
+		return false, err
+	}
+
+	// apply in chain order so dependent rows (deposit before claim) land correctly
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Raw.BlockNumber != events[j].Raw.BlockNumber {
+			return events[i].Raw.BlockNumber < events[j].Raw.BlockNumber
+		}
+		return events[i].Raw.Index < events[j].Raw.Index
+	})
+
+	for _, event := range events {
+		if err := event.Apply(); err != nil {
+			// leave the cursor where it is, the whole range is retried on the next poll
+			return false, err
+		}
+	}
+
+	header, err := idx.chain.Client.HeaderByNumber(ctx, new(big.Int).SetUint64(to))
+	if err != nil {
+		return false, err
+	}
+
+	cursor.LastBlock = to
+	cursor.LastBlockHash = header.Hash().Hex()
+	if err := idx.database.SaveIndexerCursor(cursor); err != nil {
+		return false, err
+	}
+
+	if len(events) > 0 {
+		log.Printf("Info: Indexer %s processed %d event(s) in blocks %d-%d", source.Name, len(events), from, to)
+	}
+	return to == head, nil
+}
+
+// checkReorg - compare the cursor block hash with the canonical chain
+// on a mismatch, rows from non-canonical blocks within the reorg window are rolled back
+// and the cursor is rewound so the window is indexed again from the canonical chain
+func (idx *Indexer) checkReorg(ctx context.Context, source eventSource, cursor models.IndexerCursor) (models.IndexerCursor, error) {
+	if cursor.LastBlockHash == "" {
+		return cursor, nil
+	}
+
+	header, err := idx.chain.Client.HeaderByNumber(ctx, new(big.Int).SetUint64(cursor.LastBlock))
+	if err != nil {
+		return cursor, err
+	}
+	if header.Hash().Hex() == cursor.LastBlockHash {
+		return cursor, nil
+	}
+
+	var since uint64
+	if cursor.LastBlock > idx.reorgWindow {
+		since = cursor.LastBlock - idx.reorgWindow
+	}
+	log.Printf("Warning: Reorg detected for %s at block %d (stored %s, canonical %s), rolling back to block %d",
+		source.Name, cursor.LastBlock, cursor.LastBlockHash, header.Hash().Hex(), since)
+
+	if source.IndexedBlocks != nil && source.Rollback != nil {
+		blocks, err := source.IndexedBlocks(since + 1)
+		if err != nil {
+			return cursor, err
+		}
+
+		for _, block := range blocks {
+			canonical, err := idx.chain.Client.HeaderByNumber(ctx, new(big.Int).SetUint64(block.BlockNumber))
+			if err != nil {
+				return cursor, err
+			}
+			if canonical.Hash().Hex() == block.BlockHash {
+				continue
+			}
+
+			log.Printf("Warning: Rolling back %s rows from orphaned block %d (%s)", source.Name, block.BlockNumber, block.BlockHash)
+			if err := source.Rollback(block.BlockHash); err != nil {
+				return cursor, err
+			}
+		}
+	}
+
+	sinceHeader, err := idx.chain.Client.HeaderByNumber(ctx, new(big.Int).SetUint64(since))
+	if err != nil {
+		return cursor, err
+	}
+
+	cursor.LastBlock = since
+	cursor.LastBlockHash = sinceHeader.Hash().Hex()
+	if err := idx.database.SaveIndexerCursor(cursor); err != nil {
+		return cursor, err
+	}
+	return cursor, nil
+}
+
+// loadCursor - get the stored cursor for a source, creating one if missing
+// a cursor for a different contract address (redeployment) is reset
+func (idx *Indexer) loadCursor(source eventSource, head uint64) (models.IndexerCursor, error) {
+	cursor, err := idx.database.GetIndexerCursor(source.Name)
+	if err == nil && cursor.ContractAddress == source.Address.Hex() {
+		return cursor, nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return cursor, err
+	}
+	if err == nil {
+		log.Printf("Warning: Indexer %s contract changed from %s to %s, resetting cursor",
+			source.Name, cursor.ContractAddress, source.Address.Hex())
+	}
+
+	start := head
+	if idx.startBlock != nil {
+		start = *idx.startBlock
+	}
+	if start > 0 {
+		start--
+	}
+
+	log.Printf("Info: Indexer %s starting from block %d", source.Name, start+1)
+	return models.IndexerCursor{
+		Name:            source.Name,
+		ContractAddress: source.Address.Hex(),
+		LastBlock:       start,
+	}, nil
+}
+
+func envUint64(name string, def uint64) uint64 {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		log.Printf("Warning: Invalid %s %q, using default %d", name, v, def)
+		return def
+	}
+	return n
+}
@@ -7,182 +7,404 @@ import (
 	"backend/blockchain/revenue_distribution"
 	"backend/db"
 	"backend/db/models"
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"math/big"
 	"time"
 
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
+// StartListeners - start the block-cursor indexer for every available contract
+// events are backfilled from the last processed block, so nothing is lost across restarts
 func StartListeners(chain *blockchain.ChainService, database *db.Database) {
-	log.Printf("Info: Starting blockchain event listeners...")
+	log.Printf("Info: Starting blockchain event indexer...")
 
+	indexer := NewIndexer(chain, database)
+
+	// properties first, revenue events reference the property by token address
 	if chain.PropertyFactory != nil {
-		go listenForProperties(chain, database)
+		addr, err := chain.Registry.GetPropertyFactory(nil)
+		if err != nil {
+			log.Printf("Warning: Skipping property indexer - factory address unavailable: %v", err)
+		} else {
+			indexer.AddSource(propertySource(chain, database, addr))
+		}
 	} else {
-		log.Printf("Warning: Skipping property listener - contract not available")
+		log.Printf("Warning: Skipping property indexer - contract not available")
 	}
 
-	if chain.RevenueDistribution != nil {
-		go listenForRevenue(chain, database)
-		go listenForRevenueClaims(chain, database)
+	if chain.Approval != nil {
+		addr, err := chain.Registry.GetApprovalService(nil)
+		if err != nil {
+			log.Printf("Warning: Skipping approval indexer - approval service address unavailable: %v", err)
+		} else {
+			indexer.AddSource(approvalSource(chain, database, addr))
+		}
 	} else {
-		log.Printf("Warning: Skipping revenue listeners - contract not available")
+		log.Printf("Warning: Skipping approval indexer - contract not available")
 	}
 
-	if chain.Approval != nil {
-		go listenForApprovals(chain, database)
+	if chain.RevenueDistribution != nil {
+		addr, err := chain.Registry.GetRevenueDistribution(nil)
+		if err != nil {
+			log.Printf("Warning: Skipping revenue indexer - revenue distribution address unavailable: %v", err)
+		} else {
+			indexer.AddSource(revenueSource(chain, database, addr))
+		}
 	} else {
-		log.Printf("Warning: Skipping approval listener - contract not available")
+		log.Printf("Warning: Skipping revenue indexer - contract not available")
 	}
 
-	log.Printf("Success: Event listeners started (only for available contracts)")
+	go func() {
+		// link revenue rows recorded before distribution IDs were tracked, then start indexing
+		if chain.RevenueDistribution != nil {
+			backfillRevenueLinks(chain, database)
+		}
+		indexer.Run(context.Background())
+	}()
+
+	log.Printf("Success: Event indexer started (only for available contracts)")
 }
 
-func listenForProperties(chain *blockchain.ChainService, database *db.Database) {
-	sink := make(chan *property_factory.PropertyFactoryPropertyRegistered)
-	sub, err := chain.PropertyFactory.WatchPropertyRegistered(nil, sink, nil)
-	if err != nil {
-		log.Printf("Warning: Failed to subscribe to property events: %v", err)
-		log.Printf("Info: Property events will not be monitored (RPC may not support subscriptions)")
-		return
-	}
-	log.Println("Info: Listening for New Properties...")
-
-	for {
-		select {
-		case err := <-sub.Err():
-			log.Printf("Property Subscription error: %v", err)
-			time.Sleep(5 * time.Second)
-		case event := <-sink:
-			log.Printf("Info: Event: Property Registered at %s", event.PropertyAsset.Hex())
-			
-			// Check if property already exists by asset address OR token address to prevent duplicates
-			assetAddr := event.PropertyAsset.Hex()
-			tokenAddr := event.PropertyToken.Hex()
-			
-			// Check by asset address first
-			existingProp, err := database.GetPropertyByAssetAddress(assetAddr)
-			if err == nil {
-				log.Printf("Warning: Property already exists with asset address %s (ID: %s), skipping duplicate creation", assetAddr, existingProp.ID)
-				continue
-			}
-			
-			// Also check by token address as fallback
-			existingProp, err = database.GetPropertyByTokenAddress(tokenAddr)
-			if err == nil {
-				log.Printf("Warning: Property already exists with token address %s (ID: %s), skipping duplicate creation", tokenAddr, existingProp.ID)
-				continue
+// propertySourceName - cursor name of the property source, the revenue source waits for it
+const propertySourceName = "property_factory"
+
+// propertySource - PropertyRegistered events from the PropertyFactory
+func propertySource(chain *blockchain.ChainService, database *db.Database, addr common.Address) eventSource {
+	return eventSource{
+		Name:          propertySourceName,
+		Address:       addr,
+		IndexedBlocks: database.GetPropertyBlocksSince,
+		Rollback:      database.DeletePropertiesByBlockHash,
+		Fetch: func(opts *bind.FilterOpts) ([]indexedEvent, error) {
+			it, err := chain.PropertyFactory.FilterPropertyRegistered(opts, nil)
+			if err != nil {
+				return nil, err
 			}
+			defer it.Close()
 
-			// Property not found in database - this event listener only checks for duplicates
-// Property creation is handled by the API endpoints (ApprovePropertyUploadRequest, CreateProperty)
-// This listener serves as a backup duplicate prevention mechanism onlyy
-			log.Printf("Info: Property event detected but property not found in database. Property creation should be handled by API endpoints.")
-		}
+			var events []indexedEvent
+			for it.Next() {
+				event := it.Event
+				events = append(events, indexedEvent{
+					Raw:   event.Raw,
+					Apply: func() error { return handlePropertyRegistered(chain, database, event) },
+				})
+			}
+			return events, it.Error()
+		},
 	}
 }
 
-func listenForRevenue(chain *blockchain.ChainService, database *db.Database) {
-	sink := make(chan *revenue_distribution.RevenueDistributionRevenueDeposited)
-	sub, err := chain.RevenueDistribution.WatchRevenueDeposited(nil, sink, nil, nil)
-	if err != nil {
-		log.Printf("Warning: Failed to subscribe to revenue events: %v", err)
-		log.Printf("Info: Revenue events will not be monitored (RPC may not support subscriptions)")
-		return
-	}
-	log.Println("Info: Listening for Revenue Deposits...")
-
-	for {
-		select {
-		case err := <-sub.Err():
-			log.Printf("Revenue Subscription error: %v", err)
-			time.Sleep(5 * time.Second)
-		case event := <-sink:
-			log.Printf("Info: Event: Revenue Deposited for Token %s", event.Token.Hex())
-			prop, err := database.GetPropertyByTokenAddress(event.Token.Hex())
+// approvalSource - Approved, Revoked and UserRejected events from the ApprovalService
+// rejectUser emits Revoked then UserRejected, applied in log order the user ends up rejected
+func approvalSource(chain *blockchain.ChainService, database *db.Database, addr common.Address) eventSource {
+	return eventSource{
+		Name:          "approval_service",
+		Address:       addr,
+		IndexedBlocks: database.GetApprovalBlocksSince,
+		Rollback: func(blockHash string) error {
+			return database.ResetUserApprovalsByBlockHash(blockHash, func(wallet string) (bool, error) {
+				return chain.Approval.IsApproved(nil, common.HexToAddress(wallet))
+			})
+		},
+		Fetch: func(opts *bind.FilterOpts) ([]indexedEvent, error) {
+			var events []indexedEvent
+
+			approved, err := chain.Approval.FilterApproved(opts, nil)
 			if err != nil {
-				log.Printf("Error: Property not found for token %s", event.Token.Hex())
-				continue
+				return nil, err
+			}
+			defer approved.Close()
+			for approved.Next() {
+				event := approved.Event
+				events = append(events, indexedEvent{
+					Raw:   event.Raw,
+					Apply: func() error { return handleApproved(database, event) },
+				})
+			}
+			if err := approved.Error(); err != nil {
+				return nil, err
 			}
 
-			newDist := models.RevenueDistribution{
-				ID:               uuid.New(),
-				PropertyID:       prop.ID,
-				SnapshotID:       int32(event.SnapshotId.Int64()),
-				StablecoinTxHash: event.Raw.TxHash.Hex(),
-				TotalAmount:      event.Amount.String(),
-				CreatedAt:        time.Now(),
+			revoked, err := chain.Approval.FilterRevoked(opts, nil)
+			if err != nil {
+				return nil, err
+			}
+			defer revoked.Close()
+			for revoked.Next() {
+				event := revoked.Event
+				events = append(events, indexedEvent{
+					Raw:   event.Raw,
+					Apply: func() error { return handleRevoked(database, event) },
+				})
+			}
+			if err := revoked.Error(); err != nil {
+				return nil, err
 			}
 
-			if err := database.CreateRevenueDistribution(newDist); err != nil {
-				log.Printf("Error: DB Error saving revenue: %v", err)
-			} else {
-				log.Printf("Success: Revenue Distribution saved.")
+			rejected, err := chain.Approval.FilterUserRejected(opts, nil)
+			if err != nil {
+				return nil, err
 			}
-		}
+			defer rejected.Close()
+			for rejected.Next() {
+				event := rejected.Event
+				events = append(events, indexedEvent{
+					Raw:   event.Raw,
+					Apply: func() error { return handleUserRejected(database, event) },
+				})
+			}
+			return events, rejected.Error()
+		},
 	}
 }
 
-func listenForApprovals(chain *blockchain.ChainService, database *db.Database) {
-	sink := make(chan *approval_service.ApprovalServiceApproved)
-	sub, err := chain.Approval.WatchApproved(nil, sink, nil)
-	if err != nil {
-		log.Printf("Warning: Failed to subscribe to approval events: %v", err)
-		log.Printf("Info: Approval events will not be monitored (RPC may not support subscriptions)")
-		return
-	}
-	log.Println("Info: Listening for User Approvals...")
-
-	for {
-		select {
-		case err := <-sub.Err():
-			log.Printf("Approval Subscription error: %v", err)
-			time.Sleep(5 * time.Second)
-		case event := <-sink:
-			userWallet := event.User.Hex()
-			log.Printf("Info: Event: User Approved %s", userWallet)
-
-			if err := database.UpdateUserApproval(userWallet, models.ApprovalApproved); err != nil {
-				log.Printf("Error: DB Error updating user approval: %v", err)
+// revenueSource - RevenueDeposited and RevenueClaimed events from RevenueDistribution
+func revenueSource(chain *blockchain.ChainService, database *db.Database, addr common.Address) eventSource {
+	return eventSource{
+		Name:          "revenue_distribution",
+		Address:       addr,
+		IndexedBlocks: database.GetRevenueBlocksSince,
+		Rollback:      database.DeleteRevenueRowsByBlockHash,
+		Fetch: func(opts *bind.FilterOpts) ([]indexedEvent, error) {
+			var events []indexedEvent
+
+			deposits, err := chain.RevenueDistribution.FilterRevenueDeposited(opts, nil, nil)
+			if err != nil {
+				return nil, err
+			}
+			defer deposits.Close()
+			for deposits.Next() {
+				event := deposits.Event
+				events = append(events, indexedEvent{
+					Raw:   event.Raw,
+					Apply: func() error { return handleRevenueDeposited(database, event) },
+				})
 			}
+			if err := deposits.Error(); err != nil {
+				return nil, err
+			}
+
+			claims, err := chain.RevenueDistribution.FilterRevenueClaimed(opts, nil, nil)
+			if err != nil {
+				return nil, err
+			}
+			defer claims.Close()
+			for claims.Next() {
+				event := claims.Event
+				events = append(events, indexedEvent{
+					Raw:   event.Raw,
+					Apply: func() error { return handleRevenueClaimed(database, event) },
+				})
+			}
+			return events, claims.Error()
+		},
+	}
+}
+
+// handlePropertyRegistered - record properties created on-chain that aren't in the database yet
+// covers API requests whose DB write failed and properties created outside the API
+func handlePropertyRegistered(chain *blockchain.ChainService, database *db.Database, event *property_factory.PropertyFactoryPropertyRegistered) error {
+	log.Printf("Info: Event: Property Registered at %s", event.PropertyAsset.Hex())
+
+	// Check if property already exists by asset address OR token address to prevent duplicates
+	assetAddr := event.PropertyAsset.Hex()
+	tokenAddr := event.PropertyToken.Hex()
+
+	// Check by asset address first
+	existingProp, err := database.GetPropertyByAssetAddress(assetAddr)
+	if err == nil {
+		log.Printf("Info: Property already exists with asset address %s (ID: %s)", assetAddr, existingProp.ID)
+		return nil
+	}
+
+	// Also check by token address as fallback
+	existingProp, err = database.GetPropertyByTokenAddress(tokenAddr)
+	if err == nil {
+		log.Printf("Info: Property already exists with token address %s (ID: %s)", tokenAddr, existingProp.ID)
+		return nil
+	}
+
+	// valuation is emitted in wei
+	valuation, _ := new(big.Float).Quo(new(big.Float).SetInt(event.Valuation), big.NewFloat(1e18)).Float64()
+
+	prop := models.Property{
+		ID:                  uuid.New(),
+		OnchainAssetAddress: assetAddr,
+		OnchainTokenAddress: tokenAddr,
+		OwnerWallet:         event.Owner.Hex(),
+		MetadataHash:        event.PropertyDataHash,
+		Valuation:           valuation,
+		Status:              models.StatusActive,
+		TxHash:              event.Raw.TxHash.Hex(),
+		BlockNumber:         event.Raw.BlockNumber,
+		BlockHash:           event.Raw.BlockHash.Hex(),
+		CreatedAt:           time.Now(),
+	}
+
+	// a pending upload request for the same owner and documents is the one that was approved
+	request, err := database.GetPendingUploadRequestByOwnerAndHash(prop.OwnerWallet, prop.MetadataHash)
+	matched := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	if matched {
+		prop.Name = request.Name
+		prop.Valuation = request.Valuation
+	} else {
+		name, err := chain.GetPropertyName(assetAddr)
+		if err != nil {
+			return err
+		}
+		prop.Name = name
+	}
+
+	saved, created, err := database.CreatePropertyIfMissing(prop)
+	if err != nil {
+		log.Printf("Error: DB Error saving property %s: %v", assetAddr, err)
+		return err
+	}
+	if created {
+		log.Printf("Success: Property %s recorded from chain (ID: %s)", assetAddr, saved.ID)
+	}
+
+	if matched {
+		if err := database.UpdatePropertyUploadRequestStatus(request.ID.String(), models.ApprovalApproved, ""); err != nil {
+			return err
 		}
+		log.Printf("Success: Upload request %s marked approved for property %s", request.ID, saved.ID)
 	}
+	return nil
 }
 
-func listenForRevenueClaims(chain *blockchain.ChainService, database *db.Database) {
-	sink := make(chan *revenue_distribution.RevenueDistributionRevenueClaimed)
-	sub, err := chain.RevenueDistribution.WatchRevenueClaimed(nil, sink, nil, nil)
+func handleRevenueDeposited(database *db.Database, event *revenue_distribution.RevenueDistributionRevenueDeposited) error {
+	log.Printf("Info: Event: Revenue Deposited for Token %s", event.Token.Hex())
+
+	exists, err := database.RevenueDistributionExists(event.Raw.TxHash.Hex())
 	if err != nil {
-		log.Printf("Warning: Failed to subscribe to claim events: %v", err)
-		log.Printf("Info: Claim events will not be monitored (RPC may not support subscriptions)")
-		return
-	}
-	log.Println("Info: Listening for Revenue Claims...")
-
-	for {
-		select {
-		case err := <-sub.Err():
-			log.Printf("Claim Subscription error: %v", err)
-			time.Sleep(5 * time.Second)
-		case event := <-sink:
-			// Note: Finding the exact Distribution parent in DB is tricky purely from event data
-			// if we don't index distributions by ID on chain easily.
-			// For now, we log the raw claim. In a production app, we would query the Distribution ID.
-
-			log.Printf("Info: Event: Revenue Claimed by %s Amount: %s", event.Claimant.Hex(), event.Amount.String())
-
-			newClaim := models.RevenueClaim{
-				ID:            uuid.New(),
-				WalletAddress: event.Claimant.Hex(),
-				Amount:        event.Amount.String(),
-				TxHash:        event.Raw.TxHash.Hex(),
-				ClaimedAt:     time.Now(),
-				// RevenueDistributionID would be linked here if we query distributionId from DB
-			}
+		return err
+	}
+	if exists {
+		return nil
+	}
 
-			if err := database.CreateRevenueClaim(newClaim); err != nil {
-				log.Printf("Error: DB Error saving claim: %v", err)
-			}
+	prop, err := database.GetPropertyByTokenAddress(event.Token.Hex())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// the property source has its own cursor, until it is past this block the property may still come
+		cursor, cursorErr := database.GetIndexerCursor(propertySourceName)
+		if cursorErr != nil || cursor.LastBlock < event.Raw.BlockNumber {
+			return fmt.Errorf("property for token %s not indexed yet, block %d is retried", event.Token.Hex(), event.Raw.BlockNumber)
 		}
+		log.Printf("Warning: No property for token %s, deposit %s is not from this platform", event.Token.Hex(), event.Raw.TxHash.Hex())
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	distributionID := event.DistributionId.Int64()
+	newDist := models.RevenueDistribution{
+		ID:                    uuid.New(),
+		PropertyID:            prop.ID,
+		SnapshotID:            int32(event.SnapshotId.Int64()),
+		OnchainDistributionID: &distributionID,
+		StablecoinTxHash:      event.Raw.TxHash.Hex(),
+		TotalAmount:           event.Amount.String(),
+		BlockNumber:           event.Raw.BlockNumber,
+		BlockHash:             event.Raw.BlockHash.Hex(),
+		CreatedAt:             time.Now(),
+	}
+
+	if err := database.CreateRevenueDistribution(newDist); err != nil {
+		log.Printf("Error: DB Error saving revenue: %v", err)
+		return err
+	}
+	log.Printf("Success: Revenue Distribution saved.")
+
+	// claims indexed before their deposit can now be linked
+	if _, err := database.LinkRevenueClaims(); err != nil {
+		log.Printf("Warning: Failed to link revenue claims: %v", err)
+	}
+	return nil
+}
+
+func handleApproved(database *db.Database, event *approval_service.ApprovalServiceApproved) error {
+	userWallet := event.User.Hex()
+	log.Printf("Info: Event: User Approved %s", userWallet)
+
+	if err := database.UpdateUserApprovalFromChain(userWallet, models.ApprovalApproved, event.Raw.BlockNumber, event.Raw.BlockHash.Hex()); err != nil {
+		log.Printf("Error: DB Error updating user approval: %v", err)
+		return err
+	}
+	return nil
+}
+
+func handleRevoked(database *db.Database, event *approval_service.ApprovalServiceRevoked) error {
+	userWallet := event.User.Hex()
+	log.Printf("Info: Event: User Revoked %s", userWallet)
+
+	if err := database.UpdateUserApprovalFromChain(userWallet, models.ApprovalRevoked, event.Raw.BlockNumber, event.Raw.BlockHash.Hex()); err != nil {
+		log.Printf("Error: DB Error updating user approval: %v", err)
+		return err
+	}
+	return nil
+}
+
+func handleUserRejected(database *db.Database, event *approval_service.ApprovalServiceUserRejected) error {
+	userWallet := event.User.Hex()
+	log.Printf("Info: Event: User Rejected %s", userWallet)
+
+	if err := database.UpdateUserApprovalFromChain(userWallet, models.ApprovalRejected, event.Raw.BlockNumber, event.Raw.BlockHash.Hex()); err != nil {
+		log.Printf("Error: DB Error updating user approval: %v", err)
+		return err
+	}
+	return nil
+}
+
+func handleRevenueClaimed(database *db.Database, event *revenue_distribution.RevenueDistributionRevenueClaimed) error {
+	log.Printf("Info: Event: Revenue Claimed by %s Amount: %s", event.Claimant.Hex(), event.Amount.String())
+
+	exists, err := database.RevenueClaimExists(event.Raw.TxHash.Hex())
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	distributionID := event.DistributionId.Int64()
+	newClaim := models.RevenueClaim{
+		ID:                    uuid.New(),
+		OnchainDistributionID: &distributionID,
+		WalletAddress:         event.Claimant.Hex(),
+		Amount:                event.Amount.String(),
+		TxHash:                event.Raw.TxHash.Hex(),
+		BlockNumber:           event.Raw.BlockNumber,
+		BlockHash:             event.Raw.BlockHash.Hex(),
+		ClaimedAt:             time.Now(),
+	}
+
+	// resolve the parent distribution, if it isn't indexed yet the claim is linked when it is
+	dist, err := database.GetRevenueDistributionByOnchainID(distributionID)
+	if err == nil {
+		newClaim.RevenueDistributionID = &dist.ID
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	} else {
+		log.Printf("Warning: Distribution %d not indexed yet, claim %s stored unlinked", distributionID, newClaim.TxHash)
+	}
+
+	if err := database.CreateRevenueClaim(newClaim); err != nil {
+		log.Printf("Error: DB Error saving claim: %v", err)
+		return err
 	}
+	return nil
 }
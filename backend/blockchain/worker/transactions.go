@@ -0,0 +1,152 @@
+package worker
+
+import (
+	"backend/blockchain"
+	"backend/db"
+	"backend/db/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/google/uuid"
+)
+
+// TransactionTracker - stores every backend transaction as a pending audit log entry
+// satisfies blockchain.TxTracker
+type TransactionTracker struct {
+	database *db.Database
+}
+
+func NewTransactionTracker(database *db.Database) *TransactionTracker {
+	return &TransactionTracker{database: database}
+}
+
+// TrackTransaction - insert a pending Transaction row for a submitted transaction
+func (t *TransactionTracker) TrackTransaction(kind models.TransactionType, signer, relatedWallet string, tx *types.Transaction, details map[string]any) error {
+	if details == nil {
+		details = map[string]any{}
+	}
+	details["nonce"] = tx.Nonce()
+	if tx.To() != nil {
+		details["to"] = tx.To().Hex()
+	}
+
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	return t.database.CreateTransaction(models.Transaction{
+		ID:            uuid.New(),
+		WalletAddress: signer,
+		RelatedWallet: relatedWallet,
+		Type:          kind,
+		Status:        models.TxStatusPending,
+		TxHash:        tx.Hash().Hex(),
+		Details:       string(detailsJSON),
+		CreatedAt:     time.Now(),
+	})
+}
+
+// TrackReplacement - point the recorded transaction at the hash that replaced it
+func (t *TransactionTracker) TrackReplacement(oldHash, newHash common.Hash) error {
+	return t.database.ReplaceTransactionHash(oldHash.Hex(), newHash.Hex())
+}
+
+// StartTransactionConfirmer - poll pending transactions and settle them from their receipts
+// TX_CONFIRM_INTERVAL (seconds, default 15), TX_DROP_TIMEOUT (minutes, default 60)
+func StartTransactionConfirmer(chain *blockchain.ChainService, database *db.Database) {
+	interval := time.Duration(envUint64("TX_CONFIRM_INTERVAL", 15)) * time.Second
+	dropTimeout := time.Duration(envUint64("TX_DROP_TIMEOUT", 60)) * time.Minute
+
+	go func() {
+		log.Printf("Info: Transaction confirmer started")
+		for {
+			confirmPendingTransactions(chain, database, dropTimeout)
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func confirmPendingTransactions(chain *blockchain.ChainService, database *db.Database, dropTimeout time.Duration) {
+	pending, err := database.GetPendingTransactions(100)
+	if err != nil {
+		log.Printf("Error: Failed to load pending transactions: %v", err)
+		return
+	}
+
+	for _, tx := range pending {
+		receipt, minedHash, err := minedReceipt(context.Background(), chain, tx)
+		if err != nil {
+			if !errors.Is(err, ethereum.NotFound) {
+				log.Printf("Warning: Failed to get receipt for %s: %v", tx.TxHash, err)
+				continue
+			}
+
+			// not mined within the timeout and no longer in the node's mempool, it was dropped
+			if time.Since(tx.CreatedAt) > dropTimeout {
+				if _, _, lookupErr := chain.Client.TransactionByHash(context.Background(), common.HexToHash(tx.TxHash)); errors.Is(lookupErr, ethereum.NotFound) {
+					log.Printf("Warning: Transaction %s (%s) dropped after %s, marking failed", tx.TxHash, tx.Type, dropTimeout)
+					if err := database.UpdateTransactionStatus(tx.TxHash, models.TxStatusFailed); err != nil {
+						log.Printf("Error: Failed to update transaction %s: %v", tx.TxHash, err)
+					}
+					// the dropped nonce is free again, later sends would otherwise queue behind the gap
+					chain.Signer().Resync()
+				}
+			}
+			continue
+		}
+
+		// the original was mined instead of its fee-bumped replacement, the row follows it
+		if minedHash != tx.TxHash {
+			log.Printf("Info: Transaction %s was replaced by %s, but the original was mined", minedHash, tx.TxHash)
+			if err := database.ReplaceTransactionHash(tx.TxHash, minedHash); err != nil {
+				log.Printf("Error: Failed to update transaction %s: %v", tx.TxHash, err)
+				continue
+			}
+		}
+
+		status := models.TxStatusConfirmed
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			status = models.TxStatusFailed
+		}
+		if err := database.UpdateTransactionStatus(minedHash, status); err != nil {
+			log.Printf("Error: Failed to update transaction %s: %v", minedHash, err)
+			continue
+		}
+		log.Printf("Info: Transaction %s (%s) %s in block %d", minedHash, tx.Type, status, receipt.BlockNumber)
+	}
+}
+
+// minedReceipt - receipt of a recorded transaction and the hash it was mined under, which is the current hash
+// or, after a fee-bump, one of the hashes in details.replaced_hashes; ethereum.NotFound while none of them is mined
+func minedReceipt(ctx context.Context, chain *blockchain.ChainService, tx models.Transaction) (*types.Receipt, string, error) {
+	receipt, err := chain.Client.TransactionReceipt(ctx, common.HexToHash(tx.TxHash))
+	if !errors.Is(err, ethereum.NotFound) {
+		return receipt, tx.TxHash, err
+	}
+
+	var details struct {
+		ReplacedHashes []string `json:"replaced_hashes"`
+	}
+	if tx.Details != "" {
+		if jsonErr := json.Unmarshal([]byte(tx.Details), &details); jsonErr != nil {
+			log.Printf("Warning: Transaction %s has invalid details: %v", tx.TxHash, jsonErr)
+		}
+	}
+	for _, hash := range details.ReplacedHashes {
+		replaced, replacedErr := chain.Client.TransactionReceipt(ctx, common.HexToHash(hash))
+		if replacedErr == nil {
+			return replaced, hash, nil
+		}
+		if !errors.Is(replacedErr, ethereum.NotFound) {
+			return nil, tx.TxHash, replacedErr
+		}
+	}
+	return nil, tx.TxHash, err
+}
@@ -8,11 +8,15 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Database struct {
@@ -39,6 +43,12 @@ func (db *Database) createEnums() error {
             IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'transaction_type') THEN
             	CREATE TYPE transaction_type AS ENUM ('approve_user', 'create_property', 'deposit_revenue', 'claim_revenue', 'transfer_token');
             END IF;
+            IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'job_status') THEN
+                CREATE TYPE job_status AS ENUM ('queued', 'running', 'succeeded', 'failed');
+            END IF;
+            IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'purchase_status') THEN
+                CREATE TYPE purchase_status AS ENUM ('awaiting_payment', 'paid', 'approved', 'transferred', 'declined', 'cancelled', 'expired', 'refunded');
+            END IF;
         END
         $$;
     `
@@ -48,6 +58,29 @@ func (db *Database) createEnums() error {
 	return nil
 }
 
+// extendEnums adds values introduced after the enum types were first created
+// ALTER TYPE ... ADD VALUE can't run inside the DO block above, so each is its own statement
+func (db *Database) extendEnums() error {
+	statements := []string{
+		`ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'approve_property'`,
+		`ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'reject_property'`,
+		`ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'grant_role'`,
+		`ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'revoke_role'`,
+		`ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'reject_user'`,
+		`ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'revoke_user'`,
+		`ALTER TYPE approval_status ADD VALUE IF NOT EXISTS 'revoked'`,
+		`ALTER TYPE user_role ADD VALUE IF NOT EXISTS 'compliance_reviewer'`,
+		`ALTER TYPE user_role ADD VALUE IF NOT EXISTS 'property_manager'`,
+		`ALTER TYPE user_role ADD VALUE IF NOT EXISTS 'revenue_distributor'`,
+	}
+	for _, sql := range statements {
+		if err := db.db.Exec(sql).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (db *Database) seedAdmin() error {
 	// 1. Configuration (Env or Default)
 	email := os.Getenv("ADMIN_EMAIL")
@@ -84,12 +117,13 @@ func (db *Database) seedAdmin() error {
 			IF EXISTS (SELECT 1 FROM users WHERE email = target_email) THEN
 				-- Update existing user to be admin
 				UPDATE users
-				SET role = 'admin', approval_status = 'approved', password_hash = target_hash
+				SET role = 'admin', approval_status = 'approved', password_hash = target_hash,
+					email_verified_at = COALESCE(email_verified_at, NOW())
 				WHERE email = target_email;
 			ELSE
 				-- Insert new admin user
-				INSERT INTO users (id, email, name, wallet_address, password_hash, role, approval_status, created_at, updated_at)
-				VALUES (new_id, target_email, 'System Admin', target_wallet, target_hash, 'admin', 'approved', NOW(), NOW());
+				INSERT INTO users (id, email, name, wallet_address, password_hash, role, approval_status, email_verified_at, created_at, updated_at)
+				VALUES (new_id, target_email, 'System Admin', target_wallet, target_hash, 'admin', 'approved', NOW(), NOW(), NOW());
 			END IF;
 		END
 		$$;
@@ -103,6 +137,13 @@ func (db *Database) migrate() error {
 	if err := db.createEnums(); err != nil {
 		return fmt.Errorf("failed to create enums: %w", err)
 	}
+	if err := db.extendEnums(); err != nil {
+		return fmt.Errorf("failed to extend enums: %w", err)
+	}
+
+	// purchases recorded before the status column was added only had token_tx_hash = 'pending' as their state
+	backfillPurchaseStatus := db.db.Migrator().HasTable(&models.TokenPurchase{}) &&
+		!db.db.Migrator().HasColumn(&models.TokenPurchase{}, "status")
 
 	log.Println("Info: Running database migrations...")
 	err := db.db.AutoMigrate(
@@ -115,12 +156,65 @@ func (db *Database) migrate() error {
 		&models.PropertyUploadRequest{},
 		&models.PropertyUploadRequestDocument{},
 		&models.TokenPurchase{},
+		&models.IndexerCursor{},
+		&models.Job{},
+		&models.RefreshToken{},
+		&models.RevokedToken{},
+		&models.AuthNonce{},
+		&models.RoleAssignment{},
+		&models.UserToken{},
+		&models.RecoveryCode{},
+		&models.AuthLockout{},
+		&models.RateLimitCounter{},
+		&models.ServiceAccount{},
+		&models.APIKey{},
+		&models.KYCSubmission{},
+		&models.KYCDocument{},
+		&models.KYCCheck{},
+		&models.KYCStatusChange{},
+		&models.Offering{},
+		&models.Order{},
+		&models.Trade{},
 	)
 
 	if err != nil {
 		return fmt.Errorf("migration failed: %w", err)
 	}
 
+	if backfillPurchaseStatus {
+		if err := db.db.Exec(`UPDATE token_purchases SET
+			status = CASE WHEN token_tx_hash = 'pending' THEN 'paid'::purchase_status ELSE 'transferred'::purchase_status END,
+			token_tx_hash = CASE WHEN token_tx_hash = 'pending' THEN '' ELSE token_tx_hash END`).Error; err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+		if err := db.db.Exec(`ALTER TABLE token_purchases ALTER COLUMN token_tx_hash DROP DEFAULT`).Error; err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+	}
+
+	// a failed job can be enqueued again under the same key, and so can a succeeded one keyed with active_key_only,
+	// any other state blocks duplicates; the index replaces idx_jobs_active_idempotency_key, which ignored active_key_only
+	if err := db.db.Exec(`DROP INDEX IF EXISTS idx_jobs_active_idempotency_key`).Error; err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+	if err := db.db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_idempotency_key_unique
+		ON jobs (idempotency_key) WHERE ` + jobKeyHeld).Error; err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+
+	// a transaction settles at most one purchase or trade, checking first can't stop two concurrent requests
+	for _, table := range []string{"token_purchases", "trades"} {
+		for _, column := range []string{"payment_tx_hash", "token_tx_hash", "refund_tx_hash"} {
+			if table == "trades" && column == "refund_tx_hash" {
+				continue
+			}
+			if err := db.db.Exec(fmt.Sprintf(`CREATE UNIQUE INDEX IF NOT EXISTS idx_%s_%s_unique
+				ON %s (LOWER(%s)) WHERE %s <> ''`, table, column, table, column, column)).Error; err != nil {
+				return fmt.Errorf("migration failed: %w", err)
+			}
+		}
+	}
+
 	log.Println("Success: Database migrations completed successfully")
 	return db.seedAdmin()
 }
@@ -146,10 +240,10 @@ func NewDatabase() (db *Database, err error) {
 	return
 }
 
-func (db *Database) CreateUser(details auth.RegisterUserPayload) error {
+func (db *Database) CreateUser(details auth.RegisterUserPayload) (models.User, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(details.Password), bcrypt.DefaultCost)
 	if err != nil {
-		return fmt.Errorf("failed to hash password: %w", err)
+		return models.User{}, fmt.Errorf("failed to hash password: %w", err)
 	}
 
 	newUser := models.User{
@@ -161,7 +255,8 @@ func (db *Database) CreateUser(details auth.RegisterUserPayload) error {
 		Role:          models.RoleUser,
 	}
 
-	return gorm.G[models.User](db.db).Create(db.ctx, &newUser)
+	err = gorm.G[models.User](db.db).Create(db.ctx, &newUser)
+	return newUser, err
 }
 
 func (db *Database) GetUserById(id string) (models.User, error) {
@@ -203,6 +298,8 @@ func (db *Database) UpdateUserApproval(wallet string, status models.ApprovalStat
 		approved = models.ApprovalApproved
 	case models.ApprovalRejected:
 		approved = models.ApprovalRejected
+	case models.ApprovalRevoked:
+		approved = models.ApprovalRevoked
 	default:
 		return errors.New("invalid approval status")
 	}
@@ -213,12 +310,59 @@ func (db *Database) UpdateUserApproval(wallet string, status models.ApprovalStat
 	return err
 }
 
+// UpdateUserApprovalFromChain records an approval, revocation or rejection indexed from an on-chain event
+// together with the block it came from, so it can be rolled back after a reorg
+func (db *Database) UpdateUserApprovalFromChain(wallet string, status models.ApprovalStatus, blockNumber uint64, blockHash string) error {
+	result := db.db.WithContext(db.ctx).
+		Model(&models.User{}).
+		Where("wallet_address = ?", wallet).
+		Updates(map[string]interface{}{
+			"approval_previous_status": gorm.Expr("approval_status"),
+			"approval_status":          status,
+			"approval_block_number":    blockNumber,
+			"approval_block_hash":      blockHash,
+		})
+	return result.Error
+}
+
 // --- Property Methods ---
 
 func (db *Database) CreateProperty(prop models.Property) error {
 	return gorm.G[models.Property](db.db).Create(db.ctx, &prop)
 }
 
+// CreatePropertyIfMissing inserts prop unless a property with the same asset address exists
+// API jobs and the indexer can both record a new property, the advisory lock keeps them from racing
+// returns the stored property and whether it was created by this call
+func (db *Database) CreatePropertyIfMissing(prop models.Property) (result models.Property, created bool, err error) {
+	err = db.db.WithContext(db.ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(LOWER(?)))", prop.OnchainAssetAddress).Error; err != nil {
+			return err
+		}
+
+		res := tx.Where("LOWER(onchain_asset_address) = LOWER(?)", prop.OnchainAssetAddress).Limit(1).Find(&result)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected > 0 {
+			return nil
+		}
+
+		if err := tx.Create(&prop).Error; err != nil {
+			return err
+		}
+		result = prop
+		created = true
+		return nil
+	})
+	return
+}
+
+// CountPropertyDocuments returns how many documents are linked to a property
+func (db *Database) CountPropertyDocuments(propertyID uuid.UUID) (int64, error) {
+	return gorm.G[models.PropertyDocument](db.db).Where("property_id = ?", propertyID).Count(db.ctx, "*")
+}
+
 func (db *Database) CreatePropertyDocument(doc models.PropertyDocument) error {
 	return gorm.G[models.PropertyDocument](db.db).Create(db.ctx, &doc)
 }
@@ -228,6 +372,14 @@ func (db *Database) GetAllProperties() (result []models.Property, err error) {
 	return
 }
 
+// GetTokenizedProperties lists every property with a token contract, whatever its status
+func (db *Database) GetTokenizedProperties() ([]models.Property, error) {
+	return gorm.G[models.Property](db.db).
+		Where("onchain_token_address <> ''").
+		Order("created_at ASC").
+		Find(db.ctx)
+}
+
 func (db *Database) GetPropertyByID(id string) (result models.Property, err error) {
 	uid, err := uuid.Parse(id)
 	if err != nil {
@@ -280,12 +432,129 @@ func (db *Database) CreateRevenueClaim(claim models.RevenueClaim) error {
 	return gorm.G[models.RevenueClaim](db.db).Create(db.ctx, &claim)
 }
 
+// RevenueDistributionExists checks if a deposit transaction is already recorded
+// the indexer can replay a block range after a restart, so inserts must be idempotent
+func (db *Database) RevenueDistributionExists(txHash string) (bool, error) {
+	_, err := gorm.G[models.RevenueDistribution](db.db).Where("stablecoin_tx_hash = ?", txHash).First(db.ctx)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RevenueClaimExists checks if a claim transaction is already recorded
+func (db *Database) RevenueClaimExists(txHash string) (bool, error) {
+	_, err := gorm.G[models.RevenueClaim](db.db).Where("tx_hash = ?", txHash).First(db.ctx)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func (db *Database) GetRevenueDistributionBySnapshot(propertyID uuid.UUID, snapshotID int32) (models.RevenueDistribution, error) {
 	return gorm.G[models.RevenueDistribution](db.db).
 		Where("property_id = ? AND snapshot_id = ?", propertyID, snapshotID).
 		First(db.ctx)
 }
 
+func (db *Database) GetRevenueDistributionByOnchainID(distributionID int64) (models.RevenueDistribution, error) {
+	return gorm.G[models.RevenueDistribution](db.db).
+		Where("onchain_distribution_id = ?", distributionID).
+		First(db.ctx)
+}
+
+func (db *Database) GetRevenueDistributionsByProperty(propertyID string) (result []models.RevenueDistribution, err error) {
+	uid, err := uuid.Parse(propertyID)
+	if err != nil {
+		return nil, err
+	}
+	result, err = gorm.G[models.RevenueDistribution](db.db).
+		Where("property_id = ?", uid).
+		Order("created_at DESC").
+		Find(db.ctx)
+	return
+}
+
+// GetIndexedRevenueDistributions returns all distributions that have an on-chain distribution ID
+func (db *Database) GetIndexedRevenueDistributions() ([]models.RevenueDistribution, error) {
+	return gorm.G[models.RevenueDistribution](db.db).
+		Where("onchain_distribution_id IS NOT NULL").
+		Order("created_at DESC").
+		Find(db.ctx)
+}
+
+// GetRevenueClaimsByDistribution returns every claim made against one distribution
+func (db *Database) GetRevenueClaimsByDistribution(distributionID string) (result []models.RevenueClaim, err error) {
+	uid, err := uuid.Parse(distributionID)
+	if err != nil {
+		return nil, err
+	}
+	result, err = gorm.G[models.RevenueClaim](db.db).
+		Where("revenue_distribution_id = ?", uid).
+		Order("claimed_at DESC").
+		Find(db.ctx)
+	return
+}
+
+// GetRevenueClaimsByWallet returns every claim made by an investor wallet
+func (db *Database) GetRevenueClaimsByWallet(wallet string) (result []models.RevenueClaim, err error) {
+	result, err = gorm.G[models.RevenueClaim](db.db).
+		Where("LOWER(wallet_address) = LOWER(?)", wallet).
+		Order("claimed_at DESC").
+		Find(db.ctx)
+	return
+}
+
+// GetRevenueDistributionsMissingOnchainID returns distributions recorded before the on-chain ID was stored
+func (db *Database) GetRevenueDistributionsMissingOnchainID() ([]models.RevenueDistribution, error) {
+	return gorm.G[models.RevenueDistribution](db.db).Where("onchain_distribution_id IS NULL").Find(db.ctx)
+}
+
+// GetRevenueClaimsMissingOnchainID returns claims recorded before the on-chain distribution ID was stored
+func (db *Database) GetRevenueClaimsMissingOnchainID() ([]models.RevenueClaim, error) {
+	return gorm.G[models.RevenueClaim](db.db).Where("onchain_distribution_id IS NULL").Find(db.ctx)
+}
+
+func (db *Database) SetRevenueDistributionOnchainID(id uuid.UUID, distributionID int64) error {
+	_, err := gorm.G[models.RevenueDistribution](db.db).
+		Where("id = ?", id).
+		Update(db.ctx, "onchain_distribution_id", distributionID)
+	return err
+}
+
+func (db *Database) SetRevenueClaimOnchainID(id uuid.UUID, distributionID int64) error {
+	_, err := gorm.G[models.RevenueClaim](db.db).
+		Where("id = ?", id).
+		Update(db.ctx, "onchain_distribution_id", distributionID)
+	return err
+}
+
+// LinkRevenueClaims points unlinked claims at their parent distribution by on-chain distribution ID
+// returns the number of claims that were linked
+func (db *Database) LinkRevenueClaims() (int64, error) {
+	// rows written before the column was nullable may carry the zero UUID instead of NULL
+	if err := db.db.WithContext(db.ctx).Exec(`
+		UPDATE revenue_claims SET revenue_distribution_id = NULL
+		WHERE revenue_distribution_id = '00000000-0000-0000-0000-000000000000'
+	`).Error; err != nil {
+		return 0, err
+	}
+
+	result := db.db.WithContext(db.ctx).Exec(`
+		UPDATE revenue_claims c
+		SET revenue_distribution_id = d.id
+		FROM revenue_distributions d
+		WHERE c.revenue_distribution_id IS NULL
+		  AND c.onchain_distribution_id IS NOT NULL
+		  AND d.onchain_distribution_id = c.onchain_distribution_id
+	`)
+	return result.RowsAffected, result.Error
+}
+
 // --- Transaction Methods ---
 
 func (db *Database) CreateTransaction(tx models.Transaction) error {
@@ -303,6 +572,332 @@ func (db *Database) GetTransactionByHash(txHash string) (models.Transaction, err
 	return gorm.G[models.Transaction](db.db).Where("tx_hash = ?", txHash).First(db.ctx)
 }
 
+// ReplaceTransactionHash moves a transaction to the hash of its fee-bumped replacement
+// previous hashes are kept in details.replaced_hashes
+func (db *Database) ReplaceTransactionHash(oldHash, newHash string) error {
+	return db.db.WithContext(db.ctx).Exec(`
+		UPDATE transactions
+		SET tx_hash = ?,
+			details = jsonb_set(
+				COALESCE(details::jsonb, '{}'::jsonb),
+				'{replaced_hashes}',
+				COALESCE(details::jsonb -> 'replaced_hashes', '[]'::jsonb) || to_jsonb(?::text)
+			)::json,
+			updated_at = NOW()
+		WHERE tx_hash = ?`, newHash, oldHash, oldHash).Error
+}
+
+// GetCurrentTransaction finds the transaction recorded under txHash, or the one that replaced it
+func (db *Database) GetCurrentTransaction(txHash string) (models.Transaction, error) {
+	return gorm.G[models.Transaction](db.db).
+		Where("tx_hash = ? OR (details::jsonb -> 'replaced_hashes') @> to_jsonb(?::text)", txHash, txHash).
+		Order("created_at DESC").
+		First(db.ctx)
+}
+
+// GetPendingTransactions returns the oldest transactions still waiting for a receipt
+func (db *Database) GetPendingTransactions(limit int) ([]models.Transaction, error) {
+	return gorm.G[models.Transaction](db.db).
+		Where("status = ?", models.TxStatusPending).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(db.ctx)
+}
+
+// TransactionFilter - optional filters for ListTransactions, empty fields are ignored
+type TransactionFilter struct {
+	Wallet string // matches the signer or the wallet the action concerns
+	Type   models.TransactionType
+	Status models.TransactionStatus
+	Limit  int
+	Offset int
+}
+
+// ListTransactions returns audit log entries matching the filter, newest first
+func (db *Database) ListTransactions(filter TransactionFilter) (result []models.Transaction, err error) {
+	query := db.db.WithContext(db.ctx).Model(&models.Transaction{})
+	if filter.Wallet != "" {
+		query = query.Where("LOWER(wallet_address) = LOWER(?) OR LOWER(related_wallet) = LOWER(?)", filter.Wallet, filter.Wallet)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 100
+	}
+
+	err = query.Order("created_at DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&result).Error
+	return
+}
+
+// --- Reorg Methods ---
+
+// IndexedBlock - a block that indexed rows were written from
+type IndexedBlock struct {
+	BlockNumber uint64
+	BlockHash   string
+}
+
+// GetRevenueBlocksSince lists the blocks revenue rows were indexed from, starting at a block number
+func (db *Database) GetRevenueBlocksSince(block uint64) (result []IndexedBlock, err error) {
+	err = db.db.WithContext(db.ctx).Raw(`
+		SELECT block_number, block_hash FROM revenue_distributions WHERE block_number >= ? AND block_hash <> ''
+		UNION
+		SELECT block_number, block_hash FROM revenue_claims WHERE block_number >= ? AND block_hash <> ''
+	`, block, block).Scan(&result).Error
+	return
+}
+
+// DeleteRevenueRowsByBlockHash removes revenue rows indexed from a block that is no longer canonical
+func (db *Database) DeleteRevenueRowsByBlockHash(blockHash string) error {
+	return db.db.WithContext(db.ctx).Transaction(func(tx *gorm.DB) error {
+		// claims first, they reference distributions
+		if err := tx.Where("block_hash = ?", blockHash).Delete(&models.RevenueClaim{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("revenue_distribution_id IN (?)",
+			tx.Model(&models.RevenueDistribution{}).Select("id").Where("block_hash = ?", blockHash),
+		).Delete(&models.RevenueClaim{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("block_hash = ?", blockHash).Delete(&models.RevenueDistribution{}).Error
+	})
+}
+
+// GetPropertyBlocksSince lists the blocks properties were indexed from, starting at a block number
+func (db *Database) GetPropertyBlocksSince(block uint64) (result []IndexedBlock, err error) {
+	err = db.db.WithContext(db.ctx).
+		Model(&models.Property{}).
+		Distinct("block_number", "block_hash").
+		Where("block_number >= ? AND block_hash <> ''", block).
+		Scan(&result).Error
+	return
+}
+
+// DeletePropertiesByBlockHash removes properties indexed from a block that is no longer canonical
+// and puts the upload requests they approved back to pending; a property that already has offerings,
+// purchases or orders is kept and only loses its block tag, it is logged for an admin to resolve
+func (db *Database) DeletePropertiesByBlockHash(blockHash string) error {
+	return db.db.WithContext(db.ctx).Transaction(func(tx *gorm.DB) error {
+		var props []models.Property
+		if err := tx.Where("block_hash = ?", blockHash).Find(&props).Error; err != nil {
+			return err
+		}
+
+		for _, prop := range props {
+			var used int64
+			if err := tx.Raw(`SELECT
+				(SELECT COUNT(*) FROM offerings WHERE property_id = ?) +
+				(SELECT COUNT(*) FROM token_purchases WHERE property_id = ?) +
+				(SELECT COUNT(*) FROM orders WHERE property_id = ?)`,
+				prop.ID, prop.ID, prop.ID,
+			).Scan(&used).Error; err != nil {
+				return err
+			}
+			if used > 0 {
+				log.Printf("Warning: Property %s came from orphaned block %s but is already traded, kept for review", prop.ID, blockHash)
+				if err := tx.Model(&models.Property{}).Where("id = ?", prop.ID).
+					Updates(map[string]any{"block_number": 0, "block_hash": ""}).Error; err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := tx.Where("property_id = ?", prop.ID).Delete(&models.PropertyDocument{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&models.Property{}, "id = ?", prop.ID).Error; err != nil {
+				return err
+			}
+
+			// the request is approved again if the event is mined in the canonical chain
+			if err := tx.Model(&models.PropertyUploadRequest{}).
+				Where("LOWER(wallet_address) = LOWER(?) AND metadata_hash = ? AND status = ?", prop.OwnerWallet, prop.MetadataHash, models.ApprovalApproved).
+				Where("NOT EXISTS (SELECT 1 FROM properties WHERE LOWER(owner_wallet) = LOWER(?) AND metadata_hash = ?)", prop.OwnerWallet, prop.MetadataHash).
+				Update("status", models.ApprovalPending).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetApprovalBlocksSince lists the blocks user approvals were indexed from, starting at a block number
+func (db *Database) GetApprovalBlocksSince(block uint64) (result []IndexedBlock, err error) {
+	err = db.db.WithContext(db.ctx).
+		Model(&models.User{}).
+		Distinct("approval_block_number AS block_number", "approval_block_hash AS block_hash").
+		Where("approval_block_number >= ? AND approval_block_hash <> ''", block).
+		Scan(&result).Error
+	return
+}
+
+// ResetUserApprovalsByBlockHash undoes approval statuses that came from a reorged-out block
+// the event may lie outside the re-scanned range, so the status is re-derived instead of reset to pending:
+// approved if isApproved reads the user as approved on-chain, otherwise the status before the event,
+// and revoked when that was approved
+func (db *Database) ResetUserApprovalsByBlockHash(blockHash string, isApproved func(wallet string) (bool, error)) error {
+	users, err := gorm.G[models.User](db.db).Where("approval_block_hash = ?", blockHash).Find(db.ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		approved, err := isApproved(user.WalletAddress)
+		if err != nil {
+			return fmt.Errorf("failed to read approval of %s: %w", user.WalletAddress, err)
+		}
+		status := user.ApprovalPreviousStatus
+		switch {
+		case approved:
+			status = models.ApprovalApproved
+		case status == models.ApprovalApproved:
+			status = models.ApprovalRevoked
+		case status == "":
+			status = models.ApprovalPending
+		}
+
+		result := db.db.WithContext(db.ctx).
+			Model(&models.User{}).
+			Where("id = ? AND approval_block_hash = ?", user.ID, blockHash).
+			Updates(map[string]interface{}{
+				"approval_status":       status,
+				"approval_block_number": 0,
+				"approval_block_hash":   "",
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		log.Printf("Warning: Approval of %s rolled back from %s to %s", user.WalletAddress, user.ApprovalStatus, status)
+	}
+	return nil
+}
+
+// --- Indexer Cursor Methods ---
+
+func (db *Database) GetIndexerCursor(name string) (models.IndexerCursor, error) {
+	return gorm.G[models.IndexerCursor](db.db).Where("name = ?", name).First(db.ctx)
+}
+
+// SaveIndexerCursor inserts or updates the cursor row for a contract
+func (db *Database) SaveIndexerCursor(cursor models.IndexerCursor) error {
+	cursor.UpdatedAt = time.Now()
+	return db.db.WithContext(db.ctx).Save(&cursor).Error
+}
+
+// --- Job Methods ---
+
+// jobKeyHeld - condition under which a job's idempotency key blocks another job with the same key,
+// the predicate of idx_jobs_idempotency_key_unique
+const jobKeyHeld = `status <> 'failed' AND NOT (status = 'succeeded' AND active_key_only)`
+
+// EnqueueJob inserts a job unless one with the same idempotency key is queued, running or succeeded
+// (succeeded only counts for jobs without ActiveKeyOnly), returns the existing job and false in that case
+func (db *Database) EnqueueJob(job models.Job) (models.Job, bool, error) {
+	existing, err := gorm.G[models.Job](db.db).
+		Where("idempotency_key = ? AND "+jobKeyHeld, job.IdempotencyKey).
+		First(db.ctx)
+	if err == nil {
+		return existing, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return job, false, err
+	}
+
+	// the partial unique index settles a race between two requests with the same key
+	result := db.db.WithContext(db.ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&job)
+	if result.Error != nil {
+		return job, false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		existing, err := gorm.G[models.Job](db.db).
+			Where("idempotency_key = ? AND "+jobKeyHeld, job.IdempotencyKey).
+			First(db.ctx)
+		return existing, false, err
+	}
+	return job, true, nil
+}
+
+func (db *Database) GetJobByID(id string) (models.Job, error) {
+	return gorm.G[models.Job](db.db).Where("id = ?", id).First(db.ctx)
+}
+
+// ClaimJob locks the next runnable job for a worker and marks it running
+// queued jobs past RunAfter and running jobs whose lease expired (crashed worker) are eligible
+func (db *Database) ClaimJob(lease time.Duration) (job models.Job, found bool, err error) {
+	err = db.db.WithContext(db.ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		res := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND run_after <= ?) OR (status = ? AND locked_until < ?)",
+				models.JobQueued, now, models.JobRunning, now).
+			Order("run_after ASC").
+			Limit(1).
+			Find(&job)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+
+		lockedUntil := now.Add(lease)
+		job.Status = models.JobRunning
+		job.Attempts++
+		job.LockedUntil = &lockedUntil
+		found = true
+		return tx.Model(&models.Job{}).Where("id = ?", job.ID).Updates(map[string]any{
+			"status":       job.Status,
+			"attempts":     job.Attempts,
+			"locked_until": lockedUntil,
+			"updated_at":   now,
+		}).Error
+	})
+	return
+}
+
+// SetJobTxHash records the transaction a job sent, before the worker starts waiting for it
+func (db *Database) SetJobTxHash(id uuid.UUID, txHash string) error {
+	_, err := gorm.G[models.Job](db.db).Where("id = ?", id).Update(db.ctx, "tx_hash", txHash)
+	return err
+}
+
+// CompleteJob marks a job succeeded with its result
+func (db *Database) CompleteJob(id uuid.UUID, result string, propertyID *uuid.UUID) error {
+	now := time.Now()
+	return db.db.WithContext(db.ctx).Model(&models.Job{}).Where("id = ?", id).Updates(map[string]any{
+		"status":       models.JobSucceeded,
+		"result":       result,
+		"property_id":  propertyID,
+		"error":        "",
+		"locked_until": nil,
+		"completed_at": now,
+		"updated_at":   now,
+	}).Error
+}
+
+// RetryJob puts a job back in the queue after a transient error
+func (db *Database) RetryJob(id uuid.UUID, reason string, runAfter time.Time) error {
+	return db.db.WithContext(db.ctx).Model(&models.Job{}).Where("id = ?", id).Updates(map[string]any{
+		"status":       models.JobQueued,
+		"error":        reason,
+		"run_after":    runAfter,
+		"locked_until": nil,
+		"updated_at":   time.Now(),
+	}).Error
+}
+
+// FailJob marks a job permanently failed
+func (db *Database) FailJob(id uuid.UUID, reason string) error {
+	now := time.Now()
+	return db.db.WithContext(db.ctx).Model(&models.Job{}).Where("id = ?", id).Updates(map[string]any{
+		"status":       models.JobFailed,
+		"error":        reason,
+		"locked_until": nil,
+		"completed_at": now,
+		"updated_at":   now,
+	}).Error
+}
+
 // --- Auth Helpers ---
 
 func (db *Database) UserExists(email string) (bool, error) {
@@ -329,22 +924,41 @@ func (db *Database) GetUserByCredentials(creds auth.LoginCredentials) (user mode
 	return user, err
 }
 
-func (db *Database) UpdateUserInfo(userID string, name string, email string) error {
+// UpdateUserInfo updates name and email, empty values are left unchanged
+// a new email address is unverified, and links sent to the old one stop working; returns whether the email changed
+func (db *Database) UpdateUserInfo(userID string, name string, email string) (bool, error) {
 	uid, err := uuid.Parse(userID)
 	if err != nil {
-		return err
-	}
-
-	user := models.User{}
-	if name != "" {
-		user.Name = name
-	}
-	if email != "" {
-		user.Email = email
+		return false, err
 	}
 
-	_, err = gorm.G[models.User](db.db).Where("id = ?", uid).Updates(db.ctx, user)
-	return err
+	emailChanged := false
+	err = db.db.WithContext(db.ctx).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", uid).First(&user).Error; err != nil {
+			return err
+		}
+
+		fields := map[string]any{}
+		if name != "" {
+			fields["name"] = name
+		}
+		if email != "" && email != user.Email {
+			emailChanged = true
+			fields["email"] = email
+			fields["email_verified_at"] = nil
+			if err := tx.Model(&models.UserToken{}).
+				Where("user_id = ? AND used_at IS NULL", uid).
+				Update("used_at", time.Now()).Error; err != nil {
+				return err
+			}
+		}
+		if len(fields) == 0 {
+			return nil
+		}
+		return tx.Model(&models.User{}).Where("id = ?", uid).Updates(fields).Error
+	})
+	return emailChanged, err
 }
 
 func (db *Database) DeleteUser(userID string) error {
@@ -353,10 +967,26 @@ func (db *Database) DeleteUser(userID string) error {
 		return err
 	}
 
-	_, err = gorm.G[models.User](db.db.Unscoped()).Where("id = ?", uid).Delete(db.ctx)
-	return err
+	// the user and their roles go together, and not while a contract role is mirrored to their wallet
+	return db.db.WithContext(db.ctx).Transaction(func(tx *gorm.DB) error {
+		var onChain int64
+		if err := tx.Model(&models.RoleAssignment{}).Where("user_id = ? AND on_chain", uid).Count(&onChain).Error; err != nil {
+			return err
+		}
+		if onChain > 0 {
+			return ErrUserHasOnChainRoles
+		}
+
+		if err := tx.Unscoped().Where("id = ?", uid).Delete(&models.User{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", uid).Delete(&models.RoleAssignment{}).Error
+	})
 }
 
+// ErrUserHasOnChainRoles - the user holds a staff role mirrored on-chain, it has to be removed before the account
+var ErrUserHasOnChainRoles = errors.New("user holds staff roles mirrored on-chain")
+
 func (db *Database) UpdatePassword(userID string, newHash string) error {
 	uid, err := uuid.Parse(userID)
 	if err != nil {
@@ -367,6 +997,478 @@ func (db *Database) UpdatePassword(userID string, newHash string) error {
 	return err
 }
 
+// --- Email Token Methods ---
+
+// CreateUserToken stores an emailed token, earlier unused tokens of the same purpose stop working
+func (db *Database) CreateUserToken(token models.UserToken) error {
+	return db.db.WithContext(db.ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", token.UserID, token.Purpose).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(&token).Error
+	})
+}
+
+// ConsumeUserToken marks a token used and returns it, found is false if it is unknown, expired or already used
+func (db *Database) ConsumeUserToken(hash string, purpose models.UserTokenPurpose) (token models.UserToken, found bool, err error) {
+	err = db.db.WithContext(db.ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		res := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", hash, purpose, now).
+			Limit(1).
+			Find(&token)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		found = true
+		return tx.Model(&models.UserToken{}).Where("id = ?", token.ID).Update("used_at", now).Error
+	})
+	return
+}
+
+// MarkEmailVerified records that the user controls their email address
+func (db *Database) MarkEmailVerified(userID uuid.UUID) error {
+	_, err := gorm.G[models.User](db.db).
+		Where("id = ? AND email_verified_at IS NULL", userID).
+		Update(db.ctx, "email_verified_at", time.Now())
+	return err
+}
+
+// GetUserByEmail finds a user by email address
+func (db *Database) GetUserByEmail(email string) (models.User, error) {
+	return gorm.G[models.User](db.db).Where("email = ?", email).First(db.ctx)
+}
+
+// --- Two-Factor Methods ---
+
+// SetTOTPSecret stores a new authenticator secret, 2FA stays off until EnableTOTP confirms it
+func (db *Database) SetTOTPSecret(userID uuid.UUID, secret string) error {
+	return db.db.WithContext(db.ctx).Model(&models.User{}).Where("id = ?", userID).Updates(map[string]any{
+		"totp_secret":     secret,
+		"totp_enabled_at": nil,
+		"totp_last_step":  0,
+	}).Error
+}
+
+// EnableTOTP turns 2FA on and replaces the recovery codes, step is the time step of the confirming code
+func (db *Database) EnableTOTP(userID uuid.UUID, step int64, codeHashes []string) error {
+	return db.db.WithContext(db.ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]any{
+			"totp_enabled_at": time.Now(),
+			"totp_last_step":  step,
+		}).Error; err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+// DisableTOTP turns 2FA off and drops the secret and recovery codes
+func (db *Database) DisableTOTP(userID uuid.UUID) error {
+	return db.db.WithContext(db.ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]any{
+			"totp_secret":     "",
+			"totp_enabled_at": nil,
+			"totp_last_step":  0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// UseTOTPStep records an accepted code, returns false if that step (or a later one) was already used
+func (db *Database) UseTOTPStep(userID uuid.UUID, step int64) (bool, error) {
+	rows, err := gorm.G[models.User](db.db).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		Update(db.ctx, "totp_last_step", step)
+	return rows > 0, err
+}
+
+// ReplaceRecoveryCodes drops the user's recovery codes and stores new ones
+func (db *Database) ReplaceRecoveryCodes(userID uuid.UUID, codeHashes []string) error {
+	return db.db.WithContext(db.ctx).Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uuid.UUID, codeHashes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	codes := make([]models.RecoveryCode, len(codeHashes))
+	for i, hash := range codeHashes {
+		codes[i] = models.RecoveryCode{ID: uuid.New(), UserID: userID, CodeHash: hash}
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	return tx.Create(&codes).Error
+}
+
+// UseRecoveryCode consumes a recovery code, returns false if it is unknown or already used
+func (db *Database) UseRecoveryCode(userID uuid.UUID, codeHash string) (bool, error) {
+	rows, err := gorm.G[models.RecoveryCode](db.db).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update(db.ctx, "used_at", time.Now())
+	return rows > 0, err
+}
+
+// CountRecoveryCodes returns how many unused recovery codes the user has left
+func (db *Database) CountRecoveryCodes(userID uuid.UUID) (int64, error) {
+	return gorm.G[models.RecoveryCode](db.db).Where("user_id = ? AND used_at IS NULL", userID).Count(db.ctx, "*")
+}
+
+// --- Role Methods ---
+
+// GetUserRoles returns the staff roles assigned to a user, not including User.Role
+func (db *Database) GetUserRoles(userID uuid.UUID) ([]models.UserRole, error) {
+	var roles []models.UserRole
+	err := db.db.WithContext(db.ctx).Model(&models.RoleAssignment{}).
+		Where("user_id = ?", userID).Order("role").Pluck("role", &roles).Error
+	return roles, err
+}
+
+func (db *Database) GetRoleAssignments(userID uuid.UUID) ([]models.RoleAssignment, error) {
+	return gorm.G[models.RoleAssignment](db.db).Where("user_id = ?", userID).Order("created_at").Find(db.ctx)
+}
+
+// AssignRole records a role assignment, an existing assignment of the same role is updated
+func (db *Database) AssignRole(assignment models.RoleAssignment) error {
+	return db.db.WithContext(db.ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "role"}},
+		DoUpdates: clause.AssignmentColumns([]string{"granted_by", "on_chain", "tx_hash"}),
+	}).Create(&assignment).Error
+}
+
+// RemoveRole deletes a role assignment, returns false if the user didn't have it
+func (db *Database) RemoveRole(userID uuid.UUID, role models.UserRole) (bool, error) {
+	rows, err := gorm.G[models.RoleAssignment](db.db).Where("user_id = ? AND role = ?", userID, role).Delete(db.ctx)
+	return rows > 0, err
+}
+
+// --- Session Methods ---
+
+func (db *Database) CreateRefreshToken(token models.RefreshToken) error {
+	return gorm.G[models.RefreshToken](db.db).Create(db.ctx, &token)
+}
+
+func (db *Database) GetRefreshTokenByHash(hash string) (models.RefreshToken, error) {
+	return gorm.G[models.RefreshToken](db.db).Where("token_hash = ?", hash).First(db.ctx)
+}
+
+// RotateRefreshToken marks old as used and stores its replacement in one transaction
+// returns false if old was already used or revoked, i.e. a concurrent or replayed refresh
+func (db *Database) RotateRefreshToken(old models.RefreshToken, next models.RefreshToken) (bool, error) {
+	rotated := false
+	err := db.db.WithContext(db.ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", old.ID).
+			Updates(map[string]any{"used_at": time.Now(), "replaced_by": next.ID})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		if err := tx.Create(&next).Error; err != nil {
+			return err
+		}
+		rotated = true
+		return nil
+	})
+	return rotated, err
+}
+
+// RevokeRefreshFamily revokes every token of a session, access tokens carrying its sid stop working too
+func (db *Database) RevokeRefreshFamily(familyID uuid.UUID) error {
+	_, err := gorm.G[models.RefreshToken](db.db).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update(db.ctx, "revoked_at", time.Now())
+	return err
+}
+
+// RevokeUserSessions revokes every session of a user except keep ("log out all devices" passes uuid.Nil)
+func (db *Database) RevokeUserSessions(userID uuid.UUID, keep uuid.UUID) error {
+	_, err := gorm.G[models.RefreshToken](db.db).
+		Where("user_id = ? AND family_id <> ? AND revoked_at IS NULL", userID, keep).
+		Update(db.ctx, "revoked_at", time.Now())
+	return err
+}
+
+// RevokeAccessToken blocks one access token until it expires, expired entries are pruned on the way
+func (db *Database) RevokeAccessToken(jti string, userID uuid.UUID, expiresAt time.Time) error {
+	if _, err := gorm.G[models.RevokedToken](db.db).Where("expires_at < ?", time.Now()).Delete(db.ctx); err != nil {
+		log.Printf("Warning: Failed to prune revoked tokens: %v", err)
+	}
+	return db.db.WithContext(db.ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RevokedToken{
+		JTI:       jti,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	}).Error
+}
+
+// IsTokenRevoked implements auth.RevocationStore
+func (db *Database) IsTokenRevoked(jti string, sessionID uuid.UUID) (bool, error) {
+	var revoked bool
+	err := db.db.WithContext(db.ctx).Raw(`SELECT
+		EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = ?) OR
+		EXISTS (SELECT 1 FROM refresh_tokens WHERE family_id = ? AND revoked_at IS NOT NULL)`,
+		jti, sessionID).Scan(&revoked).Error
+	return revoked, err
+}
+
+// CreateAuthNonce stores a SIWE nonce, expired nonces are pruned on the way
+func (db *Database) CreateAuthNonce(nonce string, expiresAt time.Time) error {
+	if _, err := gorm.G[models.AuthNonce](db.db).Where("expires_at < ?", time.Now()).Delete(db.ctx); err != nil {
+		log.Printf("Warning: Failed to prune auth nonces: %v", err)
+	}
+	return gorm.G[models.AuthNonce](db.db).Create(db.ctx, &models.AuthNonce{Nonce: nonce, ExpiresAt: expiresAt})
+}
+
+// ConsumeAuthNonce marks a nonce used, returns false if it is unknown, expired or already used
+func (db *Database) ConsumeAuthNonce(nonce string) (bool, error) {
+	rows, err := gorm.G[models.AuthNonce](db.db).
+		Where("nonce = ? AND used_at IS NULL AND expires_at > ?", nonce, time.Now()).
+		Update(db.ctx, "used_at", time.Now())
+	return rows > 0, err
+}
+
+// GetUserByWalletAddress finds a user by wallet, ignoring checksum casing
+func (db *Database) GetUserByWalletAddress(wallet string) (models.User, error) {
+	return gorm.G[models.User](db.db).Where("LOWER(wallet_address) = LOWER(?)", wallet).First(db.ctx)
+}
+
+// --- Rate Limit Methods ---
+// *Database implements auth.AttemptStore, so limits hold across every backend instance
+
+// Hit counts one request against key, a window that has ended starts over at 1
+// counters of finished windows are pruned on the way
+func (db *Database) Hit(key string, window time.Duration, now time.Time) (int, time.Time, error) {
+	if _, err := gorm.G[models.RateLimitCounter](db.db).Where("window_start < ?", now.Add(-2*window)).Delete(db.ctx); err != nil {
+		log.Printf("Warning: Failed to prune rate limit counters: %v", err)
+	}
+
+	var counter models.RateLimitCounter
+	err := db.db.WithContext(db.ctx).Raw(`
+		INSERT INTO rate_limit_counters (key, window_start, count) VALUES (?, ?, 1)
+		ON CONFLICT (key) DO UPDATE SET
+			window_start = CASE WHEN rate_limit_counters.window_start <= ? THEN EXCLUDED.window_start ELSE rate_limit_counters.window_start END,
+			count = CASE WHEN rate_limit_counters.window_start <= ? THEN 1 ELSE rate_limit_counters.count + 1 END
+		RETURNING key, window_start, count
+	`, key, now.Truncate(window), now.Add(-window), now.Add(-window)).Scan(&counter).Error
+	return counter.Count, counter.WindowStart.Add(window), err
+}
+
+// AddFailure counts one failed login against key, the count restarts after resetAfter without failures
+func (db *Database) AddFailure(key string, now time.Time, resetAfter time.Duration) (models.AuthLockout, error) {
+	var lockout models.AuthLockout
+	err := db.db.WithContext(db.ctx).Raw(`
+		INSERT INTO auth_lockouts (key, failures, last_failure_at, updated_at) VALUES (?, 1, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN auth_lockouts.last_failure_at < ? THEN 1 ELSE auth_lockouts.failures + 1 END,
+			last_failure_at = EXCLUDED.last_failure_at,
+			updated_at = EXCLUDED.updated_at
+		RETURNING *
+	`, key, now, now, now.Add(-resetAfter)).Scan(&lockout).Error
+	return lockout, err
+}
+
+// LockUntil locks key until the given time
+func (db *Database) LockUntil(key string, until time.Time) error {
+	_, err := gorm.G[models.AuthLockout](db.db).Where("key = ?", key).
+		Updates(db.ctx, models.AuthLockout{LockedUntil: &until, UpdatedAt: time.Now()})
+	return err
+}
+
+// GetLockout returns the failures recorded against key, found is false if there are none
+func (db *Database) GetLockout(key string) (models.AuthLockout, bool, error) {
+	lockout, err := gorm.G[models.AuthLockout](db.db).Where("key = ?", key).First(db.ctx)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return lockout, false, nil
+	}
+	return lockout, err == nil, err
+}
+
+// ListLockouts returns keys with failures since the given time or a lock still running, most recent first
+// older rows are pruned on the way
+func (db *Database) ListLockouts(since time.Time) ([]models.AuthLockout, error) {
+	if _, err := gorm.G[models.AuthLockout](db.db).
+		Where("last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)", since, time.Now()).
+		Delete(db.ctx); err != nil {
+		log.Printf("Warning: Failed to prune auth lockouts: %v", err)
+	}
+	return gorm.G[models.AuthLockout](db.db).Order("last_failure_at DESC").Find(db.ctx)
+}
+
+// ClearLockout forgets the failures and lock of key
+func (db *Database) ClearLockout(key string) error {
+	_, err := gorm.G[models.AuthLockout](db.db).Where("key = ?", key).Delete(db.ctx)
+	return err
+}
+
+// --- Service Account Methods ---
+
+func (db *Database) CreateServiceAccount(account *models.ServiceAccount) error {
+	return gorm.G[models.ServiceAccount](db.db).Create(db.ctx, account)
+}
+
+func (db *Database) ServiceAccountExists(name string) (bool, error) {
+	count, err := gorm.G[models.ServiceAccount](db.db).Where("name = ?", name).Count(db.ctx, "*")
+	return count > 0, err
+}
+
+func (db *Database) GetServiceAccounts() ([]models.ServiceAccount, error) {
+	return gorm.G[models.ServiceAccount](db.db).Order("created_at DESC").Find(db.ctx)
+}
+
+func (db *Database) GetServiceAccount(id uuid.UUID) (models.ServiceAccount, error) {
+	return gorm.G[models.ServiceAccount](db.db).Where("id = ?", id).First(db.ctx)
+}
+
+// DisableServiceAccount disables an account and revokes all of its keys
+func (db *Database) DisableServiceAccount(id uuid.UUID) error {
+	now := time.Now()
+	return db.db.WithContext(db.ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.ServiceAccount{}).Where("id = ? AND disabled_at IS NULL", id).
+			Update("disabled_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.APIKey{}).Where("service_account_id = ? AND revoked_at IS NULL", id).
+			Update("revoked_at", now).Error
+	})
+}
+
+func (db *Database) CreateAPIKey(key *models.APIKey) error {
+	return gorm.G[models.APIKey](db.db).Create(db.ctx, key)
+}
+
+// GetAPIKeys lists the keys of a service account, revoked and expired ones included
+func (db *Database) GetAPIKeys(accountID uuid.UUID) ([]models.APIKey, error) {
+	return gorm.G[models.APIKey](db.db).Where("service_account_id = ?", accountID).Order("created_at DESC").Find(db.ctx)
+}
+
+// RevokeAPIKey revokes one key of a service account, returns false if there is no such unrevoked key
+func (db *Database) RevokeAPIKey(accountID, keyID uuid.UUID) (bool, error) {
+	rows, err := gorm.G[models.APIKey](db.db).
+		Where("id = ? AND service_account_id = ? AND revoked_at IS NULL", keyID, accountID).
+		Update(db.ctx, "revoked_at", time.Now())
+	return rows > 0, err
+}
+
+// GetActiveAPIKey implements auth.APIKeyStore
+func (db *Database) GetActiveAPIKey(keyHash string) (models.APIKey, bool, error) {
+	key, err := gorm.G[models.APIKey](db.db).
+		Where("key_hash = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", keyHash, time.Now()).
+		Where("service_account_id IN (SELECT id FROM service_accounts WHERE disabled_at IS NULL)").
+		First(db.ctx)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return key, false, nil
+	}
+	return key, err == nil, err
+}
+
+// TouchAPIKey implements auth.APIKeyStore, last use is recorded at most once a minute to spare writes
+func (db *Database) TouchAPIKey(id uuid.UUID, ip string) error {
+	now := time.Now()
+	_, err := gorm.G[models.APIKey](db.db).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, now.Add(-time.Minute)).
+		Updates(db.ctx, models.APIKey{LastUsedAt: &now, LastUsedIP: ip})
+	return err
+}
+
+// --- KYC Methods ---
+
+// CreateKYCSubmission stores a submission with its documents and the first history entry
+func (db *Database) CreateKYCSubmission(submission *models.KYCSubmission) error {
+	return db.db.WithContext(db.ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(submission).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.KYCStatusChange{
+			ID:           uuid.New(),
+			SubmissionID: submission.ID,
+			ToStatus:     submission.Status,
+			ChangedBy:    submission.UserID,
+		}).Error
+	})
+}
+
+// loadKYCSubmission - submission matching the query with documents, checks and history in order
+func (db *Database) loadKYCSubmission(query any, args ...any) (submission models.KYCSubmission, err error) {
+	err = db.db.WithContext(db.ctx).
+		Preload("Documents").
+		Preload("Checks", func(tx *gorm.DB) *gorm.DB { return tx.Order("created_at") }).
+		Preload("History", func(tx *gorm.DB) *gorm.DB { return tx.Order("created_at") }).
+		Where(query, args...).Order("created_at DESC").First(&submission).Error
+	return
+}
+
+func (db *Database) GetKYCSubmission(id uuid.UUID) (models.KYCSubmission, error) {
+	return db.loadKYCSubmission("id = ?", id)
+}
+
+// GetLatestKYCSubmission returns the user's most recent submission
+func (db *Database) GetLatestKYCSubmission(userID uuid.UUID) (models.KYCSubmission, error) {
+	return db.loadKYCSubmission("user_id = ?", userID)
+}
+
+// GetKYCSubmissions lists submissions, oldest first so the review queue is worked in order; status "" lists all
+func (db *Database) GetKYCSubmissions(status models.KYCStatus) ([]models.KYCSubmission, error) {
+	query := gorm.G[models.KYCSubmission](db.db).Order("created_at")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	return query.Find(db.ctx)
+}
+
+// IsKYCApproved reports whether the latest submission for a wallet completed review with approval
+func (db *Database) IsKYCApproved(wallet string) (bool, error) {
+	submission, err := gorm.G[models.KYCSubmission](db.db).
+		Where("LOWER(wallet_address) = LOWER(?)", wallet).Order("created_at DESC").First(db.ctx)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return err == nil && submission.Status == models.KYCApproved, err
+}
+
+// UpdateKYCStatus moves a submission from one status to another and records the change
+// fields are set along with the status; returns false if the submission was no longer in status from
+func (db *Database) UpdateKYCStatus(id uuid.UUID, from, to models.KYCStatus, changedBy uuid.UUID, note string, fields map[string]any) (bool, error) {
+	updated := false
+	err := db.db.WithContext(db.ctx).Transaction(func(tx *gorm.DB) error {
+		if fields == nil {
+			fields = map[string]any{}
+		}
+		fields["status"] = to
+		fields["updated_at"] = time.Now()
+
+		result := tx.Model(&models.KYCSubmission{}).Where("id = ? AND status = ?", id, from).Updates(fields)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		updated = true
+		return tx.Create(&models.KYCStatusChange{
+			ID:           uuid.New(),
+			SubmissionID: id,
+			FromStatus:   from,
+			ToStatus:     to,
+			ChangedBy:    changedBy,
+			Note:         note,
+		}).Error
+	})
+	return updated, err
+}
+
+func (db *Database) AddKYCCheck(check models.KYCCheck) error {
+	return gorm.G[models.KYCCheck](db.db).Create(db.ctx, &check)
+}
+
+// SetKYCJob links a completed submission to the job that carries its decision on-chain
+func (db *Database) SetKYCJob(id, jobID uuid.UUID) error {
+	_, err := gorm.G[models.KYCSubmission](db.db).Where("id = ?", id).Update(db.ctx, "job_id", jobID)
+	return err
+}
+
 // --- Property Upload Request Methods ---
 
 func (db *Database) CreatePropertyUploadRequest(request models.PropertyUploadRequest) error {
@@ -399,6 +1501,14 @@ func (db *Database) GetPropertyUploadRequestByID(id string) (result models.Prope
 	return
 }
 
+// GetPendingUploadRequestByOwnerAndHash finds the pending request an on-chain property was created from
+func (db *Database) GetPendingUploadRequestByOwnerAndHash(walletAddress, metadataHash string) (models.PropertyUploadRequest, error) {
+	return gorm.G[models.PropertyUploadRequest](db.db).
+		Where("LOWER(wallet_address) = LOWER(?) AND metadata_hash = ? AND status = ?", walletAddress, metadataHash, models.ApprovalPending).
+		Order("created_at ASC").
+		First(db.ctx)
+}
+
 func (db *Database) UpdatePropertyUploadRequestStatus(id string, status models.ApprovalStatus, reason string) error {
 	uid, err := uuid.Parse(id)
 	if err != nil {
@@ -422,7 +1532,7 @@ func (db *Database) UpdatePropertyUploadRequestStatus(id string, status models.A
 // --- Token Purchase Methods ---
 
 func (db *Database) CreateTokenPurchase(purchase models.TokenPurchase) error {
-	return gorm.G[models.TokenPurchase](db.db).Create(db.ctx, &purchase)
+	return txHashConflict(gorm.G[models.TokenPurchase](db.db).Create(db.ctx, &purchase))
 }
 
 func (db *Database) GetTokenPurchasesByProperty(propertyID string) (result []models.TokenPurchase, err error) {
@@ -449,10 +1559,11 @@ func (db *Database) GetTokenStatsByProperty(propertyID string) (totalSold float6
 
 	// Use raw SQL to sum the amount column (stored as decimal/string)
 	// PostgreSQL requires casting string to numeric for SUMM
+	// declined, cancelled, expired and refunded purchases didn't sell anything
 	err = db.db.WithContext(db.ctx).
 		Model(&models.TokenPurchase{}).
 		Select("COALESCE(SUM(amount::numeric), 0) as total_sold").
-		Where("property_id = ?", uid).
+		Where("property_id = ? AND status IN ?", uid, livePurchaseStatuses).
 		Scan(&result).Error
 
 	if err != nil {
@@ -470,27 +1581,422 @@ func (db *Database) GetTokenPurchasesByBuyer(buyerWallet string) (result []model
 	return
 }
 
-// GetPendingTokenPurchasesByProperty gets all pending token purchases (where TokenTxHash = "pending") for a property
+// GetPendingTokenPurchasesByProperty gets the purchases of a property waiting for the owner: paid ones to approve or decline, approved ones to transfer
 func (db *Database) GetPendingTokenPurchasesByProperty(propertyID string) (result []models.TokenPurchase, err error) {
 	uid, err := uuid.Parse(propertyID)
 	if err != nil {
 		return nil, err
 	}
 	result, err = gorm.G[models.TokenPurchase](db.db).
-		Where("property_id = ? AND token_tx_hash = ?", uid, "pending").
+		Where("property_id = ? AND status IN ?", uid, []models.PurchaseStatus{models.PurchasePaid, models.PurchaseApproved}).
 		Order("created_at DESC").
 		Find(db.ctx)
 	return
 }
 
-// UpdateTokenPurchaseTxHash updates the token transfer transaction hash for a purchase
-func (db *Database) UpdateTokenPurchaseTxHash(purchaseID string, tokenTxHash string) error {
+func (db *Database) GetTokenPurchaseByID(purchaseID string) (result models.TokenPurchase, err error) {
 	uid, err := uuid.Parse(purchaseID)
 	if err != nil {
-		return err
+		return
+	}
+	result, err = gorm.G[models.TokenPurchase](db.db).Where("id = ?", uid).First(db.ctx)
+	return
+}
+
+// ErrTxHashUsed - the transaction hash already settles another purchase or trade
+var ErrTxHashUsed = errors.New("transaction already used for a purchase or trade")
+
+// txHashConflict - ErrTxHashUsed if err violates one of the unique transaction hash indexes (23505 unique_violation), err otherwise
+func txHashConflict(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" && strings.HasSuffix(pgErr.ConstraintName, "_tx_hash_unique") {
+		return ErrTxHashUsed
 	}
-	_, err = gorm.G[models.TokenPurchase](db.db).
-		Where("id = ?", uid).
-		Update(db.ctx, "token_tx_hash", tokenTxHash)
 	return err
 }
+
+// TokenPurchaseTxUsed reports whether a transaction hash already settles a purchase, as payment, token transfer or refund
+func (db *Database) TokenPurchaseTxUsed(txHash string) (bool, error) {
+	count, err := gorm.G[models.TokenPurchase](db.db).
+		Where("LOWER(payment_tx_hash) = LOWER(?) OR LOWER(token_tx_hash) = LOWER(?) OR LOWER(refund_tx_hash) = LOWER(?)", txHash, txHash, txHash).
+		Count(db.ctx, "*")
+	return count > 0, err
+}
+
+// livePurchaseStatuses - purchases that hold their tokens, the others sold nothing
+var livePurchaseStatuses = []models.PurchaseStatus{
+	models.PurchaseAwaitingPayment, models.PurchasePaid, models.PurchaseApproved, models.PurchaseTransferred,
+}
+
+// purchaseStatusTimestamps - column stamped when a purchase enters a status
+var purchaseStatusTimestamps = map[models.PurchaseStatus]string{
+	models.PurchasePaid:        "payment_verified_at",
+	models.PurchaseApproved:    "approved_at",
+	models.PurchaseTransferred: "transfer_verified_at",
+	models.PurchaseDeclined:    "declined_at",
+	models.PurchaseCancelled:   "cancelled_at",
+	models.PurchaseExpired:     "expired_at",
+	models.PurchaseRefunded:    "refunded_at",
+}
+
+// TransitionTokenPurchase moves a purchase from one status to another, stamping the status's timestamp
+// fields are set along with the status; returns false if the purchase was no longer in status from
+func (db *Database) TransitionTokenPurchase(id uuid.UUID, from, to models.PurchaseStatus, fields map[string]any) (bool, error) {
+	if !from.CanTransitionTo(to) {
+		return false, fmt.Errorf("purchase can't move from %s to %s", from, to)
+	}
+	if fields == nil {
+		fields = map[string]any{}
+	}
+	fields["status"] = to
+	fields[purchaseStatusTimestamps[to]] = time.Now()
+
+	result := db.db.WithContext(db.ctx).
+		Model(&models.TokenPurchase{}).
+		Where("id = ? AND status = ?", id, from).
+		Updates(fields)
+	return result.RowsAffected > 0, txHashConflict(result.Error)
+}
+
+// ExpireTokenPurchases expires purchases still unapproved past their deadline, returns how many
+func (db *Database) ExpireTokenPurchases(now time.Time) (int64, error) {
+	result := db.db.WithContext(db.ctx).
+		Model(&models.TokenPurchase{}).
+		Where("status IN ? AND expires_at < ?", []models.PurchaseStatus{models.PurchaseAwaitingPayment, models.PurchasePaid}, now).
+		Updates(map[string]any{"status": models.PurchaseExpired, "expired_at": now})
+	return result.RowsAffected, result.Error
+}
+
+// --- Offering Methods ---
+
+// OfferingTotals - tokens purchases hold in an offering, amounts are decimal strings in token units
+type OfferingTotals struct {
+	Subscribed string // held by awaiting_payment, paid, approved and transferred purchases
+	Sold       string // transferred to buyers
+	Wallet     string // subscribed by the wallet the totals were asked for
+	Investors  int64  // distinct buyer wallets
+}
+
+func (db *Database) CreateOffering(offering *models.Offering) error {
+	return gorm.G[models.Offering](db.db).Create(db.ctx, offering)
+}
+
+func (db *Database) GetOffering(id uuid.UUID) (models.Offering, error) {
+	return gorm.G[models.Offering](db.db).Where("id = ?", id).First(db.ctx)
+}
+
+// GetOfferingsByProperty lists a property's offerings, latest first
+func (db *Database) GetOfferingsByProperty(propertyID uuid.UUID) ([]models.Offering, error) {
+	return gorm.G[models.Offering](db.db).
+		Where("property_id = ?", propertyID).
+		Order("starts_at DESC").
+		Find(db.ctx)
+}
+
+// GetOpenOffering returns the offering of a property that accepts purchases at now, found is false if there is none
+func (db *Database) GetOpenOffering(propertyID uuid.UUID, now time.Time) (offering models.Offering, found bool, err error) {
+	res := db.db.WithContext(db.ctx).
+		Where("property_id = ? AND closed_at IS NULL AND starts_at <= ? AND ends_at > ?", propertyID, now, now).
+		Limit(1).
+		Find(&offering)
+	return offering, res.RowsAffected > 0, res.Error
+}
+
+// OfferingOverlaps reports whether an offering of the property that isn't closed runs at any time between startsAt and endsAt
+func (db *Database) OfferingOverlaps(propertyID uuid.UUID, startsAt, endsAt time.Time) (bool, error) {
+	count, err := gorm.G[models.Offering](db.db).
+		Where("property_id = ? AND closed_at IS NULL AND starts_at < ? AND ends_at > ?", propertyID, endsAt, startsAt).
+		Count(db.ctx, "*")
+	return count > 0, err
+}
+
+// CloseOffering ends an offering early, returns false if it was already closed or over
+func (db *Database) CloseOffering(id uuid.UUID, now time.Time) (bool, error) {
+	rows, err := gorm.G[models.Offering](db.db).
+		Where("id = ? AND closed_at IS NULL AND ends_at > ?", id, now).
+		Update(db.ctx, "closed_at", now)
+	return rows > 0, err
+}
+
+// GetOfferingTotals sums the purchases of an offering, Wallet is the part subscribed by wallet
+func (db *Database) GetOfferingTotals(offeringID uuid.UUID, wallet string) (OfferingTotals, error) {
+	return offeringTotals(db.db.WithContext(db.ctx), offeringID, wallet)
+}
+
+// ReserveOfferingPurchase records a purchase of purchase.OfferingID if check accepts the offering's current totals
+// The offering row stays locked until the purchase is written, so concurrent buyers are checked one after another
+// and can't reserve more than the offering allows; tokens return to the offering when a purchase stops being live
+func (db *Database) ReserveOfferingPurchase(purchase models.TokenPurchase, check func(models.Offering, OfferingTotals) error) error {
+	if purchase.OfferingID == nil {
+		return errors.New("purchase has no offering")
+	}
+	return db.db.WithContext(db.ctx).Transaction(func(tx *gorm.DB) error {
+		var offering models.Offering
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", *purchase.OfferingID).
+			First(&offering).Error; err != nil {
+			return err
+		}
+
+		totals, err := offeringTotals(tx, offering.ID, purchase.BuyerWallet)
+		if err != nil {
+			return err
+		}
+		if err := check(offering, totals); err != nil {
+			return err
+		}
+		return txHashConflict(tx.Create(&purchase).Error)
+	})
+}
+
+func offeringTotals(tx *gorm.DB, offeringID uuid.UUID, wallet string) (totals OfferingTotals, err error) {
+	err = tx.Raw(`
+		SELECT
+			COALESCE(SUM(amount), 0)::text AS subscribed,
+			COALESCE(SUM(amount) FILTER (WHERE status = ?), 0)::text AS sold,
+			COALESCE(SUM(amount) FILTER (WHERE LOWER(buyer_wallet) = LOWER(?)), 0)::text AS wallet,
+			COUNT(DISTINCT LOWER(buyer_wallet)) AS investors
+		FROM token_purchases
+		WHERE offering_id = ? AND status IN ?`,
+		models.PurchaseTransferred, wallet, offeringID, livePurchaseStatuses,
+	).Scan(&totals).Error
+	return
+}
+
+// --- Order Book Methods ---
+
+// PriceLevel - open orders on one side of a property's book at one price
+type PriceLevel struct {
+	Price  string
+	Amount string // tokens still open at this price
+	Orders int64
+}
+
+// TradeLeg - one half of a trade's settlement
+type TradeLeg string
+
+const (
+	TradePayment  TradeLeg = "payment"  // the buyer pays the seller
+	TradeTransfer TradeLeg = "transfer" // the seller delivers the tokens
+)
+
+// PlaceOrder records an order and matches it against the other side of the book, see matchOrder
+// canFill is asked about each resting order before it is matched, a non-empty reason cancels that order instead;
+// the property row stays locked meanwhile, so orders of one property are matched one at a time
+// Trades that aren't settled by settleBy fail, see ExpireTrades
+func (db *Database) PlaceOrder(order *models.Order, settleBy time.Time, canFill func(models.Order) (string, error)) (trades []models.Trade, err error) {
+	err = db.db.WithContext(db.ctx).Transaction(func(tx *gorm.DB) error {
+		trades = nil
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", order.PropertyID).
+			First(&models.Property{}).Error; err != nil {
+			return err
+		}
+
+		order.Status, order.Filled = models.OrderOpen, "0"
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+
+		var resting []models.Order
+		if err := matchableOrders(tx.Clauses(clause.Locking{Strength: "UPDATE"}), *order).Find(&resting).Error; err != nil {
+			return err
+		}
+		fills, rejected, err := matchOrder(order, resting, canFill)
+		if err != nil {
+			return err
+		}
+
+		for _, maker := range rejected {
+			if _, err := cancelOrder(tx, maker.ID, maker.CancelReason); err != nil {
+				return err
+			}
+		}
+		for _, fill := range fills {
+			fill.Trade.ExpiresAt = &settleBy
+			if err := tx.Create(&fill.Trade).Error; err != nil {
+				return err
+			}
+			if err := saveFilled(tx, fill.Maker); err != nil {
+				return err
+			}
+			trades = append(trades, fill.Trade)
+		}
+		return saveFilled(tx, *order)
+	})
+	return
+}
+
+// GetMatchableOrders lists the open orders order would match, in matching order
+// without a lock, PlaceOrder looks them up again; callers use it to check the makers ahead of matching
+func (db *Database) GetMatchableOrders(order models.Order) (result []models.Order, err error) {
+	err = matchableOrders(db.db.WithContext(db.ctx), order).Find(&result).Error
+	return
+}
+
+// matchableOrders - open orders of other wallets on the other side of the book that cross order's price,
+// best price first and the oldest first at one price
+func matchableOrders(tx *gorm.DB, order models.Order) *gorm.DB {
+	query := tx.Where("property_id = ? AND status = ? AND LOWER(wallet) <> LOWER(?)", order.PropertyID, models.OrderOpen, order.Wallet)
+	if order.Side == models.OrderBid {
+		query = query.Where("side = ? AND price <= ?", models.OrderAsk, order.Price).Order("price ASC")
+	} else {
+		query = query.Where("side = ? AND price >= ?", models.OrderBid, order.Price).Order("price DESC")
+	}
+	return query.Order("created_at ASC, id ASC")
+}
+
+// saveFilled - store how much of an order is matched and its status
+func saveFilled(tx *gorm.DB, order models.Order) error {
+	return tx.Model(&models.Order{}).Where("id = ?", order.ID).Updates(map[string]any{
+		"filled": order.Filled,
+		"status": order.Status,
+	}).Error
+}
+
+func cancelOrder(tx *gorm.DB, id uuid.UUID, reason string) (bool, error) {
+	result := tx.Model(&models.Order{}).
+		Where("id = ? AND status = ?", id, models.OrderOpen).
+		Updates(map[string]any{"status": models.OrderCancelled, "cancel_reason": reason, "cancelled_at": time.Now()})
+	return result.RowsAffected > 0, result.Error
+}
+
+// CancelOrder takes an open order off the book, the part already matched stays traded; false if it wasn't open
+func (db *Database) CancelOrder(id uuid.UUID, reason string) (bool, error) {
+	return cancelOrder(db.db.WithContext(db.ctx), id, reason)
+}
+
+func (db *Database) GetOrder(id uuid.UUID) (models.Order, error) {
+	return gorm.G[models.Order](db.db).Where("id = ?", id).First(db.ctx)
+}
+
+// GetOrdersByUser lists a user's orders, newest first; an empty status matches all
+func (db *Database) GetOrdersByUser(userID uuid.UUID, status models.OrderStatus) ([]models.Order, error) {
+	query := gorm.G[models.Order](db.db).Where("user_id = ?", userID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	return query.Order("created_at DESC").Find(db.ctx)
+}
+
+// GetOrderBookDepth sums the open orders of one side of a property's book per price, best price first
+func (db *Database) GetOrderBookDepth(propertyID uuid.UUID, side models.OrderSide, levels int) (result []PriceLevel, err error) {
+	// o.price is the numeric column, a bare price would sort by the text alias
+	order := "o.price DESC"
+	if side == models.OrderAsk {
+		order = "o.price ASC"
+	}
+	err = db.db.WithContext(db.ctx).Raw(`
+		SELECT o.price::text AS price, SUM(o.amount - o.filled)::text AS amount, COUNT(*) AS orders
+		FROM orders o
+		WHERE o.property_id = ? AND o.side = ? AND o.status = ?
+		GROUP BY o.price
+		ORDER BY `+order+`
+		LIMIT ?`, propertyID, side, models.OrderOpen, levels).Scan(&result).Error
+	return
+}
+
+// GetCommittedAskAmount sums the tokens a wallet already offers or owes on a property:
+// what is left of its open asks and its pending trades as seller that aren't delivered yet
+func (db *Database) GetCommittedAskAmount(propertyID uuid.UUID, wallet string) (committed string, err error) {
+	err = db.db.WithContext(db.ctx).Raw(`
+		SELECT (
+			COALESCE((SELECT SUM(amount - filled) FROM orders
+				WHERE property_id = ? AND LOWER(wallet) = LOWER(?) AND side = ? AND status = ?), 0)
+			+ COALESCE((SELECT SUM(amount) FROM trades
+				WHERE property_id = ? AND LOWER(seller_wallet) = LOWER(?) AND status = ? AND transfer_verified_at IS NULL), 0)
+		)::text`,
+		propertyID, wallet, models.OrderAsk, models.OrderOpen,
+		propertyID, wallet, models.TradePending,
+	).Scan(&committed).Error
+	return
+}
+
+func (db *Database) GetTrade(id uuid.UUID) (models.Trade, error) {
+	return gorm.G[models.Trade](db.db).Where("id = ?", id).First(db.ctx)
+}
+
+// GetTradesByProperty lists a property's trades, newest first
+func (db *Database) GetTradesByProperty(propertyID uuid.UUID, limit int) ([]models.Trade, error) {
+	return gorm.G[models.Trade](db.db).
+		Where("property_id = ?", propertyID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(db.ctx)
+}
+
+// GetTradesByWallet lists the trades a wallet bought or sold in, newest first
+func (db *Database) GetTradesByWallet(wallet string) ([]models.Trade, error) {
+	return gorm.G[models.Trade](db.db).
+		Where("LOWER(buyer_wallet) = LOWER(?) OR LOWER(seller_wallet) = LOWER(?)", wallet, wallet).
+		Order("created_at DESC").
+		Find(db.ctx)
+}
+
+// TradeTxUsed reports whether a transaction hash already settles a trade
+func (db *Database) TradeTxUsed(txHash string) (bool, error) {
+	count, err := gorm.G[models.Trade](db.db).
+		Where("LOWER(payment_tx_hash) = LOWER(?) OR LOWER(token_tx_hash) = LOWER(?)", txHash, txHash).
+		Count(db.ctx, "*")
+	return count > 0, err
+}
+
+// RecordTradeLeg stores the verified transaction of one leg of a pending trade, the trade is settled once both are in
+// returns false if that leg was already recorded
+func (db *Database) RecordTradeLeg(id uuid.UUID, leg TradeLeg, txHash string) (recorded bool, err error) {
+	hashColumn, verifiedColumn := "payment_tx_hash", "payment_verified_at"
+	if leg == TradeTransfer {
+		hashColumn, verifiedColumn = "token_tx_hash", "transfer_verified_at"
+	}
+
+	err = db.db.WithContext(db.ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.Trade{}).
+			Where("id = ? AND status = ? AND "+verifiedColumn+" IS NULL", id, models.TradePending).
+			Updates(map[string]any{hashColumn: txHash, verifiedColumn: now})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		recorded = true
+		return tx.Model(&models.Trade{}).
+			Where("id = ? AND payment_verified_at IS NOT NULL AND transfer_verified_at IS NOT NULL", id).
+			Updates(map[string]any{"status": models.TradeSettled, "settled_at": now}).Error
+	})
+	return recorded, txHashConflict(err)
+}
+
+// ExpireTrades fails pending trades not settled by their deadline, returns how many
+// the orders they came from stay filled, a leg that was already sent has to be returned between the parties
+func (db *Database) ExpireTrades(now time.Time) (int64, error) {
+	result := db.db.WithContext(db.ctx).
+		Model(&models.Trade{}).
+		Where("status = ? AND expires_at < ?", models.TradePending, now).
+		Updates(map[string]any{"status": models.TradeFailed, "failed_at": now})
+	return result.RowsAffected, result.Error
+}
+
+// --- Portfolio Methods ---
+
+// Acquisition - tokens a wallet bought in one property and what it paid for them, decimal strings
+type Acquisition struct {
+	PropertyID uuid.UUID
+	Tokens     string
+	Cost       string // ETH
+}
+
+// GetAcquisitionsByWallet sums per property the transferred purchases and settled trades a wallet bought
+func (db *Database) GetAcquisitionsByWallet(wallet string) (result []Acquisition, err error) {
+	err = db.db.WithContext(db.ctx).Raw(`
+		SELECT property_id, SUM(amount)::text AS tokens, SUM(cost)::text AS cost
+		FROM (
+			SELECT property_id, amount, COALESCE(purchase_price, 0) AS cost FROM token_purchases
+			WHERE LOWER(buyer_wallet) = LOWER(?) AND status = ?
+			UNION ALL
+			SELECT property_id, amount, total FROM trades
+			WHERE LOWER(buyer_wallet) = LOWER(?) AND status = ?
+		) bought
+		GROUP BY property_id`,
+		wallet, models.PurchaseTransferred, wallet, models.TradeSettled,
+	).Scan(&result).Error
+	return
+}
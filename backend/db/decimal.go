@@ -0,0 +1,34 @@
+package db
+
+import (
+	"math/big"
+	"strings"
+)
+
+// Token amounts and ETH prices are kept in decimal columns as strings, these convert them exactly
+
+// ParseDecimal - exact value of a decimal string, "" counts as 0; false if it isn't a non-negative number
+func ParseDecimal(value string) (*big.Rat, bool) {
+	if value == "" {
+		return new(big.Rat), true
+	}
+	r, ok := new(big.Rat).SetString(value)
+	if !ok || r.Sign() < 0 {
+		return new(big.Rat), false
+	}
+	return r, true
+}
+
+// FormatDecimal - decimal string of a token or ETH amount, up to 18 decimals without trailing zeros
+func FormatDecimal(value *big.Rat) string {
+	s := strings.TrimRight(value.FloatString(18), "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// floorDecimal - value rounded down to 18 decimals, the smallest unit of tokens and ETH
+func floorDecimal(value *big.Rat) *big.Rat {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	units := new(big.Int).Mul(value.Num(), scale)
+	units.Quo(units, value.Denom())
+	return new(big.Rat).SetFrac(units, scale)
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"backend/db/models"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// orderFill - a resting order matched by an incoming order and the trade it made
+type orderFill struct {
+	Maker models.Order // Filled and Status as they are after the fill
+	Trade models.Trade
+}
+
+// matchOrder matches order against resting orders by price-time priority: best price first,
+// the oldest order first at one price, each trade at the resting order's price
+// Resting orders that are closed, on order's side, of order's wallet or that don't cross its price are never matched;
+// canFill is asked about each order before it is matched, the ones it gives a reason for are returned as rejected
+// with Status cancelled and that reason. order's Filled and Status are updated to what was matched
+func matchOrder(order *models.Order, resting []models.Order, canFill func(models.Order) (string, error)) (fills []orderFill, rejected []models.Order, err error) {
+	limit, _ := ParseDecimal(order.Price)
+	type candidate struct {
+		order models.Order
+		price *big.Rat
+	}
+	var book []candidate
+	for _, maker := range resting {
+		price, _ := ParseDecimal(maker.Price)
+		crosses := maker.Side != order.Side &&
+			(order.Side == models.OrderBid && price.Cmp(limit) <= 0 || order.Side == models.OrderAsk && price.Cmp(limit) >= 0)
+		if maker.Status != models.OrderOpen || strings.EqualFold(maker.Wallet, order.Wallet) || !crosses {
+			continue
+		}
+		book = append(book, candidate{maker, price})
+	}
+	sort.SliceStable(book, func(i, j int) bool {
+		// the lowest ask for a bid, the highest bid for an ask
+		if c := book[i].price.Cmp(book[j].price); c != 0 {
+			if order.Side == models.OrderBid {
+				return c < 0
+			}
+			return c > 0
+		}
+		if !book[i].order.CreatedAt.Equal(book[j].order.CreatedAt) {
+			return book[i].order.CreatedAt.Before(book[j].order.CreatedAt)
+		}
+		return book[i].order.ID.String() < book[j].order.ID.String()
+	})
+
+	amount, _ := ParseDecimal(order.Amount)
+	filled, _ := ParseDecimal(order.Filled)
+	remaining := new(big.Rat).Sub(amount, filled)
+	for _, c := range book {
+		if remaining.Sign() <= 0 {
+			break
+		}
+		maker := c.order
+		reason, err := canFill(maker)
+		if err != nil {
+			return nil, nil, err
+		}
+		if reason != "" {
+			now := time.Now()
+			maker.Status, maker.CancelReason, maker.CancelledAt = models.OrderCancelled, reason, &now
+			rejected = append(rejected, maker)
+			continue
+		}
+
+		makerAmount, _ := ParseDecimal(maker.Amount)
+		makerFilled, _ := ParseDecimal(maker.Filled)
+		fill := new(big.Rat).Sub(makerAmount, makerFilled)
+		if fill.Sign() <= 0 {
+			continue
+		}
+		if fill.Cmp(remaining) > 0 {
+			fill.Set(remaining)
+		}
+
+		trade := models.Trade{
+			ID:         uuid.New(),
+			PropertyID: order.PropertyID,
+			TakerSide:  order.Side,
+			Price:      maker.Price,
+			Amount:     FormatDecimal(fill),
+			Total:      FormatDecimal(floorDecimal(new(big.Rat).Mul(fill, c.price))),
+			Status:     models.TradePending,
+		}
+		bid, ask := order, &maker
+		if order.Side == models.OrderAsk {
+			bid, ask = ask, bid
+		}
+		trade.BidOrderID, trade.BuyerWallet = bid.ID, bid.Wallet
+		trade.AskOrderID, trade.SellerWallet = ask.ID, ask.Wallet
+
+		setFilled(&maker, makerFilled.Add(makerFilled, fill), makerAmount)
+		remaining.Sub(remaining, fill)
+		fills = append(fills, orderFill{Maker: maker, Trade: trade})
+	}
+
+	setFilled(order, new(big.Rat).Sub(amount, remaining), amount)
+	return fills, rejected, nil
+}
+
+// setFilled - set how much of an order is matched, it is filled once that reaches its amount
+func setFilled(order *models.Order, filled, amount *big.Rat) {
+	order.Filled = FormatDecimal(filled)
+	if filled.Cmp(amount) >= 0 {
+		order.Status = models.OrderFilled
+	}
+}
@@ -0,0 +1,227 @@
+package db
+
+import (
+	"backend/db/models"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestMatchOrder(t *testing.T) {
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	names := map[uuid.UUID]string{} // resting orders are told apart by name
+	resting := func(name string, side models.OrderSide, wallet, price, amount, filled string, age int) models.Order {
+		id := uuid.NewSHA1(uuid.Nil, []byte(name))
+		names[id] = name
+		return models.Order{
+			ID:        id,
+			Wallet:    wallet,
+			Side:      side,
+			Status:    models.OrderOpen,
+			Price:     price,
+			Amount:    amount,
+			Filled:    filled,
+			CreatedAt: base.Add(time.Duration(age) * time.Minute),
+		}
+	}
+
+	type fill struct {
+		maker  string
+		amount string
+		price  string
+		total  string
+	}
+	tests := []struct {
+		name       string
+		order      models.Order
+		book       []models.Order
+		unfit      map[string]string // canFill reason by maker name
+		wantFills  []fill
+		wantReject []string
+		wantFilled string
+		wantStatus models.OrderStatus
+	}{
+		{
+			name:  "bid takes the cheapest ask first, at the ask's price",
+			order: models.Order{Side: models.OrderBid, Wallet: "0xBuyer", Price: "0.10", Amount: "5"},
+			book: []models.Order{
+				resting("dear", models.OrderAsk, "0xA", "0.09", "10", "0", 0),
+				resting("cheap", models.OrderAsk, "0xB", "0.05", "10", "0", 5),
+			},
+			wantFills:  []fill{{"cheap", "5", "0.05", "0.25"}},
+			wantFilled: "5",
+			wantStatus: models.OrderFilled,
+		},
+		{
+			name:  "ask takes the highest bid first",
+			order: models.Order{Side: models.OrderAsk, Wallet: "0xSeller", Price: "0.05", Amount: "3"},
+			book: []models.Order{
+				resting("low", models.OrderBid, "0xA", "0.06", "10", "0", 0),
+				resting("high", models.OrderBid, "0xB", "0.08", "10", "0", 5),
+			},
+			wantFills:  []fill{{"high", "3", "0.08", "0.24"}},
+			wantFilled: "3",
+			wantStatus: models.OrderFilled,
+		},
+		{
+			name:  "oldest order first at one price",
+			order: models.Order{Side: models.OrderBid, Wallet: "0xBuyer", Price: "1", Amount: "4"},
+			book: []models.Order{
+				resting("newer", models.OrderAsk, "0xA", "1", "4", "0", 10),
+				resting("older", models.OrderAsk, "0xB", "1", "4", "0", 1),
+			},
+			wantFills:  []fill{{"older", "4", "1", "4"}},
+			wantFilled: "4",
+			wantStatus: models.OrderFilled,
+		},
+		{
+			name:  "partial fills across levels, the rest stays open",
+			order: models.Order{Side: models.OrderBid, Wallet: "0xBuyer", Price: "0.2", Amount: "10"},
+			book: []models.Order{
+				resting("first", models.OrderAsk, "0xA", "0.1", "5", "2", 0),
+				resting("second", models.OrderAsk, "0xB", "0.2", "4", "0", 1),
+				resting("too-dear", models.OrderAsk, "0xC", "0.3", "50", "0", 2),
+			},
+			wantFills:  []fill{{"first", "3", "0.1", "0.3"}, {"second", "4", "0.2", "0.8"}},
+			wantFilled: "7",
+			wantStatus: models.OrderOpen,
+		},
+		{
+			name:  "incoming order smaller than the resting one",
+			order: models.Order{Side: models.OrderAsk, Wallet: "0xSeller", Price: "0.5", Amount: "1.5"},
+			book: []models.Order{
+				resting("big", models.OrderBid, "0xA", "0.5", "100", "0", 0),
+			},
+			wantFills:  []fill{{"big", "1.5", "0.5", "0.75"}},
+			wantFilled: "1.5",
+			wantStatus: models.OrderFilled,
+		},
+		{
+			name:  "never matches an order of the same wallet",
+			order: models.Order{Side: models.OrderBid, Wallet: "0xabc", Price: "1", Amount: "2"},
+			book: []models.Order{
+				resting("own", models.OrderAsk, "0xABC", "0.5", "2", "0", 0),
+				resting("other", models.OrderAsk, "0xDEF", "0.9", "2", "0", 1),
+			},
+			wantFills:  []fill{{"other", "2", "0.9", "1.8"}},
+			wantFilled: "2",
+			wantStatus: models.OrderFilled,
+		},
+		{
+			name:  "closed, same side and non-crossing orders are skipped",
+			order: models.Order{Side: models.OrderBid, Wallet: "0xBuyer", Price: "1", Amount: "2"},
+			book: []models.Order{
+				func() models.Order {
+					o := resting("cancelled", models.OrderAsk, "0xA", "0.5", "2", "0", 0)
+					o.Status = models.OrderCancelled
+					return o
+				}(),
+				resting("bid", models.OrderBid, "0xB", "0.5", "2", "0", 0),
+				resting("dear", models.OrderAsk, "0xC", "1.01", "2", "0", 0),
+			},
+			wantFilled: "0",
+			wantStatus: models.OrderOpen,
+		},
+		{
+			name:  "rejected makers are cancelled and the next one is matched",
+			order: models.Order{Side: models.OrderBid, Wallet: "0xBuyer", Price: "1", Amount: "2"},
+			book: []models.Order{
+				resting("uncovered", models.OrderAsk, "0xA", "0.5", "2", "0", 0),
+				resting("covered", models.OrderAsk, "0xB", "0.6", "2", "0", 1),
+			},
+			unfit:      map[string]string{"uncovered": "not enough tokens"},
+			wantFills:  []fill{{"covered", "2", "0.6", "1.2"}},
+			wantReject: []string{"uncovered"},
+			wantFilled: "2",
+			wantStatus: models.OrderFilled,
+		},
+		{
+			name:  "total is rounded down to wei",
+			order: models.Order{Side: models.OrderBid, Wallet: "0xBuyer", Price: "0.333333333333333333", Amount: "0.5"},
+			book: []models.Order{
+				resting("odd", models.OrderAsk, "0xA", "0.333333333333333333", "1", "0", 0),
+			},
+			wantFills:  []fill{{"odd", "0.5", "0.333333333333333333", "0.166666666666666666"}},
+			wantFilled: "0.5",
+			wantStatus: models.OrderFilled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := tt.order
+			order.ID, order.Status, order.Filled = uuid.New(), models.OrderOpen, "0"
+			canFill := func(maker models.Order) (string, error) {
+				return tt.unfit[names[maker.ID]], nil
+			}
+
+			fills, rejected, err := matchOrder(&order, tt.book, canFill)
+			if err != nil {
+				t.Fatalf("matchOrder: %v", err)
+			}
+			if len(fills) != len(tt.wantFills) {
+				t.Fatalf("got %d fills, want %d", len(fills), len(tt.wantFills))
+			}
+			for i, want := range tt.wantFills {
+				got := fills[i]
+				if names[got.Maker.ID] != want.maker {
+					t.Errorf("fill %d matched %q, want %q", i, names[got.Maker.ID], want.maker)
+				}
+				if got.Trade.Amount != want.amount || got.Trade.Price != want.price || got.Trade.Total != want.total {
+					t.Errorf("fill %d traded %s at %s for %s, want %s at %s for %s",
+						i, got.Trade.Amount, got.Trade.Price, got.Trade.Total, want.amount, want.price, want.total)
+				}
+				if got.Trade.Status != models.TradePending {
+					t.Errorf("fill %d trade is %s, want pending", i, got.Trade.Status)
+				}
+				buyer, seller := order.Wallet, got.Maker.Wallet
+				if order.Side == models.OrderAsk {
+					buyer, seller = seller, buyer
+				}
+				if got.Trade.BuyerWallet != buyer || got.Trade.SellerWallet != seller {
+					t.Errorf("fill %d is %s buying from %s, want %s from %s", i, got.Trade.BuyerWallet, got.Trade.SellerWallet, buyer, seller)
+				}
+			}
+			if len(rejected) != len(tt.wantReject) {
+				t.Fatalf("got %d rejected, want %d", len(rejected), len(tt.wantReject))
+			}
+			for i, want := range tt.wantReject {
+				if names[rejected[i].ID] != want || rejected[i].Status != models.OrderCancelled || rejected[i].CancelReason == "" {
+					t.Errorf("rejected %d is %q (%s, %q), want %q cancelled with a reason",
+						i, names[rejected[i].ID], rejected[i].Status, rejected[i].CancelReason, want)
+				}
+			}
+			if order.Filled != tt.wantFilled || order.Status != tt.wantStatus {
+				t.Errorf("order is %s with %s filled, want %s with %s", order.Status, order.Filled, tt.wantStatus, tt.wantFilled)
+			}
+		})
+	}
+}
+
+func TestMatchOrderMakerFilled(t *testing.T) {
+	maker := models.Order{ID: uuid.New(), Wallet: "0xA", Side: models.OrderAsk, Status: models.OrderOpen, Price: "1", Amount: "5", Filled: "1"}
+	order := models.Order{ID: uuid.New(), Wallet: "0xB", Side: models.OrderBid, Status: models.OrderOpen, Price: "1", Amount: "10", Filled: "0"}
+
+	fills, _, err := matchOrder(&order, []models.Order{maker}, func(models.Order) (string, error) { return "", nil })
+	if err != nil {
+		t.Fatalf("matchOrder: %v", err)
+	}
+	if len(fills) != 1 || fills[0].Maker.Filled != "5" || fills[0].Maker.Status != models.OrderFilled {
+		t.Fatalf("maker after fill = %+v, want 5 filled and status filled", fills)
+	}
+	if fills[0].Trade.Amount != "4" {
+		t.Errorf("traded %s, want the 4 the maker had left", fills[0].Trade.Amount)
+	}
+}
+
+func TestMatchOrderCanFillError(t *testing.T) {
+	maker := models.Order{ID: uuid.New(), Wallet: "0xA", Side: models.OrderAsk, Status: models.OrderOpen, Price: "1", Amount: "5", Filled: "0"}
+	order := models.Order{ID: uuid.New(), Wallet: "0xB", Side: models.OrderBid, Status: models.OrderOpen, Price: "1", Amount: "1", Filled: "0"}
+
+	wantErr := errors.New("rpc down")
+	if _, _, err := matchOrder(&order, []models.Order{maker}, func(models.Order) (string, error) { return "", wantErr }); !errors.Is(err, wantErr) {
+		t.Fatalf("err = %v, want %v", err, wantErr)
+	}
+}
@@ -2,6 +2,7 @@
 package models
 
 import (
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,6 +14,11 @@ type UserRole string
 const (
 	RoleAdmin UserRole = "admin" // admin role
 	RoleUser  UserRole = "user"  // regular user role
+
+	// staff roles, granted on top of the user role through RoleAssignment
+	RoleComplianceReviewer UserRole = "compliance_reviewer" // reviews and approves investors
+	RolePropertyManager    UserRole = "property_manager"    // creates properties and handles upload requests
+	RoleRevenueDistributor UserRole = "revenue_distributor" // deposits property revenue
 )
 
 // ApprovalStatus - approval states for users and properties
@@ -22,6 +28,7 @@ const (
 	ApprovalPending  ApprovalStatus = "pending"  // waiting for approval
 	ApprovalApproved ApprovalStatus = "approved" // approved for access
 	ApprovalRejected ApprovalStatus = "rejected" // rejected access
+	ApprovalRevoked  ApprovalStatus = "revoked"  // approval withdrawn after it was granted
 )
 
 // User - main user table structure
@@ -33,8 +40,17 @@ type User struct {
 	PasswordHash   string         `json:"-" gorm:"not null"` // Exclude from JSON
 	Role           UserRole       `json:"Role" gorm:"type:user_role;default:'user'"`
 	ApprovalStatus ApprovalStatus `json:"approval_status" gorm:"type:approval_status;default:'pending'"`
-	CreatedAt      time.Time      `json:"CreatedAt"`
-	UpdatedAt      time.Time      `json:"UpdatedAt"`
+	// Block the on-chain Approved event was indexed from, cleared if that block is reorged out
+	ApprovalBlockNumber uint64     `json:"-" gorm:"type:bigint"`
+	ApprovalBlockHash   string     `json:"-" gorm:"type:varchar(100);index"`
+	EmailVerifiedAt     *time.Time `json:"email_verified_at"`           // Set once the user followed the verification link
+	TOTPSecret          string     `json:"-" gorm:"type:varchar(64)"`   // Authenticator secret, set at enrollment
+	TOTPEnabledAt       *time.Time `json:"totp_enabled_at"`             // Set once enrollment was confirmed with a code
+	TOTPLastStep        int64      `json:"-" gorm:"not null;default:0"` // Last accepted time step, a code is only good once
+	CreatedAt           time.Time  `json:"CreatedAt"`
+	UpdatedAt           time.Time  `json:"UpdatedAt"`
+	// Status before the indexed approval event, restored if its block is reorged out and the chain doesn't show the user approved
+	ApprovalPreviousStatus ApprovalStatus `json:"-" gorm:"type:approval_status;default:'pending'"`
 }
 
 // PropertyStatus - property lifecycle states
@@ -58,6 +74,8 @@ type Property struct {
 	Valuation           float64        `gorm:"type:decimal"`                          // DECIMAL, using float64 or string for precision
 	Status              PropertyStatus `gorm:"type:property_status;default:'Active'"` // Synced from blockchain
 	TxHash              string         `gorm:"type:varchar(100)"`                     // Transaction hash of property creation
+	BlockNumber         uint64         `json:"-" gorm:"type:bigint"`                  // Block the PropertyRegistered event was indexed from, 0 if the API recorded it
+	BlockHash           string         `json:"-" gorm:"type:varchar(100);index"`      // Used to detect properties orphaned by a reorg
 	CreatedAt           time.Time
 	// Relationships
 	Documents []PropertyDocument    `gorm:"foreignKey:PropertyID"`
@@ -77,37 +95,59 @@ type PropertyDocument struct {
 }
 
 type RevenueDistribution struct {
-	ID               uuid.UUID `gorm:"type:uuid;primaryKey"`
-	PropertyID       uuid.UUID `gorm:"type:uuid;not null;index"`   // FK(properties.id)
-	SnapshotID       int32     `gorm:"type:int;not null"`          // Matches on-chain snapshot ID
-	StablecoinTxHash string    `gorm:"type:varchar(100);not null"` // Deposit transaction hash
-	TotalAmount      string    `gorm:"type:decimal;not null"`      // Using string for high-precision DECIMAL
-	CreatedAt        time.Time
+	ID                    uuid.UUID `gorm:"type:uuid;primaryKey"`
+	PropertyID            uuid.UUID `gorm:"type:uuid;not null;index"`   // FK(properties.id)
+	SnapshotID            int32     `gorm:"type:int;not null"`          // Matches on-chain snapshot ID
+	OnchainDistributionID *int64    `gorm:"type:bigint;index"`          // On-chain distributionId, nil for rows recorded before it was tracked
+	StablecoinTxHash      string    `gorm:"type:varchar(100);not null"` // Deposit transaction hash
+	TotalAmount           string    `gorm:"type:decimal;not null"`      // Using string for high-precision DECIMAL
+	BlockNumber           uint64    `gorm:"type:bigint;index"`          // Block the deposit event was indexed from
+	BlockHash             string    `gorm:"type:varchar(100);index"`    // Used to detect rows orphaned by a reorg
+	CreatedAt             time.Time
 	// Relationships
 	Property Property       `gorm:"foreignKey:PropertyID"`
 	Claims   []RevenueClaim `gorm:"foreignKey:RevenueDistributionID"`
 }
 
 type RevenueClaim struct {
-	ID                    uuid.UUID `gorm:"type:uuid;primaryKey"`
-	RevenueDistributionID uuid.UUID `gorm:"type:uuid;not null;index"`         // FK(revenue_distributions.id)
-	WalletAddress         string    `gorm:"type:varchar(100);not null;index"` // Investor wallet address
-	Amount                string    `gorm:"type:decimal;not null"`            // Using string for claimed revenue DECIMAL
-	TxHash                string    `gorm:"type:varchar(100);not null"`       // Blockchain transaction hash
+	ID                    uuid.UUID  `gorm:"type:uuid;primaryKey"`
+	RevenueDistributionID *uuid.UUID `gorm:"type:uuid;index"`                  // FK(revenue_distributions.id), nil until the parent deposit is indexed
+	OnchainDistributionID *int64     `gorm:"type:bigint;index"`                // On-chain distributionId from RevenueClaimed
+	WalletAddress         string     `gorm:"type:varchar(100);not null;index"` // Investor wallet address
+	Amount                string     `gorm:"type:decimal;not null"`            // Using string for claimed revenue DECIMAL
+	TxHash                string     `gorm:"type:varchar(100);not null"`       // Blockchain transaction hash
+	BlockNumber           uint64     `gorm:"type:bigint;index"`                // Block the claim event was indexed from
+	BlockHash             string     `gorm:"type:varchar(100);index"`          // Used to detect rows orphaned by a reorg
 	ClaimedAt             time.Time
 	// Relationships
 	Distribution RevenueDistribution `gorm:"foreignKey:RevenueDistributionID"`
 }
 
+// IndexerCursor - last block the event indexer has fully processed for a contract
+// one row per contract, so the worker can backfill from here after a restart
+type IndexerCursor struct {
+	Name            string    `gorm:"type:varchar(100);primaryKey"` // Cursor key, e.g. "revenue_distribution"
+	ContractAddress string    `gorm:"type:varchar(100);not null"`   // Contract the cursor belongs to
+	LastBlock       uint64    `gorm:"type:bigint;not null"`         // Last fully processed block number
+	LastBlockHash   string    `gorm:"type:varchar(100)"`            // Hash of LastBlock, a mismatch means a reorg
+	UpdatedAt       time.Time
+}
+
 // TransactionType represents the actions recorded in the audit log.
 type TransactionType string
 
 const (
-	TxTypeApproveUser    TransactionType = "approve_user"
-	TxTypeCreateProperty TransactionType = "create_property"
-	TxTypeDepositRevenue TransactionType = "deposit_revenue"
-	TxTypeClaimRevenue   TransactionType = "claim_revenue"
-	TxTypeTransferToken  TransactionType = "transfer_token"
+	TxTypeApproveUser     TransactionType = "approve_user"
+	TxTypeCreateProperty  TransactionType = "create_property"
+	TxTypeDepositRevenue  TransactionType = "deposit_revenue"
+	TxTypeClaimRevenue    TransactionType = "claim_revenue"
+	TxTypeTransferToken   TransactionType = "transfer_token"
+	TxTypeApproveProperty TransactionType = "approve_property"
+	TxTypeRejectProperty  TransactionType = "reject_property"
+	TxTypeGrantRole       TransactionType = "grant_role"
+	TxTypeRevokeRole      TransactionType = "revoke_role"
+	TxTypeRejectUser      TransactionType = "reject_user"
+	TxTypeRevokeUser      TransactionType = "revoke_user"
 )
 
 // TransactionStatus represents the status of blockchain transactions.
@@ -122,6 +162,7 @@ const (
 type Transaction struct {
 	ID            uuid.UUID         `gorm:"type:uuid;primaryKey"`
 	WalletAddress string            `gorm:"type:varchar(100);not null;index"` // Who performed the action
+	RelatedWallet string            `gorm:"type:varchar(100);index"`          // Wallet the action concerns (approved user, owner, recipient)
 	Type          TransactionType   `gorm:"type:transaction_type;not null"`
 	Status        TransactionStatus `gorm:"type:transaction_status;default:'pending'"` // Transaction status
 	TxHash        string            `gorm:"type:varchar(100)"`                         // Blockchain transaction hash
@@ -172,19 +213,379 @@ func (PropertyUploadRequestDocument) TableName() string {
 
 // TokenPurchase represents a token purchase record for tracking token sales
 type TokenPurchase struct {
-	ID            uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
-	PropertyID    uuid.UUID `gorm:"type:uuid;not null;index" json:"property_id"` // FK(properties.id)
-	BuyerWallet   string    `gorm:"type:varchar(100);not null;index" json:"buyer_wallet"` // Buyer's wallet address
-	Amount        string    `gorm:"type:decimal;not null" json:"amount"` // Token amount purchased (using string for precision)
-	PaymentTxHash string    `gorm:"type:varchar(100)" json:"payment_tx_hash"` // ETH payment transaction hash (optional)
-	TokenTxHash   string    `gorm:"type:varchar(100);not null;default:'pending'" json:"token_tx_hash"` // Token transfer transaction hash (use "pending" until owner approves)
-	PurchasePrice string    `gorm:"type:decimal" json:"purchase_price"` // ETH paid (optional, using string for precision)
-	CreatedAt     time.Time `json:"created_at"`
+	ID                 uuid.UUID      `gorm:"type:uuid;primaryKey" json:"id"`
+	PropertyID         uuid.UUID      `gorm:"type:uuid;not null;index" json:"property_id"`          // FK(properties.id)
+	OfferingID         *uuid.UUID     `gorm:"type:uuid;index" json:"offering_id,omitempty"`         // FK(offerings.id), the offering the tokens were reserved from
+	BuyerWallet        string         `gorm:"type:varchar(100);not null;index" json:"buyer_wallet"` // Buyer's wallet address
+	Amount             string         `gorm:"type:decimal;not null" json:"amount"`                  // Token amount purchased (using string for precision)
+	Status             PurchaseStatus `gorm:"type:purchase_status;not null;default:'awaiting_payment';index" json:"status"`
+	StatusReason       string         `gorm:"type:text" json:"status_reason,omitempty"`          // Why the purchase was declined or cancelled
+	PaymentTxHash      string         `gorm:"type:varchar(100)" json:"payment_tx_hash"`          // ETH payment transaction hash
+	TokenTxHash        string         `gorm:"type:varchar(100)" json:"token_tx_hash"`            // Token transfer transaction hash, set once transferred
+	RefundTxHash       string         `gorm:"type:varchar(100)" json:"refund_tx_hash,omitempty"` // ETH refund transaction hash, set once refunded
+	PurchasePrice      string         `gorm:"type:decimal" json:"purchase_price"`                // ETH paid (using string for precision)
+	ExpiresAt          *time.Time     `json:"expires_at"`                                        // Expired if not approved by then
+	PaymentVerifiedAt  *time.Time     `json:"payment_verified_at"`                               // Set once the payment tx was checked on-chain (paid)
+	ApprovedAt         *time.Time     `json:"approved_at"`
+	TransferVerifiedAt *time.Time     `json:"transfer_verified_at"` // Set once the token Transfer log was checked on-chain (transferred)
+	DeclinedAt         *time.Time     `json:"declined_at"`
+	CancelledAt        *time.Time     `json:"cancelled_at"`
+	ExpiredAt          *time.Time     `json:"expired_at"`
+	RefundedAt         *time.Time     `json:"refunded_at"`
+	CreatedAt          time.Time      `json:"created_at"`
 	// Relationships
 	Property Property `gorm:"foreignKey:PropertyID"`
 }
 
+// PurchaseStatus - lifecycle of a token purchase, allowed changes are in PurchaseTransitions
+type PurchaseStatus string
+
+const (
+	PurchaseAwaitingPayment PurchaseStatus = "awaiting_payment" // recorded, the payment doesn't have enough confirmations yet
+	PurchasePaid            PurchaseStatus = "paid"             // payment verified on-chain, waiting for the owner
+	PurchaseApproved        PurchaseStatus = "approved"         // owner accepted, token transfer outstanding
+	PurchaseTransferred     PurchaseStatus = "transferred"      // token transfer verified on-chain, final
+	PurchaseDeclined        PurchaseStatus = "declined"         // owner declined, a paid purchase is owed a refund
+	PurchaseCancelled       PurchaseStatus = "cancelled"        // buyer cancelled before approval, a paid purchase is owed a refund
+	PurchaseExpired         PurchaseStatus = "expired"          // not approved in time, a paid purchase is owed a refund
+	PurchaseRefunded        PurchaseStatus = "refunded"         // payment returned to the buyer, final
+)
+
+// PurchaseTransitions - statuses a purchase may move to from each status
+var PurchaseTransitions = map[PurchaseStatus][]PurchaseStatus{
+	PurchaseAwaitingPayment: {PurchasePaid, PurchaseCancelled, PurchaseExpired},
+	PurchasePaid:            {PurchaseApproved, PurchaseDeclined, PurchaseCancelled, PurchaseExpired},
+	PurchaseApproved:        {PurchaseTransferred, PurchaseDeclined},
+	PurchaseDeclined:        {PurchaseRefunded},
+	PurchaseCancelled:       {PurchaseRefunded},
+	PurchaseExpired:         {PurchaseRefunded},
+}
+
+// CanTransitionTo reports whether a purchase in status s may move to status to
+func (s PurchaseStatus) CanTransitionTo(to PurchaseStatus) bool {
+	return slices.Contains(PurchaseTransitions[s], to)
+}
+
 // TableName specifies the table name for TokenPurchase
 func (TokenPurchase) TableName() string {
 	return "token_purchases"
 }
+
+// Offering - primary sale of a property's tokens at a fixed price during a window
+// Amounts are in token units and prices in ETH, stored as decimals
+type Offering struct {
+	ID            uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	PropertyID    uuid.UUID  `json:"property_id" gorm:"type:uuid;not null;index"` // FK(properties.id)
+	PricePerToken string     `json:"price_per_token" gorm:"type:decimal;not null"` // ETH per token
+	StartsAt      time.Time  `json:"starts_at" gorm:"not null"`
+	EndsAt        time.Time  `json:"ends_at" gorm:"not null"`
+	MinAllocation string     `json:"min_allocation" gorm:"type:decimal;not null"` // Smallest purchase accepted
+	MaxAllocation string     `json:"max_allocation" gorm:"type:decimal;not null"` // Most tokens one wallet may buy in the offering, 0 for no limit
+	HardCap       string     `json:"hard_cap" gorm:"type:decimal;not null"`       // Tokens for sale, purchases can't reserve more
+	CreatedBy     uuid.UUID  `json:"created_by" gorm:"type:uuid;not null"`
+	ClosedAt      *time.Time `json:"closed_at,omitempty"` // Set when closed before EndsAt
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// OrderSide - side of a property's order book
+type OrderSide string
+
+const (
+	OrderBid OrderSide = "bid" // buys tokens
+	OrderAsk OrderSide = "ask" // sells tokens
+)
+
+// OrderStatus - lifecycle of an order book order
+type OrderStatus string
+
+const (
+	OrderOpen      OrderStatus = "open"      // on the book, Filled may already be above 0
+	OrderFilled    OrderStatus = "filled"    // fully matched
+	OrderCancelled OrderStatus = "cancelled" // withdrawn by its owner, or dropped because it could no longer settle
+)
+
+// Order - limit order on a property's secondary market, amounts are in tokens and prices in ETH per token
+type Order struct {
+	ID           uuid.UUID   `json:"id" gorm:"type:uuid;primaryKey"`
+	PropertyID   uuid.UUID   `json:"property_id" gorm:"type:uuid;not null;index:idx_orders_book,priority:1"` // FK(properties.id)
+	UserID       uuid.UUID   `json:"user_id" gorm:"type:uuid;not null;index"`
+	Wallet       string      `json:"wallet" gorm:"type:varchar(100);not null;index"` // Receives the tokens of a bid, delivers those of an ask
+	Side         OrderSide   `json:"side" gorm:"type:varchar(4);not null;index:idx_orders_book,priority:2"`
+	Status       OrderStatus `json:"status" gorm:"type:varchar(20);not null;index:idx_orders_book,priority:3"`
+	Price        string      `json:"price" gorm:"type:decimal;not null"`  // Limit price, ETH per token
+	Amount       string      `json:"amount" gorm:"type:decimal;not null"` // Tokens to buy or sell
+	Filled       string      `json:"filled" gorm:"type:decimal;not null"` // Tokens matched so far
+	CancelReason string      `json:"cancel_reason,omitempty" gorm:"type:text"`
+	CancelledAt  *time.Time  `json:"cancelled_at,omitempty"`
+	CreatedAt    time.Time   `json:"created_at"` // Time priority among orders at the same price
+	UpdatedAt    time.Time   `json:"updated_at"`
+}
+
+// TradeStatus - settlement state of a matched trade
+type TradeStatus string
+
+const (
+	TradePending TradeStatus = "pending" // matched, payment or token transfer not verified yet
+	TradeSettled TradeStatus = "settled" // payment and token transfer both verified on-chain
+	TradeFailed  TradeStatus = "failed"  // not settled before ExpiresAt, no longer holds the seller's tokens
+)
+
+// Trade - a match between a bid and an ask, settled by the buyer paying Total ETH
+// to the seller and the seller transferring Amount tokens to the buyer
+type Trade struct {
+	ID                 uuid.UUID   `json:"id" gorm:"type:uuid;primaryKey"`
+	PropertyID         uuid.UUID   `json:"property_id" gorm:"type:uuid;not null;index"` // FK(properties.id)
+	BidOrderID         uuid.UUID   `json:"bid_order_id" gorm:"type:uuid;not null;index"`
+	AskOrderID         uuid.UUID   `json:"ask_order_id" gorm:"type:uuid;not null;index"`
+	TakerSide          OrderSide   `json:"taker_side" gorm:"type:varchar(4);not null"` // Side of the order that matched the resting one
+	BuyerWallet        string      `json:"buyer_wallet" gorm:"type:varchar(100);not null;index"`
+	SellerWallet       string      `json:"seller_wallet" gorm:"type:varchar(100);not null;index"`
+	Price              string      `json:"price" gorm:"type:decimal;not null"`  // Price of the resting order, ETH per token
+	Amount             string      `json:"amount" gorm:"type:decimal;not null"` // Tokens traded
+	Total              string      `json:"total" gorm:"type:decimal;not null"`  // ETH owed, Amount x Price rounded down to wei
+	Status             TradeStatus `json:"status" gorm:"type:varchar(20);not null;index"`
+	PaymentTxHash      string      `json:"payment_tx_hash,omitempty" gorm:"type:varchar(100)"`
+	TokenTxHash        string      `json:"token_tx_hash,omitempty" gorm:"type:varchar(100)"`
+	PaymentVerifiedAt  *time.Time  `json:"payment_verified_at,omitempty"`
+	TransferVerifiedAt *time.Time  `json:"transfer_verified_at,omitempty"`
+	SettledAt          *time.Time  `json:"settled_at,omitempty"`
+	ExpiresAt          *time.Time  `json:"expires_at,omitempty"` // Failed if both legs aren't verified by then
+	FailedAt           *time.Time  `json:"failed_at,omitempty"`
+	CreatedAt          time.Time   `json:"created_at"`
+}
+
+// JobStatus - lifecycle of a background job
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"    // waiting for a worker
+	JobRunning   JobStatus = "running"   // claimed by a worker, LockedUntil bounds the lease
+	JobSucceeded JobStatus = "succeeded" // finished, Result holds the outcome
+	JobFailed    JobStatus = "failed"    // gave up, Error holds the reason
+)
+
+// JobType - the operation a job performs
+type JobType string
+
+const (
+	JobCreateProperty       JobType = "create_property"
+	JobApproveUploadRequest JobType = "approve_upload_request"
+	JobApproveUser          JobType = "approve_user"
+	JobRejectUser           JobType = "reject_user"
+	JobRevokeUser           JobType = "revoke_user"
+)
+
+// Job - long-running blockchain operation queued by the API and executed by a background worker
+// TxHash is stored as soon as the transaction is sent, so a retried job waits for it instead of sending again
+type Job struct {
+	ID             uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	Type           JobType    `json:"type" gorm:"type:varchar(50);not null;index"`
+	Status         JobStatus  `json:"status" gorm:"type:job_status;not null;default:'queued';index"`
+	IdempotencyKey string     `json:"idempotency_key" gorm:"type:varchar(255);not null;index"` // Unique among jobs that haven't failed
+	ActiveKeyOnly  bool       `json:"-" gorm:"not null;default:false"`                         // IdempotencyKey only blocks duplicates while queued or running
+	CreatedBy      uuid.UUID  `json:"created_by" gorm:"type:uuid;index"`                       // User who enqueued the job
+	Payload        string     `json:"-" gorm:"type:json;not null"`                             // Job input
+	Result         string     `json:"-" gorm:"type:json"`                                      // Job output once succeeded
+	Attempts       int        `json:"attempts" gorm:"not null;default:0"`
+	MaxAttempts    int        `json:"max_attempts" gorm:"not null;default:5"`
+	TxHash         string     `json:"tx_hash,omitempty" gorm:"type:varchar(100)"`
+	PropertyID     *uuid.UUID `json:"property_id,omitempty" gorm:"type:uuid"` // Property created by the job
+	Error          string     `json:"error,omitempty" gorm:"type:text"`
+	RunAfter       time.Time  `json:"run_after" gorm:"not null;index"` // Not picked up before this time (retry backoff)
+	LockedUntil    *time.Time `json:"-"`                               // Lease of the running worker, expired leases are picked up again
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	CompletedAt    *time.Time `json:"completed_at,omitempty"`
+}
+
+// RefreshToken - one issued refresh token, stored as a SHA-256 hash
+// every refresh rotates the token within its family (one login session); presenting a token that was
+// already used or revoked is treated as theft and revokes the whole family
+type RefreshToken struct {
+	ID         uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	UserID     uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index"`
+	FamilyID   uuid.UUID  `json:"family_id" gorm:"type:uuid;not null;index"` // Session, carried as the sid claim of access tokens
+	TokenHash  string     `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	MFA        bool       `json:"mfa" gorm:"not null;default:false"` // Session started with a second factor
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt     *time.Time `json:"used_at,omitempty"`    // Set when the token was rotated
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"` // Set on logout or reuse detection
+	UserAgent  string     `json:"user_agent" gorm:"type:varchar(255)"`
+	IPAddress  string     `json:"ip_address" gorm:"type:varchar(100)"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ReplacedBy *uuid.UUID `json:"-" gorm:"type:uuid"` // Token issued when this one was rotated
+}
+
+// RevokedToken - access token (by jti) logged out before it expired
+// rows are only needed until ExpiresAt, after that the JWT is rejected anyway
+type RevokedToken struct {
+	JTI       string    `gorm:"type:varchar(64);primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;index"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time
+}
+
+// AuthNonce - single-use nonce handed out for a Sign-In with Ethereum message
+type AuthNonce struct {
+	Nonce     string     `gorm:"type:varchar(64);primaryKey"`
+	ExpiresAt time.Time  `gorm:"not null;index"`
+	UsedAt    *time.Time // Set once a signed message carrying the nonce was accepted
+	CreatedAt time.Time
+}
+
+// RoleAssignment - staff role granted to a user in addition to User.Role
+// OnChain records whether the matching contract role was granted to the user's wallet as well
+type RoleAssignment struct {
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;primaryKey"`
+	Role      UserRole  `json:"role" gorm:"type:user_role;primaryKey"`
+	GrantedBy uuid.UUID `json:"granted_by" gorm:"type:uuid"`
+	OnChain   bool      `json:"on_chain" gorm:"not null;default:false"`
+	TxHash    string    `json:"tx_hash,omitempty" gorm:"type:varchar(100)"` // Grant transaction when mirrored on-chain
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserTokenPurpose - what a single-use emailed token is good for
+type UserTokenPurpose string
+
+const (
+	TokenEmailVerification UserTokenPurpose = "email_verification"
+	TokenPasswordReset     UserTokenPurpose = "password_reset"
+)
+
+// UserToken - single-use, expiring token sent by email, stored as a SHA-256 hash
+type UserToken struct {
+	ID        uuid.UUID        `gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID        `gorm:"type:uuid;not null;index"`
+	Purpose   UserTokenPurpose `gorm:"type:varchar(50);not null"`
+	TokenHash string           `gorm:"type:varchar(64);not null;uniqueIndex"`
+	ExpiresAt time.Time        `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// RecoveryCode - single-use 2FA backup code, stored as a SHA-256 hash
+type RecoveryCode struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	CodeHash  string    `gorm:"type:varchar(64);not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// AuthLockout - failed logins counted against one key ("ip:<address>" or "account:<email>")
+// once the key reaches its threshold it is locked, every further failure doubles the lock
+type AuthLockout struct {
+	Key           string     `json:"key" gorm:"type:varchar(320);primaryKey"`
+	Failures      int        `json:"failures" gorm:"not null;default:0"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+	LastFailureAt time.Time  `json:"last_failure_at" gorm:"not null;index"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// RateLimitCounter - requests counted against one key in a fixed window
+type RateLimitCounter struct {
+	Key         string    `gorm:"type:varchar(320);primaryKey"`
+	WindowStart time.Time `gorm:"not null;index"`
+	Count       int       `gorm:"not null"`
+}
+
+// ServiceAccount - machine client (e.g. a property management system) that calls the API with API keys
+// it is not a user: it can't log in and only reaches routes its keys' scopes allow
+type ServiceAccount struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	Name        string     `json:"name" gorm:"type:varchar(100);not null;uniqueIndex"`
+	Description string     `json:"description" gorm:"type:text"`
+	CreatedBy   uuid.UUID  `json:"created_by" gorm:"type:uuid"`
+	DisabledAt  *time.Time `json:"disabled_at,omitempty"` // Set when disabled, its keys stop working
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// APIKeyScope - what an API key may do
+type APIKeyScope string
+
+const (
+	ScopeRead              APIKeyScope = "read"               // GET routes open to every user (properties, distributions, jobs)
+	ScopeDistributeRevenue APIKeyScope = "revenue:distribute" // POST /revenue/distribute
+)
+
+// APIKey - credential of a service account, only the SHA-256 hash of the key is stored
+type APIKey struct {
+	ID               uuid.UUID     `json:"id" gorm:"type:uuid;primaryKey"`
+	ServiceAccountID uuid.UUID     `json:"service_account_id" gorm:"type:uuid;not null;index"`
+	Name             string        `json:"name" gorm:"type:varchar(100)"`
+	Prefix           string        `json:"prefix" gorm:"type:varchar(16);not null"` // Start of the key, to tell keys apart
+	KeyHash          string        `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	Scopes           []APIKeyScope `json:"scopes" gorm:"serializer:json;type:jsonb;not null"`
+	ExpiresAt        *time.Time    `json:"expires_at,omitempty"`
+	LastUsedAt       *time.Time    `json:"last_used_at,omitempty"`
+	LastUsedIP       string        `json:"last_used_ip,omitempty" gorm:"type:varchar(100)"`
+	RevokedAt        *time.Time    `json:"revoked_at,omitempty"`
+	CreatedBy        uuid.UUID     `json:"created_by" gorm:"type:uuid"`
+	CreatedAt        time.Time     `json:"created_at"`
+}
+
+// KYCStatus - state of a KYC submission, every change is kept in KYCStatusChange
+type KYCStatus string
+
+const (
+	KYCSubmitted KYCStatus = "submitted" // waiting for a reviewer
+	KYCInReview  KYCStatus = "in_review" // a reviewer is recording checks
+	KYCApproved  KYCStatus = "approved"  // review completed, the wallet is approved on-chain
+	KYCRejected  KYCStatus = "rejected"  // review completed, the wallet is rejected on-chain
+)
+
+// KYCSubmission - identity evidence a user submits before their wallet can be approved
+type KYCSubmission struct {
+	ID            uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	UserID        uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index"`
+	WalletAddress string     `json:"wallet_address" gorm:"type:varchar(100);not null;index"`
+	LegalName     string     `json:"legal_name" gorm:"type:varchar(255);not null"`
+	DateOfBirth   string     `json:"date_of_birth" gorm:"type:varchar(10);not null"` // YYYY-MM-DD
+	Country       string     `json:"country" gorm:"type:varchar(2);not null"`        // ISO 3166-1 alpha-2
+	Status        KYCStatus  `json:"status" gorm:"type:varchar(20);not null;index"`
+	ReviewerID    *uuid.UUID `json:"reviewer_id,omitempty" gorm:"type:uuid"` // Reviewer who started the review
+	ReviewedAt    *time.Time `json:"reviewed_at,omitempty"`                  // Set when the review was completed
+	Decision      string     `json:"decision,omitempty" gorm:"type:text"`    // Reviewer's note on the outcome, shown to the user
+	JobID         *uuid.UUID `json:"job_id,omitempty" gorm:"type:uuid"`      // On-chain approve or reject job
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	// Relationships
+	Documents []KYCDocument     `json:"documents,omitempty" gorm:"foreignKey:SubmissionID"`
+	Checks    []KYCCheck        `json:"checks,omitempty" gorm:"foreignKey:SubmissionID"`
+	History   []KYCStatusChange `json:"history,omitempty" gorm:"foreignKey:SubmissionID"`
+}
+
+// KYCDocument - identity document of a submission, uploaded to IPFS
+type KYCDocument struct {
+	ID           uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	SubmissionID uuid.UUID `json:"submission_id" gorm:"type:uuid;not null;index"`
+	Type         string    `json:"type" gorm:"type:varchar(50);not null"` // identity_document, proof_of_address or other
+	Name         string    `json:"name" gorm:"type:varchar(255);not null"`
+	FileHash     string    `json:"file_hash" gorm:"type:varchar(255);not null"`
+	FileUrl      string    `json:"file_url" gorm:"type:text;not null"`
+	UploadedAt   time.Time `json:"uploaded_at"`
+}
+
+// KYCCheck - one check a reviewer recorded, checks are append-only and the latest per name counts
+type KYCCheck struct {
+	ID           uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	SubmissionID uuid.UUID `json:"submission_id" gorm:"type:uuid;not null;index"`
+	Name         string    `json:"name" gorm:"type:varchar(50);not null"`
+	Passed       bool      `json:"passed" gorm:"not null"`
+	Notes        string    `json:"notes" gorm:"type:text"`
+	ReviewerID   uuid.UUID `json:"reviewer_id" gorm:"type:uuid;not null"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// KYCStatusChange - history entry of a submission's status
+type KYCStatusChange struct {
+	ID           uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	SubmissionID uuid.UUID `json:"submission_id" gorm:"type:uuid;not null;index"`
+	FromStatus   KYCStatus `json:"from_status" gorm:"type:varchar(20)"` // Empty for the initial submission
+	ToStatus     KYCStatus `json:"to_status" gorm:"type:varchar(20);not null"`
+	ChangedBy    uuid.UUID `json:"changed_by" gorm:"type:uuid;not null"`
+	Note         string    `json:"note" gorm:"type:text"`
+	CreatedAt    time.Time `json:"created_at"`
+}
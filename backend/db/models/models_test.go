@@ -0,0 +1,67 @@
+package models
+
+import "testing"
+
+func TestPurchaseStatusCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from, to PurchaseStatus
+		want     bool
+	}{
+		{PurchaseAwaitingPayment, PurchasePaid, true},
+		{PurchaseAwaitingPayment, PurchaseCancelled, true},
+		{PurchaseAwaitingPayment, PurchaseExpired, true},
+		{PurchaseAwaitingPayment, PurchaseApproved, false},
+		{PurchaseAwaitingPayment, PurchaseDeclined, false},
+		{PurchaseAwaitingPayment, PurchaseRefunded, false},
+
+		{PurchasePaid, PurchaseApproved, true},
+		{PurchasePaid, PurchaseDeclined, true},
+		{PurchasePaid, PurchaseCancelled, true},
+		{PurchasePaid, PurchaseExpired, true},
+		{PurchasePaid, PurchaseTransferred, false},
+		{PurchasePaid, PurchaseRefunded, false},
+
+		{PurchaseApproved, PurchaseTransferred, true},
+		{PurchaseApproved, PurchaseDeclined, true},
+		{PurchaseApproved, PurchaseCancelled, false}, // the buyer can't back out once the owner accepted
+		{PurchaseApproved, PurchaseExpired, false},
+
+		{PurchaseDeclined, PurchaseRefunded, true},
+		{PurchaseCancelled, PurchaseRefunded, true},
+		{PurchaseExpired, PurchaseRefunded, true},
+		{PurchaseDeclined, PurchasePaid, false},
+		{PurchaseExpired, PurchasePaid, false},
+
+		// final statuses
+		{PurchaseTransferred, PurchaseRefunded, false},
+		{PurchaseTransferred, PurchaseDeclined, false},
+		{PurchaseRefunded, PurchasePaid, false},
+		{PurchaseRefunded, PurchaseRefunded, false},
+
+		{PurchasePaid, PurchasePaid, false},
+		{PurchaseStatus("unknown"), PurchasePaid, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+			t.Errorf("%s -> %s = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestPurchaseTransitionsAreKnownStatuses(t *testing.T) {
+	known := map[PurchaseStatus]bool{
+		PurchaseAwaitingPayment: true, PurchasePaid: true, PurchaseApproved: true, PurchaseTransferred: true,
+		PurchaseDeclined: true, PurchaseCancelled: true, PurchaseExpired: true, PurchaseRefunded: true,
+	}
+	for from, targets := range PurchaseTransitions {
+		if !known[from] {
+			t.Errorf("transitions from unknown status %q", from)
+		}
+		for _, to := range targets {
+			if !known[to] {
+				t.Errorf("%s moves to unknown status %q", from, to)
+			}
+		}
+	}
+}
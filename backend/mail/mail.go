@@ -0,0 +1,139 @@
+// mail package - outgoing email behind a pluggable sender
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Message - plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender - delivers messages, implemented by SMTP for production and file/log senders for development
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewSenderEnv - create the sender selected by MAIL_DRIVER (smtp, file or log, default log)
+// smtp uses SMTP_HOST, SMTP_PORT (default 587), SMTP_USERNAME, SMTP_PASSWORD and MAIL_FROM,
+// file writes one .eml per message into MAIL_DIR (default ./mail_out)
+func NewSenderEnv() (Sender, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+
+	switch driver := os.Getenv("MAIL_DRIVER"); driver {
+	case "smtp":
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			return nil, fmt.Errorf("SMTP_HOST is required for MAIL_DRIVER=smtp")
+		}
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		return &SMTPSender{
+			Addr:     net.JoinHostPort(host, port),
+			Host:     host,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}, nil
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "mail_out"
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create MAIL_DIR: %w", err)
+		}
+		return &FileSender{Dir: dir, From: from}, nil
+	case "", "log":
+		log.Printf("Warning: MAIL_DRIVER not set, emails are only written to the log")
+		return &LogSender{}, nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_DRIVER %q, use smtp, file or log", driver)
+	}
+}
+
+// SMTPSender - delivers through an SMTP server, STARTTLS is used when the server offers it
+type SMTPSender struct {
+	Addr     string
+	Host     string
+	Username string
+	Password string
+	From     string
+}
+
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	// net/smtp has no context support, run it aside so the caller isn't held past its deadline
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(s.Addr, auth, s.From, []string{msg.To}, format(s.From, msg))
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// FileSender - writes each message to Dir as an .eml file, for local development and tests
+type FileSender struct {
+	Dir  string
+	From string
+}
+
+func (s *FileSender) Send(ctx context.Context, msg Message) error {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405"), hex.EncodeToString(suffix))
+	path := filepath.Join(s.Dir, name)
+	if err := os.WriteFile(path, format(s.From, msg), 0o600); err != nil {
+		return err
+	}
+	log.Printf("Info: Email to %s written to %s", msg.To, path)
+	return nil
+}
+
+// LogSender - prints messages to the log instead of sending them
+type LogSender struct{}
+
+func (s *LogSender) Send(ctx context.Context, msg Message) error {
+	log.Printf("Info: Email to %s\nSubject: %s\n\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// format - RFC 5322 message with headers, line endings normalized to CRLF
+func format(from string, msg Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " + msg.Subject + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return []byte(b.String())
+}
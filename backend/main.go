@@ -3,14 +3,22 @@ package main
 
 import (
 	"backend/api"
+	"backend/auth"
 	"backend/blockchain"
 	"backend/blockchain/worker"
 	"backend/db"
+	"backend/mail"
 	"log"
+	"os"
 )
 
 // main function - application startup sequence
 func main() {
+	// JWT signing keys, refuse to start with a broken key configuration
+	if err := auth.LoadKeysEnv(); err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+
 	// setup database first, required for everything
 	// database init includes migrations and admin user creation
 	log.Printf("Setting up database connection...")
@@ -20,6 +28,11 @@ func main() {
 	}
 	log.Printf("Database connected successfully")
 
+	// access tokens are checked against server-side sessions on every request
+	auth.SetRevocationStore(database)
+	// service accounts authenticate with API keys kept in the database
+	auth.SetAPIKeyStore(database)
+
 	// try blockchain connection, optional - system works without it
 	// blockchain service handles smart contract interactions
 	log.Printf("Attempting to connect to blockchain...")
@@ -30,6 +43,9 @@ func main() {
 		chainService = nil
 	} else {
 		log.Printf("Blockchain connected (Chain ID: %s)", chainService.ChainID.String())
+
+		// record every transaction the backend sends in the audit log
+		chainService.Tracker = worker.NewTransactionTracker(database)
 	}
 
 	// start event listeners if blockchain available
@@ -37,13 +53,26 @@ func main() {
 	if chainService != nil {
 		log.Printf("Starting blockchain event monitoring...")
 		worker.StartListeners(chainService, database)
+		worker.StartTransactionConfirmer(chainService, database)
 		log.Printf("Event listeners active (might see warnings if RPC doesn't support event subscriptions)")
 	}
 
 	// finally start the API server
 	// api handler needs both database and blockchain service
 	log.Printf("Starting API server...")
-	handler := api.NewRequestHandler(database, chainService)
+	mailer, err := mail.NewSenderEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure mail: %v", err)
+	}
+
+	// login rate limits and lockouts, in Postgres unless RATE_LIMIT_STORE=memory (single instance only)
+	var attempts auth.AttemptStore = database
+	if os.Getenv("RATE_LIMIT_STORE") == "memory" {
+		attempts = auth.NewMemoryAttemptStore()
+	}
+	limiter := auth.NewLimiter(attempts, auth.LimiterConfigEnv())
+
+	handler := api.NewRequestHandler(database, chainService, mailer, limiter)
 	handler.Start()
 }
 